@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/export"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const defaultAnnotationColor = "yellow"
+
+type CreateAnnotationRequest struct {
+	PageNumber   *int32  `json:"page_number" binding:"omitempty,min=1"`
+	CfiRange     *string `json:"cfi_range"`
+	SelectedText string  `json:"selected_text" binding:"required"`
+	Color        string  `json:"color" binding:"max=20"`
+	Note         *string `json:"note"`
+}
+
+// UpdateAnnotationRequest changes the fields it sets and keeps the rest.
+// Since a missing field and null look the same, the note and the CFI range are
+// removed with clear_note and clear_cfi_range instead.
+type UpdateAnnotationRequest struct {
+	PageNumber    *int32  `json:"page_number" binding:"omitempty,min=1"`
+	CfiRange      *string `json:"cfi_range" binding:"excluded_if=ClearCfiRange true"`
+	ClearCfiRange bool    `json:"clear_cfi_range"`
+	SelectedText  *string `json:"selected_text"`
+	Color         *string `json:"color" binding:"omitempty,min=1,max=20"`
+	Note          *string `json:"note" binding:"excluded_if=ClearNote true"`
+	ClearNote     bool    `json:"clear_note"`
+}
+
+func parseAnnotationID(c *gin.Context) (uuid.UUID, bool) {
+	annotationID := c.Param("annotation_id")
+	uuidAnnotationID, err := uuid.Parse(annotationID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": annotationID + " is not a valid uuid"})
+		return uuid.Nil, false
+	}
+
+	return uuidAnnotationID, true
+}
+
+func listAnnotationsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	highlights, err := cfg.Queries.GetHighlightsByBookID(c, repository.GetHighlightsByBookIDParams{BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, highlights)
+}
+
+func createAnnotationHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req CreateAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.PageNumber == nil && (req.CfiRange == nil || *req.CfiRange == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either page_number or cfi_range is required"})
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	if req.PageNumber != nil && book.TotalPages > 0 && *req.PageNumber > book.TotalPages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number is out of range"})
+		return
+	}
+
+	color := strings.TrimSpace(req.Color)
+	if color == "" {
+		color = defaultAnnotationColor
+	}
+
+	highlight, err := cfg.Queries.CreateHighlight(c, repository.CreateHighlightParams{
+		ID:           uuid.New(),
+		BookID:       book.ID,
+		UserID:       dbUser.ID,
+		PageNumber:   req.PageNumber,
+		CfiRange:     req.CfiRange,
+		SelectedText: req.SelectedText,
+		Color:        color,
+		Note:         req.Note,
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, highlight)
+}
+
+func getAnnotationHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	annotationID, ok := parseAnnotationID(c)
+	if !ok {
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	highlight, err := cfg.Queries.GetHighlightByID(c, repository.GetHighlightByIDParams{ID: annotationID, BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, highlight)
+}
+
+func updateAnnotationHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	annotationID, ok := parseAnnotationID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	if req.PageNumber != nil && book.TotalPages > 0 && *req.PageNumber > book.TotalPages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number is out of range"})
+		return
+	}
+
+	if req.Color != nil {
+		color := strings.TrimSpace(*req.Color)
+		if color == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "color can't be blank"})
+			return
+		}
+		req.Color = &color
+	}
+
+	// An annotation has to keep a position, so the CFI range can only go
+	// when there is a page to fall back to.
+	if req.ClearCfiRange && req.PageNumber == nil {
+		current, err := cfg.Queries.GetHighlightByID(c, repository.GetHighlightByIDParams{ID: annotationID, BookID: book.ID, UserID: dbUser.ID})
+		if err != nil {
+			if strings.Contains(err.Error(), "no rows") {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if current.PageNumber == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "either page_number or cfi_range is required"})
+			return
+		}
+	}
+
+	highlight, err := cfg.Queries.UpdateHighlight(c, repository.UpdateHighlightParams{
+		PageNumber:    req.PageNumber,
+		ClearCfiRange: req.ClearCfiRange,
+		CfiRange:      req.CfiRange,
+		SelectedText:  req.SelectedText,
+		Color:         req.Color,
+		ClearNote:     req.ClearNote,
+		Note:          req.Note,
+		ID:            annotationID,
+		BookID:        book.ID,
+		UserID:        dbUser.ID,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, highlight)
+}
+
+func deleteAnnotationHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	annotationID, ok := parseAnnotationID(c)
+	if !ok {
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteHighlight(c, repository.DeleteHighlightParams{ID: annotationID, BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "annotation not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+var exportContentTypes = map[export.Format]string{
+	export.FormatMarkdown: "text/markdown; charset=utf-8",
+	export.FormatJSON:     "application/json; charset=utf-8",
+	export.FormatCSV:      "text/csv; charset=utf-8",
+}
+
+var exportExtensions = map[export.Format]string{
+	export.FormatMarkdown: ".md",
+	export.FormatJSON:     ".json",
+	export.FormatCSV:      ".csv",
+}
+
+func parseExportFormat(c *gin.Context) (export.Format, bool) {
+	format := export.Format(c.DefaultQuery("format", string(export.FormatMarkdown)))
+	if _, ok := exportContentTypes[format]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of markdown, json, csv"})
+		return "", false
+	}
+
+	return format, true
+}
+
+func sendExport(c *gin.Context, filename, contentType string, data []byte) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// exportAnnotationsHandler downloads the user's highlights in a book as a
+// Markdown note, JSON or CSV.
+func exportAnnotationsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	format, ok := parseExportFormat(c)
+	if !ok {
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	highlights, err := cfg.Queries.GetHighlightsByBookID(c, repository.GetHighlightsByBookIDParams{BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookmarks, err := cfg.Queries.GetBookmarksByBookID(c, repository.GetBookmarksByBookIDParams{BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	books := []export.BookAnnotations{{Book: book, Highlights: highlights, Bookmarks: bookmarks}}
+
+	var buf bytes.Buffer
+	switch format {
+	case export.FormatMarkdown:
+		err = export.Markdown(&buf, books[0])
+	case export.FormatJSON:
+		err = export.JSON(&buf, books)
+	case export.FormatCSV:
+		err = export.CSV(&buf, books)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendExport(c, export.Filename(book)+exportExtensions[format], exportContentTypes[format], buf.Bytes())
+}
+
+// exportLibraryAnnotationsHandler downloads the user's highlights across every
+// book they can still read. Markdown comes as a zip with a note per book.
+func exportLibraryAnnotationsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	format, ok := parseExportFormat(c)
+	if !ok {
+		return
+	}
+
+	highlights, err := cfg.Queries.GetHighlightsByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	byBook := map[uuid.UUID][]repository.Highlight{}
+	var bookIDs []uuid.UUID
+	for _, highlight := range highlights {
+		if _, ok := byBook[highlight.BookID]; !ok {
+			bookIDs = append(bookIDs, highlight.BookID)
+		}
+		byBook[highlight.BookID] = append(byBook[highlight.BookID], highlight)
+	}
+
+	bookmarks, err := cfg.Queries.GetBookmarksByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookmarksByBook := map[uuid.UUID][]repository.Bookmark{}
+	for _, bookmark := range bookmarks {
+		if _, ok := byBook[bookmark.BookID]; !ok {
+			if _, ok := bookmarksByBook[bookmark.BookID]; !ok {
+				bookIDs = append(bookIDs, bookmark.BookID)
+			}
+		}
+		bookmarksByBook[bookmark.BookID] = append(bookmarksByBook[bookmark.BookID], bookmark)
+	}
+
+	books := []export.BookAnnotations{}
+	if len(bookIDs) > 0 {
+		readable, err := cfg.Queries.GetReadableBooksByIDs(c, repository.GetReadableBooksByIDsParams{Ids: bookIDs, UserID: dbUser.ID})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, book := range readable {
+			books = append(books, export.BookAnnotations{Book: book, Highlights: byBook[book.ID], Bookmarks: bookmarksByBook[book.ID]})
+		}
+	}
+	slices.SortFunc(books, func(a, b export.BookAnnotations) int {
+		return strings.Compare(strings.ToLower(a.Book.Title), strings.ToLower(b.Book.Title))
+	})
+
+	var buf bytes.Buffer
+	switch format {
+	case export.FormatMarkdown:
+		err = export.MarkdownZip(&buf, books)
+	case export.FormatJSON:
+		err = export.JSON(&buf, books)
+	case export.FormatCSV:
+		err = export.CSV(&buf, books)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == export.FormatMarkdown {
+		sendExport(c, "highlights.zip", "application/zip", buf.Bytes())
+		return
+	}
+	sendExport(c, "highlights"+exportExtensions[format], exportContentTypes[format], buf.Bytes())
+}
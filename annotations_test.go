@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestUpdateAnnotationRequestValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "empty update", body: `{}`},
+		{name: "color", body: `{"color": "blue"}`},
+		{name: "empty color", body: `{"color": ""}`, wantErr: true},
+		{name: "color too long", body: `{"color": "a very very long colour name"}`, wantErr: true},
+		{name: "clear note", body: `{"clear_note": true}`},
+		{name: "null note is not a clear", body: `{"note": null}`},
+		{name: "note and clear note", body: `{"note": "x", "clear_note": true}`, wantErr: true},
+		{name: "clear cfi range", body: `{"clear_cfi_range": true, "page_number": 3}`},
+		{name: "cfi range and clear cfi range", body: `{"cfi_range": "epubcfi(/6/4)", "clear_cfi_range": true}`, wantErr: true},
+		{name: "page zero", body: `{"page_number": 0}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req UpdateAnnotationRequest
+			err := binding.JSON.BindBody([]byte(tt.body), &req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("binding %s: error = %v, wantErr %v", tt.body, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=read write"`
+}
+
+type CreatedAPIToken struct {
+	repository.ApiToken
+	Token string `json:"token"`
+}
+
+// requireSession stops API tokens from being used to mint or revoke
+// credentials: other API tokens, the KOReader sync account and the OPDS feed
+// token. Otherwise a read-only token could give itself write access, or a
+// leaked one could outlive its revocation. The request is aborted when ok is
+// false.
+func requireSession(c *gin.Context) (ok bool) {
+	if auth.GetAPITokenFromRequest(c) != nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "credentials can only be managed from a signed-in session"})
+		return false
+	}
+	return true
+}
+
+func listAPITokensHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := cfg.Queries.GetAPITokensByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// createAPITokenHandler issues a personal access token. The token itself is
+// only ever shown in this response.
+func createAPITokenHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !requireSession(c) {
+		return
+	}
+
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slices.Sort(req.Scopes)
+	scopes := slices.Compact(req.Scopes)
+
+	token, hash, err := auth.NewAPIToken()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	stored, err := cfg.Queries.CreateAPIToken(c, repository.CreateAPITokenParams{
+		ID:          uuid.New(),
+		UserID:      dbUser.ID,
+		Name:        req.Name,
+		TokenPrefix: token[:len(auth.APITokenPrefix)+6],
+		TokenHash:   hash,
+		Scopes:      scopes,
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreatedAPIToken{ApiToken: stored, Token: token})
+}
+
+func deleteAPITokenHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !requireSession(c) {
+		return
+	}
+
+	tokenID := c.Param("token_id")
+	uuidTokenID, err := uuid.Parse(tokenID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": tokenID + " is not a valid uuid"})
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteAPIToken(c, repository.DeleteAPITokenParams{ID: uuidTokenID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "api token not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -9,8 +9,6 @@ import (
 
 	"github.com/Hodik/noteshelf-be.git/repository"
 	"github.com/clerk/clerk-sdk-go/v2"
-	"github.com/clerk/clerk-sdk-go/v2/jwt"
-	"github.com/clerk/clerk-sdk-go/v2/user"
 	"github.com/gin-gonic/gin"
 )
 
@@ -46,7 +44,9 @@ func GetPrimaryEmail(usr *clerk.User) *string {
 	return nil
 }
 
-func AuthMiddleware(queries *repository.Queries) gin.HandlerFunc {
+// AuthMiddleware authenticates requests by their bearer token: a personal
+// access token, or otherwise a session token checked by authenticator.
+func AuthMiddleware(authenticator Authenticator, queries *repository.Queries) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authheader := c.GetHeader("Authorization")
 		if authheader == "" {
@@ -61,42 +61,53 @@ func AuthMiddleware(queries *repository.Queries) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := jwt.Verify(c, &jwt.VerifyParams{
-			Token: parts[1],
-		})
-		if err != nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
+		if strings.HasPrefix(parts[1], APITokenPrefix) {
+			apiTokenAuth(c, queries, parts[1])
 			return
 		}
 
-		usr, err := user.Get(c, claims.Subject)
-		c.Set("user", usr)
-
-		dbUser, err := syncUser(c, usr, queries)
+		identity, err := authenticator.Authenticate(c, parts[1])
 		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusInternalServerError, &gin.H{"detail": err.Error()})
 			return
 		}
+		if identity.ClerkUser != nil {
+			c.Set("user", identity.ClerkUser)
+		}
+
+		dbUser := identity.User
+		if dbUser == nil {
+			dbUser, err = syncUser(c, identity, queries)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, &gin.H{"detail": err.Error()})
+				return
+			}
+			if cache, ok := authenticator.(userCache); ok {
+				cache.rememberUser(*dbUser)
+			}
+		}
 		c.Set("dbUser", dbUser)
 		c.Next()
 	}
 }
 
-func syncUser(ctx context.Context, usr *clerk.User, queries *repository.Queries) (*repository.User, error) {
-	user, err := queries.GetUserById(ctx, usr.ID)
+func syncUser(ctx context.Context, identity *Identity, queries *repository.Queries) (*repository.User, error) {
+	user, err := queries.GetUserById(ctx, identity.Subject)
 
 	if err == nil {
 		return &user, nil
 	}
 
 	if errors.Is(err, sql.ErrNoRows) {
-		phoneNumber := GetPrimaryPhone(usr)
-		email := GetPrimaryEmail(usr)
-		if email == nil {
-			return nil, errors.New("email not found in clerk user")
+		if identity.Email == nil {
+			return nil, errors.New("email not found in user identity")
 		}
 
-		user, err = queries.CreateUser(ctx, repository.CreateUserParams{ID: usr.ID, FirstName: usr.FirstName, LastName: usr.LastName, Username: usr.Username, Email: *email, Phone: phoneNumber})
+		user, err = queries.CreateUser(ctx, repository.CreateUserParams{ID: identity.Subject, FirstName: identity.FirstName, LastName: identity.LastName, Username: identity.Username, Email: *identity.Email, Phone: identity.Phone})
 		if err != nil {
 			return nil, err
 		} else {
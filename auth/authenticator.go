@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/clerk/clerk-sdk-go/v2"
+)
+
+// ErrInvalidToken is returned by an Authenticator for tokens that are
+// malformed, expired or not signed by a trusted key.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity is the user a bearer token was issued to.
+type Identity struct {
+	Subject   string
+	Email     *string
+	Username  *string
+	FirstName *string
+	LastName  *string
+	Phone     *string
+	// ClerkUser is the full Clerk profile when Clerk verified the token.
+	ClerkUser *clerk.User
+	// User is the users row when the authenticator already has it, so the
+	// middleware doesn't load it again.
+	User *repository.User
+}
+
+// Authenticator verifies session tokens from an identity provider.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// userCache is implemented by authenticators that keep the users row with
+// the profiles they cache.
+type userCache interface {
+	rememberUser(user repository.User)
+	forgetUser(subject string)
+}
+
+// ForgetUser drops the cached users row of subject, so a change to it shows
+// on the next request. Other instances pick it up when their profile cache
+// expires.
+func ForgetUser(authenticator Authenticator, subject string) {
+	if cache, ok := authenticator.(userCache); ok {
+		cache.forgetUser(subject)
+	}
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/jwt"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+	josejwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+const (
+	DefaultProfileTTL = 5 * time.Minute
+	// jwkRefreshInterval is how long a signing key is used before it is
+	// fetched again. Clerk rotates keys under a new kid, which is fetched on
+	// first sight regardless.
+	jwkRefreshInterval = time.Hour
+	maxCachedProfiles  = 10000
+)
+
+type cachedProfile struct {
+	identity  *Identity
+	fetchedAt time.Time
+}
+
+type cachedJWK struct {
+	key       *clerk.JSONWebKey
+	fetchedAt time.Time
+}
+
+// ClerkAuthenticator verifies Clerk session JWTs and loads the profile from
+// Clerk's API. Profiles are cached in memory for the TTL, and signing keys
+// for an hour, so most requests don't reach Clerk at all. The users row is
+// cached along with the profile, so they don't reach the database either.
+// When Clerk can't be reached the last known profile, or else the stored
+// users row, is used instead.
+type ClerkAuthenticator struct {
+	ttl     time.Duration
+	queries *repository.Queries
+	// shared records when a profile was fetched on the users row, so other
+	// instances and restarts can skip the lookup while it is fresh.
+	shared bool
+
+	mu       sync.Mutex
+	profiles map[string]cachedProfile
+	keys     map[string]cachedJWK
+}
+
+// NewClerkAuthenticator caches profiles for ttl. queries is where profiles
+// are read from when Clerk is unreachable; with shared set their freshness is
+// also kept there.
+func NewClerkAuthenticator(secretKey string, ttl time.Duration, queries *repository.Queries, shared bool) *ClerkAuthenticator {
+	clerk.SetKey(secretKey)
+	return &ClerkAuthenticator{
+		ttl:      ttl,
+		queries:  queries,
+		shared:   shared,
+		profiles: map[string]cachedProfile{},
+		keys:     map[string]cachedJWK{},
+	}
+}
+
+// clerkUnavailable tells failures to reach Clerk apart from answers it gave,
+// such as an unknown user.
+func clerkUnavailable(err error) bool {
+	var apiErr *clerk.APIErrorResponse
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.HTTPStatusCode >= http.StatusInternalServerError || apiErr.HTTPStatusCode == http.StatusTooManyRequests
+}
+
+// jwk returns the signing key with the given kid, fetching it when it is new
+// or due for a refresh. A key already known is kept when Clerk is down.
+func (a *ClerkAuthenticator) jwk(ctx context.Context, kid string) (*clerk.JSONWebKey, error) {
+	if kid == "" {
+		return nil, fmt.Errorf("%w: missing kid", ErrInvalidToken)
+	}
+
+	a.mu.Lock()
+	cached, ok := a.keys[kid]
+	a.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < jwkRefreshInterval {
+		return cached.key, nil
+	}
+
+	key, err := jwt.GetJSONWebKey(ctx, &jwt.GetJSONWebKeyParams{KeyID: kid})
+	switch {
+	case err == nil:
+	case strings.Contains(err.Error(), "missing json web key"):
+		return nil, errors.Join(ErrInvalidToken, err)
+	case ok && clerkUnavailable(err):
+		log.Printf("using cached clerk signing key %s: %s", kid, err)
+		return cached.key, nil
+	default:
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.keys[kid] = cachedJWK{key: key, fetchedAt: time.Now()}
+	a.mu.Unlock()
+	return key, nil
+}
+
+func (a *ClerkAuthenticator) cached(subject string) (cachedProfile, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	profile, ok := a.profiles[subject]
+	return profile, ok
+}
+
+func (a *ClerkAuthenticator) remember(identity *Identity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.profiles) >= maxCachedProfiles {
+		for subject, profile := range a.profiles {
+			if time.Since(profile.fetchedAt) >= a.ttl {
+				delete(a.profiles, subject)
+			}
+		}
+		if len(a.profiles) >= maxCachedProfiles {
+			clear(a.profiles)
+		}
+	}
+	a.profiles[identity.Subject] = cachedProfile{identity: identity, fetchedAt: time.Now()}
+}
+
+// rememberUser keeps the users row with the cached profile of its user until
+// the profile expires. Cached identities are never changed in place, since
+// requests may be holding them.
+func (a *ClerkAuthenticator) rememberUser(user repository.User) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	profile, ok := a.profiles[user.ID]
+	if !ok {
+		return
+	}
+	identity := *profile.identity
+	identity.User = &user
+	a.profiles[user.ID] = cachedProfile{identity: &identity, fetchedAt: profile.fetchedAt}
+}
+
+func (a *ClerkAuthenticator) forgetUser(subject string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	profile, ok := a.profiles[subject]
+	if !ok || profile.identity.User == nil {
+		return
+	}
+	identity := *profile.identity
+	identity.User = nil
+	a.profiles[subject] = cachedProfile{identity: &identity, fetchedAt: profile.fetchedAt}
+}
+
+func identityFromUser(user repository.User) *Identity {
+	return &Identity{
+		Subject:   user.ID,
+		Email:     &user.Email,
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Phone:     user.Phone,
+		User:      &user,
+	}
+}
+
+func (a *ClerkAuthenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	parsed, err := josejwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) == 0 {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	key, err := a.jwk(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := jwt.Verify(ctx, &jwt.VerifyParams{
+		Token: token,
+		JWK:   key,
+	})
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	cached, ok := a.cached(claims.Subject)
+	if ok && time.Since(cached.fetchedAt) < a.ttl {
+		return cached.identity, nil
+	}
+
+	if a.shared {
+		stored, err := a.queries.GetUserWithFreshProfile(ctx, repository.GetUserWithFreshProfileParams{ID: claims.Subject, MaxAgeSeconds: int32(a.ttl / time.Second)})
+		if err == nil {
+			identity := identityFromUser(stored)
+			a.remember(identity)
+			return identity, nil
+		}
+		if !strings.Contains(err.Error(), "no rows") {
+			log.Printf("reading stored profile of %s: %s", claims.Subject, err)
+		}
+	}
+
+	usr, err := user.Get(ctx, claims.Subject)
+	if err != nil {
+		var apiErr *clerk.APIErrorResponse
+		if errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusNotFound {
+			return nil, errors.Join(ErrInvalidToken, err)
+		}
+		if !clerkUnavailable(err) {
+			return nil, err
+		}
+
+		// Keep serving the fallback for a TTL rather than waiting on Clerk
+		// again for every request of the outage.
+		identity := cached.identity
+		if !ok {
+			if a.queries == nil {
+				return nil, err
+			}
+			stored, dbErr := a.queries.GetUserById(ctx, claims.Subject)
+			if dbErr != nil {
+				return nil, err
+			}
+			identity = identityFromUser(stored)
+		}
+		log.Printf("clerk is unavailable, using the last known profile of %s: %s", claims.Subject, err)
+		a.remember(identity)
+		return identity, nil
+	}
+
+	identity := &Identity{
+		Subject:   usr.ID,
+		Email:     GetPrimaryEmail(usr),
+		Username:  usr.Username,
+		FirstName: usr.FirstName,
+		LastName:  usr.LastName,
+		Phone:     GetPrimaryPhone(usr),
+		ClerkUser: usr,
+	}
+	a.remember(identity)
+
+	// Users signing in for the first time have no row yet; the middleware
+	// creates it.
+	if a.shared && identity.Email != nil {
+		if _, err := a.queries.UpdateUserProfile(ctx, repository.UpdateUserProfileParams{
+			Email:     *identity.Email,
+			Username:  identity.Username,
+			FirstName: identity.FirstName,
+			LastName:  identity.LastName,
+			Phone:     identity.Phone,
+			ID:        identity.Subject,
+		}); err != nil {
+			log.Printf("storing profile of %s: %s", identity.Subject, err)
+		}
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+)
+
+func TestClerkAuthenticatorCachesUser(t *testing.T) {
+	a := &ClerkAuthenticator{ttl: time.Minute, profiles: map[string]cachedProfile{}}
+	a.remember(&Identity{Subject: "user_1"})
+	held, _ := a.cached("user_1")
+
+	a.rememberUser(repository.User{ID: "user_1", TimeZone: "Europe/Kyiv"})
+	a.rememberUser(repository.User{ID: "user_2"})
+
+	profile, _ := a.cached("user_1")
+	if profile.identity.User == nil || profile.identity.User.TimeZone != "Europe/Kyiv" {
+		t.Fatalf("cached user = %+v, want the remembered row", profile.identity.User)
+	}
+	if held.identity.User != nil {
+		t.Error("rememberUser() changed an identity a request may be holding")
+	}
+	if !profile.fetchedAt.Equal(held.fetchedAt) {
+		t.Error("rememberUser() extended the profile's TTL")
+	}
+	if _, ok := a.cached("user_2"); ok {
+		t.Error("rememberUser() cached a user without a profile")
+	}
+
+	ForgetUser(a, "user_1")
+	if profile, _ := a.cached("user_1"); profile.identity.User != nil {
+		t.Errorf("cached user after ForgetUser() = %+v, want nil", profile.identity.User)
+	}
+}
@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	josejwt "github.com/go-jose/go-jose/v3/jwt"
+)
+
+const (
+	testIssuer   = "https://id.example.com"
+	testAudience = "noteshelf"
+)
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// validClaims are the claims of a token every test authenticator accepts.
+func validClaims() josejwt.Claims {
+	now := time.Now()
+	return josejwt.Claims{
+		Subject:  "user_1",
+		Issuer:   testIssuer,
+		Audience: josejwt.Audience{testAudience},
+		IssuedAt: josejwt.NewNumericDate(now),
+		Expiry:   josejwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func signToken(t *testing.T, alg jose.SignatureAlgorithm, key any, kid string, claims josejwt.Claims, extra ...any) string {
+	t.Helper()
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if kid != "" {
+		opts = opts.WithHeader("kid", kid)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := josejwt.Signed(signer).Claims(claims)
+	for _, c := range extra {
+		builder = builder.Claims(c)
+	}
+	token, err := builder.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// unsignedToken builds a token with alg none, which go-jose won't sign.
+func unsignedToken(t *testing.T, claims josejwt.Claims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + enc.EncodeToString(payload) + "."
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, ecKey, otherKey := mustRSAKey(t), mustECKey(t), mustRSAKey(t)
+	keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: rsaKey.Public(), KeyID: "rsa", Algorithm: string(jose.RS256)},
+		{Key: ecKey.Public(), KeyID: "ec"},
+	}}
+	authenticator, err := NewJWTAuthenticator(keys, testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(edit func(*josejwt.Claims)) josejwt.Claims {
+		c := validClaims()
+		edit(&c)
+		return c
+	}
+	email, username := "reader@example.com", "reader"
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "rsa", token: signToken(t, jose.RS256, rsaKey, "rsa", validClaims(), oidcClaims{Email: &email, PreferredUsername: &username})},
+		{name: "ec", token: signToken(t, jose.ES256, ecKey, "ec", validClaims())},
+		{name: "no kid tries every key", token: signToken(t, jose.ES256, ecKey, "", validClaims())},
+		{name: "expired within leeway", token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) {
+			c.Expiry = josejwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+		}))},
+		{name: "expired", wantErr: true, token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) {
+			c.Expiry = josejwt.NewNumericDate(time.Now().Add(-time.Hour))
+		}))},
+		{name: "not yet valid", wantErr: true, token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) {
+			c.NotBefore = josejwt.NewNumericDate(time.Now().Add(time.Hour))
+		}))},
+		{name: "no expiry", wantErr: true, token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) { c.Expiry = nil }))},
+		{name: "no subject", wantErr: true, token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) { c.Subject = "" }))},
+		{name: "wrong audience", wantErr: true, token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) {
+			c.Audience = josejwt.Audience{"someone-else"}
+		}))},
+		{name: "wrong issuer", wantErr: true, token: signToken(t, jose.RS256, rsaKey, "rsa", claims(func(c *josejwt.Claims) {
+			c.Issuer = "https://evil.example.com"
+		}))},
+		{name: "untrusted key", wantErr: true, token: signToken(t, jose.RS256, otherKey, "rsa", validClaims())},
+		{name: "kid of another key", wantErr: true, token: signToken(t, jose.ES256, ecKey, "rsa", validClaims())},
+		{name: "algorithm the key is pinned against", wantErr: true, token: signToken(t, jose.PS256, rsaKey, "rsa", validClaims())},
+		{name: "alg none", wantErr: true, token: unsignedToken(t, validClaims())},
+		{name: "hs256 with the public key as secret", wantErr: true, token: signToken(t, jose.HS256, rsaDER, "rsa", validClaims())},
+		{name: "garbage", wantErr: true, token: "not.a.jwt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := authenticator.Authenticate(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Authenticate() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if identity.Subject != "user_1" {
+				t.Errorf("Authenticate() subject = %q, want user_1", identity.Subject)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticatorProfile(t *testing.T) {
+	key := mustECKey(t)
+	authenticator, err := NewJWTAuthenticator(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: key.Public()}}}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email, given := "reader@example.com", "Ada"
+	// Issuer and audience aren't checked when none are configured.
+	claims := validClaims()
+	claims.Issuer, claims.Audience = "https://any.example.com", nil
+	identity, err := authenticator.Authenticate(context.Background(), signToken(t, jose.ES256, key, "", claims, oidcClaims{Email: &email, GivenName: &given}))
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if identity.Email == nil || *identity.Email != email || identity.FirstName == nil || *identity.FirstName != given || identity.LastName != nil {
+		t.Errorf("Authenticate() identity = %+v, want the profile claims", identity)
+	}
+}
+
+func TestNewJWTAuthenticatorNoKeys(t *testing.T) {
+	if _, err := NewJWTAuthenticator(jose.JSONWebKeySet{}, testIssuer, testAudience); err == nil {
+		t.Error("NewJWTAuthenticator() with no keys succeeded")
+	}
+}
+
+func TestLoadPEMKeys(t *testing.T) {
+	rsaKey, ecKey := mustRSAKey(t), mustECKey(t)
+
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "id.example.com"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, ecKey.Public(), ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemOf := func(blocks ...*pem.Block) []byte {
+		var data []byte
+		for _, block := range blocks {
+			data = append(data, pem.EncodeToMemory(block)...)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantKeys []crypto.PublicKey
+		wantErr  bool
+	}{
+		{name: "pkix public key", data: pemOf(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), wantKeys: []crypto.PublicKey{rsaKey.Public()}},
+		{name: "pkcs1 public key", data: pemOf(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)}), wantKeys: []crypto.PublicKey{rsaKey.Public()}},
+		{name: "certificate", data: pemOf(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), wantKeys: []crypto.PublicKey{ecKey.Public()}},
+		{
+			name:     "bundle skipping private keys",
+			data:     pemOf(&pem.Block{Type: "PRIVATE KEY", Bytes: private}, &pem.Block{Type: "PUBLIC KEY", Bytes: der}, &pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+			wantKeys: []crypto.PublicKey{rsaKey.Public(), ecKey.Public()},
+		},
+		{name: "only a private key", data: pemOf(&pem.Block{Type: "PRIVATE KEY", Bytes: private}), wantErr: true},
+		{name: "corrupt public key", data: pemOf(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("junk")}), wantErr: true},
+		{name: "not pem", data: []byte("hello"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadPEMKeys(writeFile(t, "key.pem", tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadPEMKeys() = %d keys, want an error", len(keys.Keys))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPEMKeys() error = %v", err)
+			}
+			if len(keys.Keys) != len(tt.wantKeys) {
+				t.Fatalf("LoadPEMKeys() = %d keys, want %d", len(keys.Keys), len(tt.wantKeys))
+			}
+			for i, want := range tt.wantKeys {
+				if !want.(interface{ Equal(crypto.PublicKey) bool }).Equal(keys.Keys[i].Key) {
+					t.Errorf("key %d = %T, want %T", i, keys.Keys[i].Key, want)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadPEMKeys(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("LoadPEMKeys() of a missing file succeeded")
+	}
+}
+
+func TestLoadPEMKeysVerifiesTokens(t *testing.T) {
+	key := mustRSAKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadPEMKeys(writeFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := NewJWTAuthenticator(keys, testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticator.Authenticate(context.Background(), signToken(t, jose.RS256, key, "", validClaims())); err != nil {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	rsaKey, ecKey := mustRSAKey(t), mustECKey(t)
+
+	// A provider's jwks.json only has public keys, but a saved set with the
+	// private halves must not leave them in memory.
+	data, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: rsaKey, KeyID: "rsa", Algorithm: string(jose.RS256), Use: "sig"},
+		{Key: ecKey.Public(), KeyID: "ec", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadJWKS(writeFile(t, "jwks.json", data))
+	if err != nil {
+		t.Fatalf("LoadJWKS() error = %v", err)
+	}
+	if len(keys.Keys) != 2 {
+		t.Fatalf("LoadJWKS() = %d keys, want 2", len(keys.Keys))
+	}
+	for _, key := range keys.Keys {
+		if !key.IsPublic() {
+			t.Errorf("key %s is private", key.KeyID)
+		}
+	}
+
+	authenticator, err := NewJWTAuthenticator(keys, testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{
+		signToken(t, jose.RS256, rsaKey, "rsa", validClaims()),
+		signToken(t, jose.ES256, ecKey, "ec", validClaims()),
+	} {
+		if _, err := authenticator.Authenticate(context.Background(), token); err != nil {
+			t.Errorf("Authenticate() error = %v", err)
+		}
+	}
+
+	if _, err := LoadJWKS(writeFile(t, "jwks.json", []byte(`{"keys": [{"kty": "RSA"}]}`))); err == nil {
+		t.Error("LoadJWKS() of an invalid key succeeded")
+	}
+	if _, err := LoadJWKS(writeFile(t, "jwks.json", []byte("not json"))); err == nil {
+		t.Error("LoadJWKS() of invalid JSON succeeded")
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fakeTokenQueries keeps tokens by hash, the way the api_tokens table is
+// looked up.
+type fakeTokenQueries struct {
+	tokens  map[string]repository.ApiToken
+	touched []uuid.UUID
+	lookups []string
+}
+
+func (q *fakeTokenQueries) GetAPITokenByHash(ctx context.Context, tokenHash string) (repository.ApiToken, error) {
+	q.lookups = append(q.lookups, tokenHash)
+	token, ok := q.tokens[tokenHash]
+	if !ok {
+		return token, pgx.ErrNoRows
+	}
+	return token, nil
+}
+
+func (q *fakeTokenQueries) GetUserById(ctx context.Context, id string) (repository.User, error) {
+	return repository.User{ID: id}, nil
+}
+
+func (q *fakeTokenQueries) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	q.touched = append(q.touched, id)
+	return nil
+}
+
+func (q *fakeTokenQueries) add(t *testing.T, scopes []string, lastUsedAt pgtype.Timestamp) (string, repository.ApiToken) {
+	t.Helper()
+	raw, hash, err := NewAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := repository.ApiToken{ID: uuid.New(), UserID: "user_1", TokenHash: hash, Scopes: scopes, LastUsedAt: lastUsedAt}
+	q.tokens[hash] = token
+	return raw, token
+}
+
+// serveWithToken runs a request authenticated by raw through apiTokenAuth.
+func serveWithToken(queries apiTokenQueries, method, raw string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		apiTokenAuth(c, queries, strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	})
+	router.Any("/books", func(c *gin.Context) {
+		dbUser, err := GetDBUserFromRequest(c)
+		if err != nil || GetAPITokenFromRequest(c) == nil {
+			return
+		}
+		c.String(http.StatusOK, dbUser.ID)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/books", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewAPIToken(t *testing.T) {
+	raw, hash, err := NewAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, APITokenPrefix) {
+		t.Errorf("NewAPIToken() = %q, want the %q prefix", raw, APITokenPrefix)
+	}
+	if hash != HashAPIToken(raw) || strings.Contains(hash, raw) || len(hash) != 64 {
+		t.Errorf("NewAPIToken() hash = %q, want the hex SHA-256 of the token", hash)
+	}
+	if other, _, _ := NewAPIToken(); other == raw {
+		t.Error("NewAPIToken() returned the same token twice")
+	}
+}
+
+func TestAPITokenAuth(t *testing.T) {
+	queries := &fakeTokenQueries{tokens: map[string]repository.ApiToken{}}
+	readToken, _ := queries.add(t, []string{ScopeRead}, pgtype.Timestamp{})
+	writeToken, _ := queries.add(t, []string{ScopeWrite}, pgtype.Timestamp{})
+	revokedToken, revoked := queries.add(t, []string{ScopeRead, ScopeWrite}, pgtype.Timestamp{})
+	delete(queries.tokens, revoked.TokenHash)
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		want   int
+	}{
+		{name: "read token reads", method: http.MethodGet, token: readToken, want: http.StatusOK},
+		{name: "read token heads", method: http.MethodHead, token: readToken, want: http.StatusOK},
+		{name: "read token can't post", method: http.MethodPost, token: readToken, want: http.StatusForbidden},
+		{name: "read token can't patch", method: http.MethodPatch, token: readToken, want: http.StatusForbidden},
+		{name: "read token can't delete", method: http.MethodDelete, token: readToken, want: http.StatusForbidden},
+		{name: "write token writes", method: http.MethodDelete, token: writeToken, want: http.StatusOK},
+		{name: "write token reads", method: http.MethodGet, token: writeToken, want: http.StatusOK},
+		{name: "revoked token", method: http.MethodGet, token: revokedToken, want: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, token: APITokenPrefix + "made-up", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveWithToken(queries, tt.method, tt.token)
+			if w.Code != tt.want {
+				t.Fatalf("%s with token = %d, want %d", tt.method, w.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && tt.method != http.MethodHead && w.Body.String() != "user_1" {
+				t.Errorf("dbUser = %q, want the token's owner", w.Body.String())
+			}
+			if got, want := queries.lookups[len(queries.lookups)-1], HashAPIToken(tt.token); got != want {
+				t.Errorf("looked up %q, want the token's hash %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAPITokenAuthTouchesOncePerMinute(t *testing.T) {
+	now := time.Now().UTC()
+	queries := &fakeTokenQueries{tokens: map[string]repository.ApiToken{}}
+	neverUsed, neverUsedToken := queries.add(t, []string{ScopeRead}, pgtype.Timestamp{})
+	recent, _ := queries.add(t, []string{ScopeRead}, pgtype.Timestamp{Time: now.Add(-10 * time.Second), Valid: true})
+	stale, staleToken := queries.add(t, []string{ScopeRead}, pgtype.Timestamp{Time: now.Add(-2 * time.Minute), Valid: true})
+
+	for _, raw := range []string{neverUsed, recent, stale} {
+		if w := serveWithToken(queries, http.MethodGet, raw); w.Code != http.StatusOK {
+			t.Fatalf("GET with token = %d, want 200", w.Code)
+		}
+	}
+
+	if len(queries.touched) != 2 || queries.touched[0] != neverUsedToken.ID || queries.touched[1] != staleToken.ID {
+		t.Errorf("touched %v, want only the never used and stale tokens", queries.touched)
+	}
+}
+
+func TestNeedsTouch(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		lastUsedAt pgtype.Timestamp
+		want       bool
+	}{
+		{name: "never used", want: true},
+		{name: "just used", lastUsedAt: pgtype.Timestamp{Time: now.Add(-59 * time.Second), Valid: true}},
+		{name: "a minute ago", lastUsedAt: pgtype.Timestamp{Time: now.Add(-time.Minute), Valid: true}, want: true},
+		{name: "clock skew", lastUsedAt: pgtype.Timestamp{Time: now.Add(time.Minute), Valid: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsTouch(repository.ApiToken{LastUsedAt: tt.lastUsedAt}, now); got != tt.want {
+				t.Errorf("needsTouch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	for method, want := range map[string]string{
+		http.MethodGet: ScopeRead, http.MethodHead: ScopeRead, http.MethodOptions: ScopeRead,
+		http.MethodPost: ScopeWrite, http.MethodPut: ScopeWrite, http.MethodPatch: ScopeWrite, http.MethodDelete: ScopeWrite,
+	} {
+		if got := requiredScope(method); got != want {
+			t.Errorf("requiredScope(%s) = %s, want %s", method, got, want)
+		}
+	}
+}
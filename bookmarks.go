@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateBookmarkRequest struct {
+	PageNumber *int32   `json:"page_number" binding:"omitempty,min=1"`
+	Locator    *Locator `json:"locator"`
+	Label      string   `json:"label" binding:"required,max=255"`
+}
+
+func parseBookmarkID(c *gin.Context) (uuid.UUID, bool) {
+	bookmarkID := c.Param("bookmark_id")
+	uuidBookmarkID, err := uuid.Parse(bookmarkID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": bookmarkID + " is not a valid uuid"})
+		return uuid.Nil, false
+	}
+
+	return uuidBookmarkID, true
+}
+
+func listBookmarksHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	bookmarks, err := cfg.Queries.GetBookmarksByBookID(c, repository.GetBookmarksByBookIDParams{BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bookmarks)
+}
+
+func createBookmarkHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req CreateBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label := strings.TrimSpace(req.Label)
+	if label == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label must not be empty"})
+		return
+	}
+
+	if req.PageNumber == nil && req.Locator == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either page_number or locator is required"})
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	if req.PageNumber != nil && book.TotalPages > 0 && *req.PageNumber > book.TotalPages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "page_number is out of range"})
+		return
+	}
+
+	params := repository.CreateBookmarkParams{
+		ID:         uuid.New(),
+		BookID:     book.ID,
+		UserID:     dbUser.ID,
+		PageNumber: req.PageNumber,
+		Label:      label,
+	}
+	if req.Locator != nil {
+		params.LocatorHref = &req.Locator.Href
+		params.LocatorCfi = req.Locator.Locations.CFI
+		params.LocatorProgression = req.Locator.Locations.Progression
+		params.LocatorTotalProgression = req.Locator.Locations.TotalProgression
+	}
+
+	bookmark, err := cfg.Queries.CreateBookmark(c, params)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, bookmark)
+}
+
+func deleteBookmarkHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	bookmarkID, ok := parseBookmarkID(c)
+	if !ok {
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteBookmark(c, repository.DeleteBookmarkParams{ID: bookmarkID, BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "bookmark not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
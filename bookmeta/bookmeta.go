@@ -0,0 +1,67 @@
+package bookmeta
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatPDF     Format = "pdf"
+	FormatEPUB    Format = "epub"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported book format")
+
+type Metadata struct {
+	Format   Format
+	Title    string
+	Author   string
+	Language string
+	Pages    int
+}
+
+// DetectFormat sniffs the magic bytes at the start of a file.
+func DetectFormat(data []byte) Format {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+
+	if bytes.Contains(head, []byte("%PDF-")) {
+		return FormatPDF
+	}
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		// EPUB requires an uncompressed "mimetype" entry first in the archive,
+		// but fall back to looking for the container for sloppy writers.
+		if len(data) >= 58 && string(data[30:58]) == "mimetypeapplication/epub+zip" {
+			return FormatEPUB
+		}
+		if _, err := openEPUB(data); err == nil {
+			return FormatEPUB
+		}
+	}
+
+	return FormatUnknown
+}
+
+// Extract detects the format of data and reads its title, author, language and
+// page count. For EPUBs the page count is the number of spine items.
+func Extract(data []byte) (*Metadata, error) {
+	switch DetectFormat(data) {
+	case FormatPDF:
+		return extractPDF(data)
+	case FormatEPUB:
+		return extractEPUB(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
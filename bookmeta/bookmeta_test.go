@@ -0,0 +1,114 @@
+package bookmeta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Format
+	}{
+		{name: "pdf", data: readFixture(t, "simple.pdf"), want: FormatPDF},
+		{name: "pdf with leading junk", data: append([]byte("\r\n\x00junk"), readFixture(t, "simple.pdf")...), want: FormatPDF},
+		{name: "epub", data: readFixture(t, "simple.epub"), want: FormatEPUB},
+		{name: "epub without mimetype", data: readFixture(t, "nomimetype.epub"), want: FormatEPUB},
+		{name: "truncated epub without mimetype", data: readFixture(t, "nomimetype.epub")[:200], want: FormatUnknown},
+		{name: "zip that is not an epub", data: zipOf(t, map[string]string{"readme.txt": "hello"}), want: FormatUnknown},
+		{name: "text", data: []byte("just some text"), want: FormatUnknown},
+		{name: "empty", data: nil, want: FormatUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.data); got != tt.want {
+				t.Errorf("DetectFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	simplePDF := readFixture(t, "simple.pdf")
+	simpleEPUB := readFixture(t, "simple.epub")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Metadata
+		wantErr bool
+	}{
+		{
+			name: "pdf",
+			data: simplePDF,
+			want: &Metadata{Format: FormatPDF, Title: "A Simple Book", Author: "Jane Doe", Language: "en-GB", Pages: 2},
+		},
+		{
+			name: "pdf with object streams and utf-16 title",
+			data: readFixture(t, "compressed.pdf"),
+			want: &Metadata{Format: FormatPDF, Title: "Ünïcode Títle", Author: "Anon", Pages: 1},
+		},
+		{
+			name: "pdf truncated before the trailer",
+			data: simplePDF[:len(simplePDF)/2],
+			want: &Metadata{Format: FormatPDF, Language: "en-GB", Pages: 2},
+		},
+		{
+			name:    "pdf truncated to its header",
+			data:    simplePDF[:20],
+			wantErr: true,
+		},
+		{
+			name: "epub",
+			data: simpleEPUB,
+			want: &Metadata{Format: FormatEPUB, Title: "The Simple EPUB", Author: "First Author, Second Author", Language: "fr", Pages: 2},
+		},
+		{
+			name: "epub without metadata",
+			data: readFixture(t, "nomimetype.epub"),
+			want: &Metadata{Format: FormatEPUB, Pages: 1},
+		},
+		{
+			name:    "truncated epub",
+			data:    simpleEPUB[:len(simpleEPUB)/2],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Extract() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("Extract() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractUnsupported(t *testing.T) {
+	if _, err := Extract([]byte("just some text")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Extract() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
@@ -0,0 +1,217 @@
+package bookmeta
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+var ErrNoCover = errors.New("no cover image found")
+
+// maxCoverPixels caps the size of an image that is decoded for a cover. Image
+// headers are checked before decoding, so a few bytes declaring a huge canvas
+// cannot make the decoder allocate gigabytes.
+const maxCoverPixels = 25_000_000
+
+var errCoverTooLarge = errors.New("cover image is too large")
+
+// checkCoverSize reports whether an image of the given dimensions may be
+// decoded.
+func checkCoverSize(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return errors.New("cover image has no pixels")
+	}
+	if width > maxCoverPixels/height {
+		return errCoverTooLarge
+	}
+	return nil
+}
+
+// decodeCoverImage decodes a GIF, JPEG or PNG after checking the dimensions in
+// its header.
+func decodeCoverImage(raw []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCoverSize(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	return img, err
+}
+
+// ExtractCover returns the cover image of a book. For EPUBs this is the image
+// the package document marks as the cover. For PDFs the first page is
+// rendered; pages that cannot be rendered or come out blank fall back to the
+// largest image drawn on them.
+func ExtractCover(data []byte) (image.Image, error) {
+	switch DetectFormat(data) {
+	case FormatPDF:
+		return extractPDFCover(data)
+	case FormatEPUB:
+		return extractEPUBCover(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func extractEPUBCover(data []byte) (image.Image, error) {
+	book, err := openEPUB(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []epubManifestItem
+
+	// EPUB 3 marks the cover with a manifest property.
+	for _, item := range book.pkg.Manifest {
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") {
+			candidates = append(candidates, item)
+		}
+	}
+
+	// EPUB 2 points at it from <meta name="cover" content="item-id"/>.
+	for _, meta := range book.pkg.Metadata.Meta {
+		if meta.Name == "cover" {
+			if item, ok := book.byID[meta.Content]; ok {
+				candidates = append(candidates, item)
+			}
+		}
+	}
+
+	// Fall back to anything that looks like a cover by name.
+	for _, item := range book.pkg.Manifest {
+		if strings.HasPrefix(item.MediaType, "image/") && strings.Contains(strings.ToLower(item.ID+item.Href), "cover") {
+			candidates = append(candidates, item)
+		}
+	}
+
+	for _, item := range candidates {
+		if !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		raw, err := book.readFile(book.resolve(item.Href))
+		if err != nil {
+			continue
+		}
+		if img, err := decodeCoverImage(raw); err == nil {
+			return img, nil
+		}
+	}
+
+	return nil, ErrNoCover
+}
+
+func extractPDFCover(data []byte) (image.Image, error) {
+	doc, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, ErrNoCover
+	}
+
+	if img, err := doc.renderPage(pages[0], pdfRenderWidth); err == nil && !isBlank(img) {
+		return img, nil
+	}
+
+	resources := doc.dict(pages[0]["Resources"])
+	xobjects := doc.dict(resources["XObject"])
+
+	var best image.Image
+	bestArea := 0
+	for _, ref := range xobjects {
+		stream, ok := doc.resolve(ref).(*pdfStream)
+		if !ok || doc.resolve(stream.Dict["Subtype"]) != pdfName("Image") {
+			continue
+		}
+
+		width := toInt(doc.resolve(stream.Dict["Width"]))
+		height := toInt(doc.resolve(stream.Dict["Height"]))
+		if checkCoverSize(width, height) != nil || width*height <= bestArea {
+			continue
+		}
+
+		img, err := doc.decodeImage(stream, width, height)
+		if err != nil {
+			continue
+		}
+		best, bestArea = img, width*height
+	}
+
+	if best == nil {
+		return nil, ErrNoCover
+	}
+
+	return best, nil
+}
+
+func (d *pdfDocument) decodeImage(stream *pdfStream, width, height int) (image.Image, error) {
+	if err := checkCoverSize(width, height); err != nil {
+		return nil, err
+	}
+	data, imageFilter, err := d.decodeStreamFilters(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	switch imageFilter {
+	case "DCTDecode", "DCT":
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCoverSize(config.Width, config.Height); err != nil {
+			return nil, err
+		}
+		return jpeg.Decode(bytes.NewReader(data))
+	case "":
+	default:
+		return nil, errors.New("unsupported pdf image filter")
+	}
+
+	if toInt(d.resolve(stream.Dict["BitsPerComponent"])) != 8 {
+		return nil, errors.New("unsupported pdf image depth")
+	}
+
+	components := 0
+	switch cs := d.resolve(stream.Dict["ColorSpace"]).(type) {
+	case pdfName:
+		switch cs {
+		case "DeviceRGB", "CalRGB":
+			components = 3
+		case "DeviceGray", "CalGray":
+			components = 1
+		}
+	case pdfArray:
+		if len(cs) == 2 && d.resolve(cs[0]) == pdfName("ICCBased") {
+			components = toInt(d.resolve(d.dict(cs[1])["N"]))
+		}
+	}
+	if components != 1 && components != 3 {
+		return nil, errors.New("unsupported pdf image color space")
+	}
+	if len(data) < width*height*components {
+		return nil, errors.New("pdf image data is truncated")
+	}
+
+	if components == 1 {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		copy(img.Pix, data)
+		return img, nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		img.Set(i%width, i/width, color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xFF})
+	}
+	return img, nil
+}
@@ -0,0 +1,188 @@
+package bookmeta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestDecodeCoverImageTooLarge(t *testing.T) {
+	// A GIF header declaring a 20000x20000 canvas and nothing else.
+	raw := []byte("GIF89a\x20\x4e\x20\x4e\x00\x00\x00")
+	if _, err := decodeCoverImage(raw); !errors.Is(err, errCoverTooLarge) {
+		t.Errorf("decodeCoverImage() error = %v, want errCoverTooLarge", err)
+	}
+}
+
+func TestCheckCoverSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		wantErr       bool
+	}{
+		{width: 600, height: 900},
+		{width: 5000, height: 5000},
+		{width: 0, height: 900, wantErr: true},
+		{width: 600, height: -1, wantErr: true},
+		{width: 5001, height: 5000, wantErr: true},
+		{width: 1 << 40, height: 1 << 40, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := checkCoverSize(tt.width, tt.height); (err != nil) != tt.wantErr {
+			t.Errorf("checkCoverSize(%d, %d) error = %v, wantErr %v", tt.width, tt.height, err, tt.wantErr)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewGray(image.Rect(10, 20, 1010, 1520))
+	for i := range src.Pix {
+		src.Pix[i] = 0x80
+	}
+
+	data, err := Thumbnail(src, 200)
+	if err != nil {
+		t.Fatalf("Thumbnail() error = %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding thumbnail: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(200, 300) {
+		t.Errorf("thumbnail size = %v, want (200,300)", got)
+	}
+	if r, _, _, _ := color.GrayModel.Convert(img.At(100, 150)).RGBA(); r>>8 < 0x78 || r>>8 > 0x88 {
+		t.Errorf("thumbnail pixel = %#x, want about 0x80", r>>8)
+	}
+}
+
+// pdfOf builds a single page PDF with the given content stream and page
+// resources. Extra objects are numbered from 5.
+func pdfOf(mediaBox, resources, content string, extra ...string) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.7\n")
+	b.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	b.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	fmt.Fprintf(&b, "3 0 obj << /Type /Page /Parent 2 0 R /MediaBox %s /Resources %s /Contents 4 0 R >> endobj\n", mediaBox, resources)
+	fmt.Fprintf(&b, "4 0 obj << /Length %d >> stream\n%s\nendstream endobj\n", len(content), content)
+	for i, obj := range extra {
+		fmt.Fprintf(&b, "%d 0 obj %s endobj\n", i+5, obj)
+	}
+	b.WriteString("trailer << /Root 1 0 R >>\n%%EOF\n")
+	return []byte(b.String())
+}
+
+// darkPixels counts the pixels within r that are clearly not white.
+func darkPixels(img image.Image, r image.Rectangle) int {
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if c := color.GrayModel.Convert(img.At(x, y)).(color.Gray); c.Y < 0x80 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestExtractPDFCoverRendersPage(t *testing.T) {
+	const letter = "[0 0 612 792]"
+	helvetica := "<< /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >>"
+	embeddedFontPDF := pdfOf(letter, "<< /Font << /F1 7 0 R >> >>", "BT /F1 48 Tf 72 600 Td (Hello) Tj ET",
+		fmt.Sprintf("<< /Length %d >> stream\n%s\nendstream", len(goregular.TTF), goregular.TTF),
+		"<< /Type /FontDescriptor /FontFile2 5 0 R >>",
+		"<< /Type /Font /Subtype /TrueType /BaseFont /GoRegular /FontDescriptor 6 0 R >>",
+	)
+
+	doc, err := openPDF(embeddedFontPDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if font := doc.loadRenderFont(pdfRef{Num: 7}); font.fallback {
+		t.Error("embedded TrueType program was not used")
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		size image.Point
+		// ink must contain dark pixels and blank must not.
+		ink, blank image.Rectangle
+	}{
+		{
+			name:  "text only",
+			data:  readFixture(t, "simple.pdf"),
+			size:  image.Pt(640, 828),
+			ink:   image.Rect(70, 60, 180, 80),
+			blank: image.Rect(0, 120, 640, 828),
+		},
+		{
+			name:  "embedded truetype font",
+			data:  embeddedFontPDF,
+			size:  image.Pt(640, 828),
+			ink:   image.Rect(70, 150, 250, 210),
+			blank: image.Rect(0, 250, 640, 828),
+		},
+		{
+			name:  "filled and stroked paths",
+			data:  pdfOf(letter, "<< >>", "0 0 0.5 rg 100 100 200 200 re f 0 G 20 w 50 700 m 550 700 l S"),
+			size:  image.Pt(640, 828),
+			ink:   image.Rect(110, 520, 310, 720),
+			blank: image.Rect(350, 200, 640, 600),
+		},
+		{
+			name:  "clipped fill",
+			data:  pdfOf(letter, "<< >>", "q 0 0 306 792 re W n 0 g 0 0 612 792 re f Q"),
+			size:  image.Pt(640, 828),
+			ink:   image.Rect(0, 0, 318, 828),
+			blank: image.Rect(322, 0, 640, 828),
+		},
+		{
+			name:  "malformed content",
+			data:  pdfOf(letter, "<< >>", "Q Q 1e30 1e30 m 1e30 -1e30 l f q 2 0 0 2 0 0 cm BT /Missing 12 Tf (x) Tj ET ) ] 0 0 306 198 re f"),
+			size:  image.Pt(640, 828),
+			ink:   image.Rect(0, 420, 640, 828),
+			blank: image.Rect(0, 0, 640, 410),
+		},
+		{
+			name:  "rotated page",
+			data:  pdfOf("[0 0 612 792] /Rotate 90", helvetica, "0 g 0 0 100 792 re f"),
+			size:  image.Pt(640, 495),
+			ink:   image.Rect(0, 0, 640, 80),
+			blank: image.Rect(0, 90, 640, 495),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ExtractCover(tt.data)
+			if err != nil {
+				t.Fatalf("ExtractCover() error = %v", err)
+			}
+			if got := img.Bounds().Size(); got != tt.size {
+				t.Fatalf("cover size = %v, want %v", got, tt.size)
+			}
+			if darkPixels(img, tt.ink) == 0 {
+				t.Errorf("no ink within %v", tt.ink)
+			}
+			if n := darkPixels(img, tt.blank); n != 0 {
+				t.Errorf("%d dark pixels within %v, want none", n, tt.blank)
+			}
+		})
+	}
+}
+
+func TestExtractPDFCoverInvisibleText(t *testing.T) {
+	// OCR layers are drawn in text mode 3; a page with nothing else is blank.
+	data := pdfOf("[0 0 612 792]", "<< /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >>", "BT 3 Tr /F1 12 Tf 72 720 Td (Hidden) Tj ET")
+	if _, err := ExtractCover(data); !errors.Is(err, ErrNoCover) {
+		t.Errorf("ExtractCover() error = %v, want ErrNoCover", err)
+	}
+}
@@ -0,0 +1,172 @@
+package bookmeta
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// maxEPUBFileSize caps how much of a single archive entry is inflated, so a
+// zip bomb can't exhaust memory.
+const maxEPUBFileSize = 64 << 20
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubManifestItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+type epubPackageDocument struct {
+	Metadata struct {
+		Titles    []string `xml:"title"`
+		Creators  []string `xml:"creator"`
+		Languages []string `xml:"language"`
+		Meta      []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []epubManifestItem `xml:"manifest>item"`
+	Spine    []struct {
+		IDRef  string `xml:"idref,attr"`
+		Linear string `xml:"linear,attr"`
+	} `xml:"spine>itemref"`
+}
+
+type epubBook struct {
+	zip     *zip.Reader
+	opfDir  string
+	pkg     epubPackageDocument
+	byID    map[string]epubManifestItem
+	entries map[string]*zip.File
+}
+
+func openEPUB(data []byte) (*epubBook, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	book := &epubBook{zip: zr, entries: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		book.entries[f.Name] = f
+	}
+
+	var container epubContainer
+	if err := book.decodeXML("META-INF/container.xml", &container); err != nil {
+		return nil, fmt.Errorf("failed to read epub container: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.New("epub container has no rootfile")
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	if err := book.decodeXML(opfPath, &book.pkg); err != nil {
+		return nil, fmt.Errorf("failed to read epub package document: %w", err)
+	}
+
+	book.opfDir = path.Dir(opfPath)
+	book.byID = make(map[string]epubManifestItem, len(book.pkg.Manifest))
+	for _, item := range book.pkg.Manifest {
+		book.byID[item.ID] = item
+	}
+
+	return book, nil
+}
+
+func (b *epubBook) readFile(name string) ([]byte, error) {
+	f, ok := b.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in epub", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxEPUBFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEPUBFileSize {
+		return nil, fmt.Errorf("%s is too large", name)
+	}
+	return data, nil
+}
+
+func (b *epubBook) decodeXML(name string, v any) error {
+	data, err := b.readFile(name)
+	if err != nil {
+		return err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder.Decode(v)
+}
+
+// resolve turns a manifest href into a path inside the archive.
+func (b *epubBook) resolve(href string) string {
+	if i := strings.IndexAny(href, "#?"); i >= 0 {
+		href = href[:i]
+	}
+	href = strings.ReplaceAll(href, "%20", " ")
+	if b.opfDir == "." || b.opfDir == "" {
+		return path.Clean(href)
+	}
+	return path.Join(b.opfDir, href)
+}
+
+func (b *epubBook) spineItems() []epubManifestItem {
+	items := make([]epubManifestItem, 0, len(b.pkg.Spine))
+	for _, ref := range b.pkg.Spine {
+		if item, ok := b.byID[ref.IDRef]; ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func extractEPUB(data []byte) (*Metadata, error) {
+	book, err := openEPUB(data)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{Format: FormatEPUB, Pages: len(book.spineItems())}
+	if len(book.pkg.Metadata.Titles) > 0 {
+		meta.Title = cleanText(book.pkg.Metadata.Titles[0])
+	}
+
+	var creators []string
+	for _, creator := range book.pkg.Metadata.Creators {
+		if creator = cleanText(creator); creator != "" {
+			creators = append(creators, creator)
+		}
+	}
+	meta.Author = strings.Join(creators, ", ")
+
+	if len(book.pkg.Metadata.Languages) > 0 {
+		meta.Language = cleanText(book.pkg.Metadata.Languages[0])
+	}
+
+	return meta, nil
+}
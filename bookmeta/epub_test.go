@@ -0,0 +1,61 @@
+package bookmeta
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testContainer = `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`
+
+func TestOpenEPUBMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "no container", files: map[string]string{"content.opf": "<package/>"}},
+		{name: "container without rootfile", files: map[string]string{"META-INF/container.xml": "<container/>"}},
+		{name: "missing package document", files: map[string]string{"META-INF/container.xml": testContainer}},
+		{name: "package document is not xml", files: map[string]string{"META-INF/container.xml": testContainer, "content.opf": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openEPUB(zipOf(t, tt.files)); err == nil {
+				t.Error("openEPUB() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestEPUBFileSizeLimit(t *testing.T) {
+	data := zipOf(t, map[string]string{
+		"META-INF/container.xml": testContainer,
+		"content.opf":            "<package>" + strings.Repeat(" ", maxEPUBFileSize) + "</package>",
+	})
+
+	_, err := openEPUB(data)
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("openEPUB() error = %v, want a size error", err)
+	}
+}
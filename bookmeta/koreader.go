@@ -0,0 +1,37 @@
+package bookmeta
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+)
+
+// KOReaderHash returns the partial MD5 KOReader uses to identify a document
+// when syncing progress. It hashes 1 KiB samples taken at the start of the
+// file and at 1 KiB, 4 KiB, 16 KiB and so on up to 1 GiB, so only a few
+// ranges of a large file have to be read.
+func KOReaderHash(r io.ReaderAt, size int64) (string, error) {
+	const sampleSize = 1024
+
+	hash := md5.New()
+	sample := make([]byte, sampleSize)
+	for i := -1; i <= 10; i++ {
+		// KOReader shifts by -2 for the first sample, which LuaJIT turns
+		// into offset 0.
+		var offset int64
+		if i >= 0 {
+			offset = sampleSize << (2 * i)
+		}
+		if offset >= size {
+			break
+		}
+
+		n, err := r.ReadAt(sample[:min(sampleSize, size-offset)], offset)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		hash.Write(sample[:n])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,809 @@
+package bookmeta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+)
+
+// This is a deliberately small PDF reader: it scans the file for indirect
+// objects instead of trusting the xref table, which also copes with files that
+// were truncated or rewritten by sloppy tools.
+
+type pdfName string
+
+type pdfRef struct {
+	Num int
+	Gen int
+}
+
+type pdfDict map[pdfName]any
+
+type pdfArray []any
+
+type pdfStream struct {
+	Dict pdfDict
+	Raw  []byte
+}
+
+type pdfDocument struct {
+	data    []byte
+	objects map[int]any
+	trailer pdfDict
+}
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+const maxPDFDepth = 64
+
+// Limits on what a single stream may decode to. Real documents stay far below
+// them; they only stop crafted files from exhausting memory.
+const (
+	maxPDFStreamSize   = 64 << 20
+	maxPredictorColors = 32
+	maxPredictorColumn = 1 << 20
+)
+
+var errPDFStreamTooLarge = errors.New("pdf stream is too large")
+
+func openPDF(data []byte) (*pdfDocument, error) {
+	doc := &pdfDocument{data: data, objects: map[int]any{}}
+
+	for _, loc := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		if loc[0] > 0 && isPDFRegular(data[loc[0]-1]) {
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[loc[2]:loc[3]]))
+		p := &pdfParser{data: data, pos: loc[1]}
+		obj, err := p.parseObject(0)
+		if err != nil {
+			continue
+		}
+		doc.objects[num] = obj
+	}
+
+	// Objects can also live compressed inside object streams.
+	for _, obj := range doc.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.Dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		doc.loadObjectStream(stream)
+	}
+
+	for _, loc := range regexp.MustCompile(`trailer\s*<<`).FindAllIndex(data, -1) {
+		p := &pdfParser{data: data, pos: loc[0] + len("trailer")}
+		if obj, err := p.parseObject(0); err == nil {
+			if dict, ok := obj.(pdfDict); ok {
+				doc.trailer = mergeTrailer(doc.trailer, dict)
+			}
+		}
+	}
+	for _, obj := range doc.objects {
+		if stream, ok := obj.(*pdfStream); ok && stream.Dict["Type"] == pdfName("XRef") {
+			doc.trailer = mergeTrailer(doc.trailer, stream.Dict)
+		}
+	}
+
+	if len(doc.objects) == 0 {
+		return nil, errors.New("no pdf objects found")
+	}
+
+	return doc, nil
+}
+
+func mergeTrailer(dst, src pdfDict) pdfDict {
+	if dst == nil {
+		dst = pdfDict{}
+	}
+	for k, v := range src {
+		if _, ok := dst[k]; !ok || k == "Root" || k == "Info" {
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+func (d *pdfDocument) loadObjectStream(stream *pdfStream) {
+	data, err := d.decodeStream(stream)
+	if err != nil {
+		return
+	}
+
+	n := toInt(d.resolve(stream.Dict["N"]))
+	first := toInt(d.resolve(stream.Dict["First"]))
+	if first <= 0 || first > len(data) {
+		return
+	}
+
+	header := &pdfParser{data: data[:first]}
+	for i := 0; i < n; i++ {
+		numObj, err := header.parseObject(0)
+		if err != nil {
+			return
+		}
+		offsetObj, err := header.parseObject(0)
+		if err != nil {
+			return
+		}
+
+		num, offset := toInt(numObj), toInt(offsetObj)
+		if _, exists := d.objects[num]; exists || offset < 0 || first+offset < 0 || first+offset >= len(data) {
+			continue
+		}
+
+		p := &pdfParser{data: data, pos: first + offset}
+		if obj, err := p.parseObject(0); err == nil {
+			d.objects[num] = obj
+		}
+	}
+}
+
+// resolve follows indirect references until it reaches a direct object.
+func (d *pdfDocument) resolve(obj any) any {
+	for i := 0; i < maxPDFDepth; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.Num]
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(obj any) pdfDict {
+	switch v := d.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.Dict
+	}
+	return nil
+}
+
+func (d *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	data, imageFilter, err := d.decodeStreamFilters(stream)
+	if err != nil {
+		return nil, err
+	}
+	if imageFilter != "" {
+		return nil, errors.New("unsupported pdf stream filter")
+	}
+	return data, nil
+}
+
+// decodeStreamFilters applies the generic filters of a stream. Image codecs
+// such as DCTDecode are not applied; the first one found is returned instead so
+// that callers can hand the data to an image decoder.
+func (d *pdfDocument) decodeStreamFilters(stream *pdfStream) ([]byte, pdfName, error) {
+	data := stream.Raw
+	var filters []any
+	switch f := d.resolve(stream.Dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case pdfArray:
+		filters = f
+	}
+
+	var params []any
+	switch p := d.resolve(stream.Dict["DecodeParms"]).(type) {
+	case pdfDict:
+		params = []any{p}
+	case pdfArray:
+		params = p
+	}
+
+	for i, f := range filters {
+		switch name, _ := d.resolve(f).(pdfName); name {
+		case "FlateDecode", "Fl":
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, "", err
+			}
+			// Truncated streams are common; keep whatever was inflated.
+			decoded, err := io.ReadAll(io.LimitReader(r, maxPDFStreamSize+1))
+			if len(decoded) > maxPDFStreamSize {
+				return nil, "", errPDFStreamTooLarge
+			}
+			if err != nil && len(decoded) == 0 {
+				return nil, "", err
+			}
+			data = decoded
+
+			if i < len(params) {
+				if data, err = d.unpredict(data, d.dict(params[i])); err != nil {
+					return nil, "", err
+				}
+			}
+		default:
+			return data, name, nil
+		}
+	}
+
+	return data, "", nil
+}
+
+// unpredict reverses the PNG predictors that may be applied before Flate
+// compression. Parameters outside what the spec allows are rejected, as they
+// would size the row buffers.
+func (d *pdfDocument) unpredict(data []byte, params pdfDict) ([]byte, error) {
+	predictor := toInt(d.resolve(params["Predictor"]))
+	if predictor < 10 {
+		return data, nil
+	}
+
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := params["Colors"]; ok {
+		colors = toInt(d.resolve(v))
+	}
+	if v, ok := params["BitsPerComponent"]; ok {
+		bpc = toInt(d.resolve(v))
+	}
+	if v, ok := params["Columns"]; ok {
+		columns = toInt(d.resolve(v))
+	}
+	switch {
+	case colors < 1 || colors > maxPredictorColors:
+		return nil, errors.New("unsupported pdf predictor colors")
+	case bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16:
+		return nil, errors.New("unsupported pdf predictor bits per component")
+	case columns < 1 || columns > maxPredictorColumn:
+		return nil, errors.New("unsupported pdf predictor columns")
+	}
+
+	bpp := max(colors*bpc/8, 1)
+	rowLen := (colors*bpc*columns + 7) / 8
+	if len(data) < rowLen+1 {
+		return nil, nil
+	}
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+
+	for len(data) >= rowLen+1 {
+		filter, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+
+		out = append(out, row...)
+		prev = row
+	}
+
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func (d *pdfDocument) root() pdfDict {
+	if root := d.dict(d.trailer["Root"]); root != nil {
+		return root
+	}
+	for _, obj := range d.objects {
+		if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			return dict
+		}
+	}
+	return nil
+}
+
+// pages walks the page tree in reading order. Inheritable attributes are
+// copied down so that every returned page carries its own /Resources.
+func (d *pdfDocument) pages() []pdfDict {
+	root := d.root()
+	if root == nil {
+		return nil
+	}
+
+	var pages []pdfDict
+	visited := map[int]bool{}
+	var walk func(node any, inherited pdfDict, depth int)
+	walk = func(node any, inherited pdfDict, depth int) {
+		if depth > maxPDFDepth {
+			return
+		}
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.Num] {
+				return
+			}
+			visited[ref.Num] = true
+		}
+
+		dict := d.dict(node)
+		if dict == nil {
+			return
+		}
+
+		attrs := pdfDict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, key := range []pdfName{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if v, ok := dict[key]; ok {
+				attrs[key] = v
+			}
+		}
+
+		kids, isTree := d.resolve(dict["Kids"]).(pdfArray)
+		if !isTree {
+			page := pdfDict{}
+			for k, v := range attrs {
+				page[k] = v
+			}
+			for k, v := range dict {
+				page[k] = v
+			}
+			pages = append(pages, page)
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, attrs, depth+1)
+		}
+	}
+	walk(root["Pages"], nil, 0)
+
+	return pages
+}
+
+func (d *pdfDocument) pageCount() int {
+	if root := d.root(); root != nil {
+		if count := toInt(d.resolve(d.dict(root["Pages"])["Count"])); count > 0 {
+			return count
+		}
+	}
+
+	count := 0
+	for _, obj := range d.objects {
+		if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *pdfDocument) infoString(key pdfName) string {
+	info := d.dict(d.trailer["Info"])
+	if info == nil {
+		return ""
+	}
+	s, _ := d.resolve(info[key]).(string)
+	return cleanText(decodePDFTextString(s))
+}
+
+func extractPDF(data []byte) (*Metadata, error) {
+	doc, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Metadata{
+		Format: FormatPDF,
+		Title:  doc.infoString("Title"),
+		Author: doc.infoString("Author"),
+		Pages:  doc.pageCount(),
+	}
+	if root := doc.root(); root != nil {
+		if lang, ok := doc.resolve(root["Lang"]).(string); ok {
+			meta.Language = cleanText(decodePDFTextString(lang))
+		}
+	}
+
+	return meta, nil
+}
+
+// decodePDFTextString handles the two encodings allowed for text strings:
+// UTF-16BE with a byte order mark, or PDFDocEncoding which for our purposes is
+// close enough to Latin-1.
+func decodePDFTextString(s string) string {
+	b := []byte(s)
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+		return string(b[3:])
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func toInt(obj any) int {
+	switch v := obj.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+var errPDFSyntax = errors.New("pdf syntax error")
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isPDFRegular(c byte) bool {
+	return !isPDFWhitespace(c) && !isPDFDelimiter(c)
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if isPDFWhitespace(c) {
+			p.pos++
+			continue
+		}
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+func (p *pdfParser) readKeyword() string {
+	start := p.pos
+	for p.pos < len(p.data) && isPDFRegular(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *pdfParser) parseObject(depth int) (any, error) {
+	if depth > maxPDFDepth {
+		return nil, errPDFSyntax
+	}
+
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.EOF
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return pdfName(decodeNameEscapes(p.readKeyword())), nil
+	case c == '(':
+		return p.parseLiteralString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict, err := p.parseDict(depth)
+		if err != nil {
+			return nil, err
+		}
+		return p.maybeStream(dict), nil
+	case c == '<':
+		return p.parseHexString()
+	case c == '[':
+		p.pos++
+		var arr pdfArray
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return nil, errPDFSyntax
+			}
+			if p.data[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			obj, err := p.parseObject(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, obj)
+		}
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumberOrRef()
+	default:
+		kw := p.readKeyword()
+		switch kw {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		case "":
+			p.pos++
+			return nil, errPDFSyntax
+		}
+		return keyword(kw), nil
+	}
+}
+
+// keyword is a bare token such as an operator in a content stream.
+type keyword string
+
+func (p *pdfParser) parseDict(depth int) (pdfDict, error) {
+	dict := pdfDict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 >= len(p.data) {
+			return nil, errPDFSyntax
+		}
+		if p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return dict, nil
+		}
+
+		key, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			return nil, errPDFSyntax
+		}
+		value, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[name] = value
+	}
+}
+
+func (p *pdfParser) maybeStream(dict pdfDict) any {
+	save := p.pos
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		p.pos = save
+		return dict
+	}
+
+	p.pos += len("stream")
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+
+	start := p.pos
+	if length, ok := dict["Length"].(int); ok && length >= 0 && start+length <= len(p.data) {
+		rest := p.data[start+length:]
+		trimmed := bytes.TrimLeft(rest, "\r\n \t")
+		if bytes.HasPrefix(trimmed, []byte("endstream")) {
+			p.pos = start + length
+			return &pdfStream{Dict: dict, Raw: p.data[start : start+length]}
+		}
+	}
+
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		p.pos = len(p.data)
+		return &pdfStream{Dict: dict, Raw: p.data[start:]}
+	}
+	raw := bytes.TrimRight(p.data[start:start+end], "\r\n")
+	p.pos = start + end
+	return &pdfStream{Dict: dict, Raw: raw}
+}
+
+func (p *pdfParser) parseNumberOrRef() (any, error) {
+	num, isInt, err := p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	if !isInt {
+		return num, nil
+	}
+
+	// Look ahead for "<gen> R".
+	save := p.pos
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos > start {
+		gen, _ := strconv.Atoi(string(p.data[start:p.pos]))
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == 'R' && (p.pos+1 == len(p.data) || !isPDFRegular(p.data[p.pos+1])) {
+			p.pos++
+			return pdfRef{Num: int(num), Gen: gen}, nil
+		}
+	}
+	p.pos = save
+
+	return int(num), nil
+}
+
+func (p *pdfParser) parseNumber() (float64, bool, error) {
+	start := p.pos
+	isInt := true
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '.' {
+			isInt = false
+		} else if !(c >= '0' && c <= '9') && !(p.pos == start && (c == '+' || c == '-')) {
+			break
+		}
+		p.pos++
+	}
+
+	text := string(p.data[start:p.pos])
+	if text == "" || text == "+" || text == "-" || text == "." {
+		return 0, false, errPDFSyntax
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false, errPDFSyntax
+	}
+	return v, isInt, nil
+}
+
+func (p *pdfParser) parseLiteralString() (any, error) {
+	p.pos++
+	var buf []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+			buf = append(buf, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(buf), nil
+			}
+			buf = append(buf, c)
+		case '\\':
+			if p.pos >= len(p.data) {
+				return string(buf), nil
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					buf = append(buf, byte(v))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf), nil
+}
+
+func (p *pdfParser) parseHexString() (any, error) {
+	p.pos++
+	var buf []byte
+	var hi byte
+	haveHi := false
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '>' {
+			break
+		}
+		v, ok := hexValue(c)
+		if !ok {
+			continue
+		}
+		if haveHi {
+			buf = append(buf, hi<<4|v)
+			haveHi = false
+		} else {
+			hi, haveHi = v, true
+		}
+	}
+	if haveHi {
+		buf = append(buf, hi<<4)
+	}
+	return string(buf), nil
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func decodeNameEscapes(name string) string {
+	if !bytes.ContainsRune([]byte(name), '#') {
+		return name
+	}
+	var buf []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			hi, ok1 := hexValue(name[i+1])
+			lo, ok2 := hexValue(name[i+2])
+			if ok1 && ok2 {
+				buf = append(buf, hi<<4|lo)
+				i += 2
+				continue
+			}
+		}
+		buf = append(buf, name[i])
+	}
+	return string(buf)
+}
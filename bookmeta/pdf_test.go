@@ -0,0 +1,161 @@
+package bookmeta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOpenPDFMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "header only", data: "%PDF-1.7\n", wantErr: true},
+		{name: "unterminated dictionary", data: "%PDF-1.7\n1 0 obj << /Type /Catalog /Pages", wantErr: true},
+		{name: "nesting too deep", data: "%PDF-1.7\n1 0 obj " + strings.Repeat("[", maxPDFDepth+2), wantErr: true},
+		{name: "reference cycle", data: "%PDF-1.7\n1 0 obj 2 0 R endobj 2 0 obj 1 0 R endobj trailer << /Root 1 0 R /Info 2 0 R >>"},
+		{name: "page tree cycle", data: "%PDF-1.7\n1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj 2 0 obj << /Type /Pages /Kids [2 0 R] >> endobj"},
+		{name: "stream without endstream", data: "%PDF-1.7\n1 0 obj << /Length 1000 >> stream\nabc"},
+		{name: "negative object stream offset", data: "%PDF-1.7\n1 0 obj << /Type /ObjStm /N 1 /First 7 /Length 13 >> stream\n2 -30 (x)\nendstream endobj trailer << /Root 2 0 R >>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := openPDF([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("openPDF() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openPDF() error = %v", err)
+			}
+			doc.pages()
+			doc.pageCount()
+			doc.infoString("Title")
+		})
+	}
+}
+
+func TestDecodePDFTextString(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{name: "pdfdoc", s: "Caf\xe9", want: "Café"},
+		{name: "utf-16be", s: "\xfe\xff\x00C\x00a\x00f\x00\xe9", want: "Café"},
+		{name: "utf-16be with odd length", s: "\xfe\xff\x00C\x00", want: "C"},
+		{name: "utf-8 with bom", s: "\xef\xbb\xbfCafé", want: "Café"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodePDFTextString(tt.s); got != tt.want {
+				t.Errorf("decodePDFTextString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnpredict(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  pdfDict
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:   "no predictor",
+			params: pdfDict{},
+			data:   []byte{1, 2, 3},
+			want:   []byte{1, 2, 3},
+		},
+		{
+			name:   "png up",
+			params: pdfDict{"Predictor": 12, "Columns": 3},
+			data:   []byte{2, 1, 2, 3, 2, 1, 1, 1},
+			want:   []byte{1, 2, 3, 2, 3, 4},
+		},
+		{
+			name:   "png sub with two colors",
+			params: pdfDict{"Predictor": 11, "Colors": 2, "Columns": 2},
+			data:   []byte{1, 1, 2, 1, 1},
+			want:   []byte{1, 2, 2, 3},
+		},
+		{
+			name:   "trailing partial row is dropped",
+			params: pdfDict{"Predictor": 12, "Columns": 3},
+			data:   []byte{0, 1, 2, 3, 0, 4},
+			want:   []byte{1, 2, 3},
+		},
+		{
+			name:   "shorter than a row",
+			params: pdfDict{"Predictor": 12, "Columns": 3},
+			data:   []byte{0, 1},
+		},
+		{name: "zero colors", params: pdfDict{"Predictor": 12, "Colors": 0}, wantErr: true},
+		{name: "too many colors", params: pdfDict{"Predictor": 12, "Colors": maxPredictorColors + 1}, wantErr: true},
+		{name: "odd bits per component", params: pdfDict{"Predictor": 12, "BitsPerComponent": 3}, wantErr: true},
+		{name: "negative columns", params: pdfDict{"Predictor": 12, "Columns": -1}, wantErr: true},
+		{name: "too many columns", params: pdfDict{"Predictor": 12, "Columns": 1 << 40}, wantErr: true},
+	}
+
+	doc := &pdfDocument{objects: map[int]any{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := doc.unpredict(tt.data, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("unpredict() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unpredict() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("unpredict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStreamLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(make([]byte, maxPDFStreamSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	doc := &pdfDocument{objects: map[int]any{}}
+	stream := &pdfStream{Dict: pdfDict{"Filter": pdfName("FlateDecode")}, Raw: buf.Bytes()}
+	if _, err := doc.decodeStream(stream); !errors.Is(err, errPDFStreamTooLarge) {
+		t.Errorf("decodeStream() error = %v, want errPDFStreamTooLarge", err)
+	}
+}
+
+func TestDecodeStreamTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(strings.Repeat("BT (Hello) Tj ET\n", 100)))
+	w.Close()
+	raw := buf.Bytes()
+
+	doc := &pdfDocument{objects: map[int]any{}}
+	stream := &pdfStream{Dict: pdfDict{"Filter": pdfName("FlateDecode")}, Raw: raw[:len(raw)-10]}
+	data, err := doc.decodeStream(stream)
+	if err != nil {
+		t.Fatalf("decodeStream() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("BT (Hello) Tj ET")) {
+		t.Errorf("decodeStream() = %q, want the inflated prefix", data)
+	}
+}
@@ -0,0 +1,1349 @@
+package bookmeta
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"strings"
+	"sync"
+
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// This is a small rasterizer for the first page of a PDF, good enough for a
+// cover thumbnail. It fills and strokes paths, draws images and shows text with
+// the embedded TrueType or OpenType program when there is one, falling back to
+// the Go fonts for everything else. Shadings, patterns, blend modes and dash
+// patterns are ignored.
+
+// pdfRenderWidth is the width pages are rendered at, which is enough for the
+// largest thumbnail.
+const pdfRenderWidth = 640
+
+// Limits on the work a single page may cause. Every operator and every painted
+// pixel costs one unit of the budget; once it runs out the page is returned as
+// drawn so far.
+const (
+	maxRenderWork      = 64_000_000
+	maxRenderFormDepth = 8
+	maxRenderStack     = 256
+	maxRenderWidths    = 1 << 17
+	maxRenderCoord     = 1e6
+)
+
+var errPageNotRenderable = errors.New("pdf page cannot be rendered")
+
+// pdfMatrix is an affine transform [a b c d e f] as used by the cm operator.
+type pdfMatrix [6]float64
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the transform that applies m first and then n.
+func (m pdfMatrix) mul(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m pdfMatrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+func (m pdfMatrix) invert() (pdfMatrix, bool) {
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return pdfMatrix{}, false
+	}
+	return pdfMatrix{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}, true
+}
+
+// scale is the factor by which m scales lengths, on average.
+func (m pdfMatrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+func matrixOf(operands []any) (pdfMatrix, bool) {
+	if len(operands) < 6 {
+		return pdfMatrix{}, false
+	}
+	var m pdfMatrix
+	for i, v := range operands[len(operands)-6:] {
+		m[i] = toNumber(v)
+	}
+	return m, true
+}
+
+type point struct{ X, Y float64 }
+
+// pathSegment is one step of a path in device space. Op is one of 'M', 'L',
+// 'Q', 'C' and 'Z'; only the first one, two or three points are used.
+type pathSegment struct {
+	Op  byte
+	Pts [3]point
+}
+
+type renderState struct {
+	ctm         pdfMatrix
+	fill        color.NRGBA
+	stroke      color.NRGBA
+	fillAlpha   uint8
+	strokeAlpha uint8
+	fillSpace   *colorSpace
+	strokeSpace *colorSpace
+	lineWidth   float64
+	clip        *image.Alpha
+
+	font      *renderFont
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	hScale    float64
+	leading   float64
+	rise      float64
+	textMode  int
+}
+
+type pageRenderer struct {
+	doc    *pdfDocument
+	dst    *image.RGBA
+	raster vector.Rasterizer
+	glyphs sfnt.Buffer
+	fonts  map[any]*renderFont
+	images map[*pdfStream]image.Image
+	work   int
+
+	state renderState
+	stack []renderState
+
+	path          []pathSegment
+	current       point
+	start         point
+	pendingClip   bool
+	textMatrix    pdfMatrix
+	textLineStart pdfMatrix
+}
+
+// renderPage draws page onto a white canvas width pixels wide.
+func (d *pdfDocument) renderPage(page pdfDict, width int) (*image.RGBA, error) {
+	box := d.pageBox(page)
+	boxWidth, boxHeight := box[2]-box[0], box[3]-box[1]
+	if !(boxWidth > 0 && boxHeight > 0) {
+		return nil, errPageNotRenderable
+	}
+
+	rotate := toInt(d.resolve(page["Rotate"])) % 360
+	if rotate < 0 {
+		rotate += 360
+	}
+	shownWidth, shownHeight := boxWidth, boxHeight
+	if rotate == 90 || rotate == 270 {
+		shownWidth, shownHeight = boxHeight, boxWidth
+	}
+
+	s := float64(width) / shownWidth
+	height := int(math.Round(shownHeight * s))
+	if height > maxCoverPixels/width {
+		return nil, errCoverTooLarge
+	}
+	if err := checkCoverSize(width, height); err != nil {
+		return nil, err
+	}
+
+	// Default user space has its origin at the bottom left of the box and y
+	// pointing up; the device has it at the top left with y pointing down.
+	var device pdfMatrix
+	switch rotate {
+	case 90:
+		device = pdfMatrix{0, s, s, 0, 0, 0}
+	case 180:
+		device = pdfMatrix{-s, 0, 0, s, boxWidth * s, 0}
+	case 270:
+		device = pdfMatrix{0, -s, -s, 0, boxHeight * s, boxWidth * s}
+	default:
+		device = pdfMatrix{s, 0, 0, -s, 0, boxHeight * s}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+
+	r := &pageRenderer{
+		doc:    d,
+		dst:    dst,
+		fonts:  map[any]*renderFont{},
+		images: map[*pdfStream]image.Image{},
+		state: renderState{
+			ctm:         pdfMatrix{1, 0, 0, 1, -box[0], -box[1]}.mul(device),
+			fill:        color.NRGBA{A: 0xFF},
+			stroke:      color.NRGBA{A: 0xFF},
+			fillSpace:   deviceGray,
+			fillAlpha:   0xFF,
+			strokeAlpha: 0xFF,
+			strokeSpace: deviceGray,
+			lineWidth:   1,
+			hScale:      1,
+		},
+	}
+	r.run(d.pageContents(page), d.dict(page["Resources"]), 0)
+
+	return dst, nil
+}
+
+// pageBox returns the visible area of a page as [x0 y0 x1 y1].
+func (d *pdfDocument) pageBox(page pdfDict) [4]float64 {
+	for _, key := range []pdfName{"CropBox", "MediaBox"} {
+		arr, ok := d.resolve(page[key]).(pdfArray)
+		if !ok || len(arr) != 4 {
+			continue
+		}
+		var box [4]float64
+		for i, v := range arr {
+			box[i] = toNumber(d.resolve(v))
+		}
+		box[0], box[2] = min(box[0], box[2]), max(box[0], box[2])
+		box[1], box[3] = min(box[1], box[3]), max(box[1], box[3])
+		if box[2]-box[0] > 0 && box[3]-box[1] > 0 {
+			return box
+		}
+	}
+	// US Letter is the default of most producers.
+	return [4]float64{0, 0, 612, 792}
+}
+
+func (r *pageRenderer) run(content []byte, resources pdfDict, depth int) {
+	p := &pdfParser{data: content}
+	var operands []any
+
+	for r.work < maxRenderWork {
+		obj, err := p.parseObject(0)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			operands = operands[:0]
+			continue
+		}
+
+		op, isOp := obj.(keyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+		r.work++
+
+		switch op {
+		case "BI":
+			// Inline images are skipped, like in pageText.
+			end := bytes.Index(p.data[p.pos:], []byte("EI"))
+			if end < 0 {
+				p.pos = len(p.data)
+			} else {
+				p.pos += end + 2
+			}
+
+		// Graphics state.
+		case "q":
+			if len(r.stack) < maxRenderStack {
+				r.stack = append(r.stack, r.state)
+			}
+		case "Q":
+			if n := len(r.stack); n > 0 {
+				r.state, r.stack = r.stack[n-1], r.stack[:n-1]
+			}
+		case "cm":
+			if m, ok := matrixOf(operands); ok {
+				r.state.ctm = m.mul(r.state.ctm)
+			}
+		case "w":
+			if len(operands) >= 1 {
+				r.state.lineWidth = toNumber(operands[0])
+			}
+		case "gs":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					r.setExtGState(r.doc.dict(r.doc.dict(resources["ExtGState"])[name]))
+				}
+			}
+
+		// Colors.
+		case "g", "rg", "k":
+			r.state.fillSpace = deviceSpaces[strings.ToLower(string(op))]
+			r.state.fill = r.state.fillSpace.color(operands)
+		case "G", "RG", "K":
+			r.state.strokeSpace = deviceSpaces[strings.ToLower(string(op))]
+			r.state.stroke = r.state.strokeSpace.color(operands)
+		case "cs", "CS":
+			if len(operands) < 1 {
+				break
+			}
+			space := r.doc.colorSpace(operands[0], r.doc.dict(resources["ColorSpace"]))
+			if op == "cs" {
+				r.state.fillSpace, r.state.fill = space, space.initial()
+			} else {
+				r.state.strokeSpace, r.state.stroke = space, space.initial()
+			}
+		case "sc", "scn":
+			r.state.fill = r.state.fillSpace.color(operands)
+		case "SC", "SCN":
+			r.state.stroke = r.state.strokeSpace.color(operands)
+
+		// Path construction.
+		case "m":
+			if len(operands) >= 2 {
+				r.moveTo(toNumber(operands[0]), toNumber(operands[1]))
+			}
+		case "l":
+			if len(operands) >= 2 {
+				r.lineTo(toNumber(operands[0]), toNumber(operands[1]))
+			}
+		case "c":
+			if len(operands) >= 6 {
+				r.curveTo(r.point(operands[0], operands[1]), r.point(operands[2], operands[3]), r.point(operands[4], operands[5]))
+			}
+		case "v":
+			if len(operands) >= 4 {
+				r.curveTo(r.current, r.point(operands[0], operands[1]), r.point(operands[2], operands[3]))
+			}
+		case "y":
+			if len(operands) >= 4 {
+				end := r.point(operands[2], operands[3])
+				r.curveTo(r.point(operands[0], operands[1]), end, end)
+			}
+		case "h":
+			r.closePath()
+		case "re":
+			if len(operands) >= 4 {
+				x, y, w, h := toNumber(operands[0]), toNumber(operands[1]), toNumber(operands[2]), toNumber(operands[3])
+				r.moveTo(x, y)
+				r.lineTo(x+w, y)
+				r.lineTo(x+w, y+h)
+				r.lineTo(x, y+h)
+				r.closePath()
+			}
+
+		// Path painting. Even-odd fills are drawn with the non-zero rule.
+		case "f", "F", "f*":
+			r.fillPath(r.path, r.fillColor())
+			r.endPath()
+		case "S":
+			r.strokePath()
+			r.endPath()
+		case "s":
+			r.closePath()
+			r.strokePath()
+			r.endPath()
+		case "B", "B*":
+			r.fillPath(r.path, r.fillColor())
+			r.strokePath()
+			r.endPath()
+		case "b", "b*":
+			r.closePath()
+			r.fillPath(r.path, r.fillColor())
+			r.strokePath()
+			r.endPath()
+		case "n":
+			r.endPath()
+		case "W", "W*":
+			r.pendingClip = true
+
+		// Text.
+		case "BT":
+			r.textMatrix, r.textLineStart = identityMatrix, identityMatrix
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					r.state.font = r.font(r.doc.dict(resources["Font"])[name], name)
+				}
+				r.state.fontSize = toNumber(operands[1])
+			}
+		case "Tc":
+			if len(operands) >= 1 {
+				r.state.charSpace = toNumber(operands[0])
+			}
+		case "Tw":
+			if len(operands) >= 1 {
+				r.state.wordSpace = toNumber(operands[0])
+			}
+		case "Tz":
+			if len(operands) >= 1 {
+				r.state.hScale = toNumber(operands[0]) / 100
+			}
+		case "TL":
+			if len(operands) >= 1 {
+				r.state.leading = toNumber(operands[0])
+			}
+		case "Ts":
+			if len(operands) >= 1 {
+				r.state.rise = toNumber(operands[0])
+			}
+		case "Tr":
+			if len(operands) >= 1 {
+				r.state.textMode = toInt(operands[0])
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, ty := toNumber(operands[0]), toNumber(operands[1])
+				if op == "TD" {
+					r.state.leading = -ty
+				}
+				r.textLineStart = pdfMatrix{1, 0, 0, 1, tx, ty}.mul(r.textLineStart)
+				r.textMatrix = r.textLineStart
+			}
+		case "Tm":
+			if m, ok := matrixOf(operands); ok {
+				r.textMatrix, r.textLineStart = m, m
+			}
+		case "T*":
+			r.nextLine()
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(string); ok {
+					r.showText(s)
+				}
+			}
+		case "'", "\"":
+			if op == "\"" && len(operands) >= 3 {
+				r.state.wordSpace, r.state.charSpace = toNumber(operands[0]), toNumber(operands[1])
+			}
+			r.nextLine()
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(string); ok {
+					r.showText(s)
+				}
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			arr, _ := operands[len(operands)-1].(pdfArray)
+			for _, item := range arr {
+				switch v := item.(type) {
+				case string:
+					r.showText(v)
+				case int, float64:
+					tx := -toNumber(v) / 1000 * r.state.fontSize * r.state.hScale
+					r.textMatrix = pdfMatrix{1, 0, 0, 1, tx, 0}.mul(r.textMatrix)
+				}
+			}
+
+		// External objects.
+		case "Do":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					r.drawXObject(r.doc.dict(resources["XObject"])[name], resources, depth)
+				}
+			}
+		}
+
+		operands = operands[:0]
+	}
+}
+
+func (r *pageRenderer) setExtGState(gs pdfDict) {
+	if gs == nil {
+		return
+	}
+	if v, ok := gs["LW"]; ok {
+		r.state.lineWidth = toNumber(r.doc.resolve(v))
+	}
+	if v, ok := gs["ca"]; ok {
+		r.state.fillAlpha = channel(toNumber(r.doc.resolve(v)))
+	}
+	if v, ok := gs["CA"]; ok {
+		r.state.strokeAlpha = channel(toNumber(r.doc.resolve(v)))
+	}
+}
+
+// fillColor is the current fill color with the constant alpha of the graphics
+// state applied.
+func (r *pageRenderer) fillColor() color.NRGBA {
+	c := r.state.fill
+	c.A = uint8(uint32(c.A) * uint32(r.state.fillAlpha) / 0xFF)
+	return c
+}
+
+func (r *pageRenderer) strokeColor() color.NRGBA {
+	c := r.state.stroke
+	c.A = uint8(uint32(c.A) * uint32(r.state.strokeAlpha) / 0xFF)
+	return c
+}
+
+// point transforms a user space coordinate pair to device space.
+func (r *pageRenderer) point(x, y any) point {
+	dx, dy := r.state.ctm.apply(toNumber(x), toNumber(y))
+	return point{clampCoord(dx), clampCoord(dy)}
+}
+
+// clampCoord keeps absurd coordinates from crafted files within a range the
+// rasterizer handles.
+func clampCoord(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return max(-maxRenderCoord, min(maxRenderCoord, v))
+}
+
+func (r *pageRenderer) moveTo(x, y float64) {
+	r.current = r.point(x, y)
+	r.start = r.current
+	r.path = append(r.path, pathSegment{Op: 'M', Pts: [3]point{r.current}})
+}
+
+func (r *pageRenderer) lineTo(x, y float64) {
+	r.current = r.point(x, y)
+	r.path = append(r.path, pathSegment{Op: 'L', Pts: [3]point{r.current}})
+}
+
+func (r *pageRenderer) curveTo(c1, c2, end point) {
+	r.current = end
+	r.path = append(r.path, pathSegment{Op: 'C', Pts: [3]point{c1, c2, end}})
+}
+
+func (r *pageRenderer) closePath() {
+	if len(r.path) > 0 {
+		r.path = append(r.path, pathSegment{Op: 'Z'})
+		r.current = r.start
+	}
+}
+
+// endPath finishes a painting operator, applying a clip set by W or W* on the
+// way.
+func (r *pageRenderer) endPath() {
+	if r.pendingClip {
+		r.clipPath(r.path)
+		r.pendingClip = false
+	}
+	r.path = r.path[:0]
+}
+
+// pathBounds returns the device pixels a path may touch, limited to the page.
+func (r *pageRenderer) pathBounds(path []pathSegment) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, seg := range path {
+		n := 0
+		switch seg.Op {
+		case 'M', 'L':
+			n = 1
+		case 'Q':
+			n = 2
+		case 'C':
+			n = 3
+		}
+		for _, p := range seg.Pts[:n] {
+			minX, minY = min(minX, p.X), min(minY, p.Y)
+			maxX, maxY = max(maxX, p.X), max(maxY, p.Y)
+		}
+	}
+	if minX > maxX {
+		return image.Rectangle{}
+	}
+	rect := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1)
+	return rect.Intersect(r.dst.Bounds())
+}
+
+// coverage rasterizes path within rect and returns how much of each pixel it
+// covers, with the mask's origin at rect.Min.
+func (r *pageRenderer) coverage(path []pathSegment, rect image.Rectangle) *image.Alpha {
+	r.work += rect.Dx() * rect.Dy()
+	r.raster.Reset(rect.Dx(), rect.Dy())
+	ox, oy := float64(rect.Min.X), float64(rect.Min.Y)
+	at := func(p point) (float32, float32) { return float32(p.X - ox), float32(p.Y - oy) }
+
+	open := false
+	for _, seg := range path {
+		switch seg.Op {
+		case 'M':
+			if open {
+				r.raster.ClosePath()
+			}
+			r.raster.MoveTo(at(seg.Pts[0]))
+			open = true
+		case 'L':
+			if open {
+				r.raster.LineTo(at(seg.Pts[0]))
+			}
+		case 'Q':
+			if open {
+				bx, by := at(seg.Pts[0])
+				cx, cy := at(seg.Pts[1])
+				r.raster.QuadTo(bx, by, cx, cy)
+			}
+		case 'C':
+			if open {
+				bx, by := at(seg.Pts[0])
+				cx, cy := at(seg.Pts[1])
+				dx, dy := at(seg.Pts[2])
+				r.raster.CubeTo(bx, by, cx, cy, dx, dy)
+			}
+		case 'Z':
+			if open {
+				r.raster.ClosePath()
+				open = false
+			}
+		}
+	}
+	if open {
+		r.raster.ClosePath()
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	r.raster.DrawOp = draw.Src
+	r.raster.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask
+}
+
+func (r *pageRenderer) fillPath(path []pathSegment, c color.NRGBA) {
+	if c.A == 0 {
+		return
+	}
+	rect := r.pathBounds(path)
+	if rect.Empty() {
+		return
+	}
+	mask := r.coverage(path, rect)
+	r.composite(rect, func(x, y int) (color.NRGBA, uint8) {
+		return c, mask.Pix[(y-rect.Min.Y)*mask.Stride+x-rect.Min.X]
+	})
+}
+
+// composite blends the colors returned by src over every pixel of rect,
+// weighted by the coverage src returns and the current clip.
+func (r *pageRenderer) composite(rect image.Rectangle, src func(x, y int) (color.NRGBA, uint8)) {
+	clip := r.state.clip
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c, cover := src(x, y)
+			a := uint32(cover) * uint32(c.A) / 0xFF
+			if clip != nil {
+				a = a * uint32(clip.Pix[clip.PixOffset(x, y)]) / 0xFF
+			}
+			if a == 0 {
+				continue
+			}
+			i := r.dst.PixOffset(x, y)
+			pix := r.dst.Pix[i : i+3 : i+3]
+			pix[0] = uint8((uint32(c.R)*a + uint32(pix[0])*(0xFF-a)) / 0xFF)
+			pix[1] = uint8((uint32(c.G)*a + uint32(pix[1])*(0xFF-a)) / 0xFF)
+			pix[2] = uint8((uint32(c.B)*a + uint32(pix[2])*(0xFF-a)) / 0xFF)
+		}
+	}
+}
+
+// strokePath outlines the current path by filling a quadrilateral along every
+// flattened segment, with round joins and caps for lines wider than a pixel.
+func (r *pageRenderer) strokePath() {
+	c := r.strokeColor()
+	if c.A == 0 {
+		return
+	}
+	half := max(r.state.lineWidth*r.state.ctm.scale(), 0.75) / 2
+	if math.IsNaN(half) || half > maxRenderCoord {
+		return
+	}
+
+	var outline []pathSegment
+	for _, poly := range flattenPath(r.path) {
+		for i := 1; i < len(poly); i++ {
+			a, b := poly[i-1], poly[i]
+			dx, dy := b.X-a.X, b.Y-a.Y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			nx, ny := -dy/length*half, dx/length*half
+			outline = append(outline,
+				pathSegment{Op: 'M', Pts: [3]point{{a.X + nx, a.Y + ny}}},
+				pathSegment{Op: 'L', Pts: [3]point{{b.X + nx, b.Y + ny}}},
+				pathSegment{Op: 'L', Pts: [3]point{{b.X - nx, b.Y - ny}}},
+				pathSegment{Op: 'L', Pts: [3]point{{a.X - nx, a.Y - ny}}},
+				pathSegment{Op: 'Z'},
+			)
+		}
+		if half < 1 {
+			continue
+		}
+		for _, p := range poly {
+			// Wound the same way as the quadrilaterals so that overlaps add up
+			// instead of cancelling out.
+			for k := 0; k < 8; k++ {
+				angle := -float64(k) * math.Pi / 4
+				op := byte('L')
+				if k == 0 {
+					op = 'M'
+				}
+				outline = append(outline, pathSegment{Op: op, Pts: [3]point{{p.X + half*math.Cos(angle), p.Y + half*math.Sin(angle)}}})
+			}
+			outline = append(outline, pathSegment{Op: 'Z'})
+		}
+	}
+
+	r.fillPath(outline, c)
+}
+
+// flattenPath turns a path into polylines, one per subpath, approximating
+// curves with straight segments about two pixels long.
+func flattenPath(path []pathSegment) [][]point {
+	var polys [][]point
+	var poly []point
+	for _, seg := range path {
+		switch seg.Op {
+		case 'M':
+			if len(poly) > 1 {
+				polys = append(polys, poly)
+			}
+			poly = []point{seg.Pts[0]}
+		case 'L':
+			if len(poly) > 0 {
+				poly = append(poly, seg.Pts[0])
+			}
+		case 'C':
+			if len(poly) == 0 {
+				continue
+			}
+			p0 := poly[len(poly)-1]
+			c1, c2, p3 := seg.Pts[0], seg.Pts[1], seg.Pts[2]
+			length := math.Hypot(c1.X-p0.X, c1.Y-p0.Y) + math.Hypot(c2.X-c1.X, c2.Y-c1.Y) + math.Hypot(p3.X-c2.X, p3.Y-c2.Y)
+			steps := int(max(1, min(64, length/2)))
+			for i := 1; i <= steps; i++ {
+				t := float64(i) / float64(steps)
+				u := 1 - t
+				poly = append(poly, point{
+					u*u*u*p0.X + 3*u*u*t*c1.X + 3*u*t*t*c2.X + t*t*t*p3.X,
+					u*u*u*p0.Y + 3*u*u*t*c1.Y + 3*u*t*t*c2.Y + t*t*t*p3.Y,
+				})
+			}
+		case 'Z':
+			if len(poly) > 1 {
+				polys = append(polys, append(poly, poly[0]))
+			}
+			if len(poly) > 0 {
+				poly = []point{poly[0]}
+			}
+		}
+	}
+	if len(poly) > 1 {
+		polys = append(polys, poly)
+	}
+	return polys
+}
+
+// clipPath intersects the current clip with path.
+func (r *pageRenderer) clipPath(path []pathSegment) {
+	clip := image.NewAlpha(r.dst.Bounds())
+	if rect := r.pathBounds(path); !rect.Empty() {
+		mask := r.coverage(path, rect)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				a := uint32(mask.Pix[(y-rect.Min.Y)*mask.Stride+x-rect.Min.X])
+				if r.state.clip != nil {
+					a = a * uint32(r.state.clip.Pix[r.state.clip.PixOffset(x, y)]) / 0xFF
+				}
+				clip.Pix[clip.PixOffset(x, y)] = uint8(a)
+			}
+		}
+	}
+	r.work += len(clip.Pix)
+	r.state.clip = clip
+}
+
+func (r *pageRenderer) nextLine() {
+	r.textLineStart = pdfMatrix{1, 0, 0, 1, 0, -r.state.leading}.mul(r.textLineStart)
+	r.textMatrix = r.textLineStart
+}
+
+// showText draws the glyphs of a shown string and advances the text matrix.
+// Invisible text, which OCR layers of scanned books use, only advances.
+func (r *pageRenderer) showText(s string) {
+	font := r.state.font
+	if font == nil {
+		return
+	}
+	st := &r.state
+	fill := r.fillColor()
+	visible := st.textMode != 3 && st.textMode != 7 && fill.A != 0
+
+	for i := 0; i+font.codeBytes <= len(s); i += font.codeBytes {
+		code := codeValue(s[i : i+font.codeBytes])
+
+		if visible {
+			if g := font.glyph(code, &r.glyphs); g != nil {
+				trm := pdfMatrix{st.fontSize * st.hScale, 0, 0, st.fontSize, 0, st.rise}.mul(r.textMatrix).mul(st.ctm)
+				glyphMatrix := pdfMatrix{g.scaleX / g.unitsPerEm, 0, 0, -1 / g.unitsPerEm, 0, 0}.mul(trm)
+				r.work += len(g.segments)
+				r.fillPath(glyphPath(g.segments, glyphMatrix), fill)
+			}
+		}
+
+		advance := font.width(code, &r.glyphs)/1000*st.fontSize + st.charSpace
+		if font.codeBytes == 1 && code == ' ' {
+			advance += st.wordSpace
+		}
+		r.textMatrix = pdfMatrix{1, 0, 0, 1, advance * st.hScale, 0}.mul(r.textMatrix)
+	}
+}
+
+// glyphPath transforms glyph outlines, whose y axis points down as sfnt
+// returns them, to device space.
+func glyphPath(segments sfnt.Segments, m pdfMatrix) []pathSegment {
+	at := func(p fixed.Point26_6) point {
+		x, y := m.apply(float64(p.X), float64(p.Y))
+		return point{clampCoord(x), clampCoord(y)}
+	}
+
+	path := make([]pathSegment, 0, len(segments)+4)
+	for _, seg := range segments {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if len(path) > 0 {
+				path = append(path, pathSegment{Op: 'Z'})
+			}
+			path = append(path, pathSegment{Op: 'M', Pts: [3]point{at(seg.Args[0])}})
+		case sfnt.SegmentOpLineTo:
+			path = append(path, pathSegment{Op: 'L', Pts: [3]point{at(seg.Args[0])}})
+		case sfnt.SegmentOpQuadTo:
+			path = append(path, pathSegment{Op: 'Q', Pts: [3]point{at(seg.Args[0]), at(seg.Args[1])}})
+		case sfnt.SegmentOpCubeTo:
+			path = append(path, pathSegment{Op: 'C', Pts: [3]point{at(seg.Args[0]), at(seg.Args[1]), at(seg.Args[2])}})
+		}
+	}
+	return append(path, pathSegment{Op: 'Z'})
+}
+
+func (r *pageRenderer) drawXObject(ref any, resources pdfDict, depth int) {
+	stream, ok := r.doc.resolve(ref).(*pdfStream)
+	if !ok {
+		return
+	}
+
+	switch r.doc.resolve(stream.Dict["Subtype"]) {
+	case pdfName("Image"):
+		r.drawImage(stream)
+	case pdfName("Form"):
+		if depth >= maxRenderFormDepth {
+			return
+		}
+		content, err := r.doc.decodeStream(stream)
+		if err != nil {
+			return
+		}
+		formResources := r.doc.dict(stream.Dict["Resources"])
+		if formResources == nil {
+			formResources = resources
+		}
+
+		saved, savedStack := r.state, len(r.stack)
+		if arr, ok := r.doc.resolve(stream.Dict["Matrix"]).(pdfArray); ok {
+			var operands []any
+			for _, v := range arr {
+				operands = append(operands, r.doc.resolve(v))
+			}
+			if m, ok := matrixOf(operands); ok {
+				r.state.ctm = m.mul(r.state.ctm)
+			}
+		}
+		r.run(content, formResources, depth+1)
+		r.state, r.stack = saved, r.stack[:savedStack]
+	}
+}
+
+// drawImage maps an image onto the unit square of the current transform.
+// Stencil masks are painted in the fill color and soft masks are honoured.
+func (r *pageRenderer) drawImage(stream *pdfStream) {
+	width := toInt(r.doc.resolve(stream.Dict["Width"]))
+	height := toInt(r.doc.resolve(stream.Dict["Height"]))
+	if checkCoverSize(width, height) != nil {
+		return
+	}
+
+	img, ok := r.images[stream]
+	if !ok {
+		r.work += width * height
+		var err error
+		if isMask, _ := r.doc.resolve(stream.Dict["ImageMask"]).(bool); isMask {
+			img, err = r.doc.decodeStencil(stream, width, height)
+		} else {
+			img, err = r.doc.decodeImage(stream, width, height)
+		}
+		if err != nil {
+			img = nil
+		}
+		r.images[stream] = img
+	}
+	if img == nil {
+		return
+	}
+
+	var softMask image.Image
+	if smask, ok := r.doc.resolve(stream.Dict["SMask"]).(*pdfStream); ok {
+		if m, err := r.doc.decodeImage(smask, toInt(r.doc.resolve(smask.Dict["Width"])), toInt(r.doc.resolve(smask.Dict["Height"]))); err == nil {
+			softMask = m
+		}
+	}
+
+	inverse, ok := r.state.ctm.invert()
+	if !ok {
+		return
+	}
+	var corners []pathSegment
+	for _, c := range []point{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		corners = append(corners, pathSegment{Op: 'L', Pts: [3]point{r.point(c.X, c.Y)}})
+	}
+	rect := r.pathBounds(corners)
+	r.work += rect.Dx() * rect.Dy()
+
+	stencil, isStencil := img.(*image.Alpha)
+	fill := r.fillColor()
+	sample := func(src image.Image, u, v float64) (int, int) {
+		b := src.Bounds()
+		x := min(int(u*float64(b.Dx())), b.Dx()-1)
+		y := min(int((1-v)*float64(b.Dy())), b.Dy()-1)
+		return b.Min.X + x, b.Min.Y + y
+	}
+
+	r.composite(rect, func(x, y int) (color.NRGBA, uint8) {
+		u, v := inverse.apply(float64(x)+0.5, float64(y)+0.5)
+		if u < 0 || u >= 1 || v < 0 || v >= 1 {
+			return color.NRGBA{}, 0
+		}
+
+		if isStencil {
+			return fill, stencil.AlphaAt(sample(stencil, u, v)).A
+		}
+
+		c := color.NRGBAModel.Convert(img.At(sample(img, u, v))).(color.NRGBA)
+		c.A = r.state.fillAlpha
+		cover := uint8(0xFF)
+		if softMask != nil {
+			cover = color.GrayModel.Convert(softMask.At(sample(softMask, u, v))).(color.Gray).Y
+		}
+		return c, cover
+	})
+}
+
+// decodeStencil decodes a one bit image mask into the pixels it paints.
+func (d *pdfDocument) decodeStencil(stream *pdfStream, width, height int) (*image.Alpha, error) {
+	data, imageFilter, err := d.decodeStreamFilters(stream)
+	if err != nil {
+		return nil, err
+	}
+	if imageFilter != "" {
+		return nil, errors.New("unsupported pdf image filter")
+	}
+
+	rowLen := (width + 7) / 8
+	if len(data) < rowLen*height {
+		return nil, errors.New("pdf image data is truncated")
+	}
+
+	// By default a 0 bit paints; a Decode array of [1 0] flips that.
+	paint := byte(0)
+	if decode, ok := d.resolve(stream.Dict["Decode"]).(pdfArray); ok && len(decode) == 2 && toNumber(d.resolve(decode[0])) == 1 {
+		paint = 1
+	}
+
+	img := image.NewAlpha(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := data[y*rowLen:]
+		for x := 0; x < width; x++ {
+			if row[x/8]>>(7-x%8)&1 == paint {
+				img.Pix[y*img.Stride+x] = 0xFF
+			}
+		}
+	}
+	return img, nil
+}
+
+// colorSpace converts color operands to RGB.
+type colorSpace struct {
+	components int
+	convert    func(values []float64) color.NRGBA
+
+	// For /Indexed spaces.
+	base   *colorSpace
+	lookup []byte
+}
+
+var (
+	deviceGray = &colorSpace{components: 1, convert: func(v []float64) color.NRGBA {
+		g := channel(v[0])
+		return color.NRGBA{g, g, g, 0xFF}
+	}}
+	deviceRGB = &colorSpace{components: 3, convert: func(v []float64) color.NRGBA {
+		return color.NRGBA{channel(v[0]), channel(v[1]), channel(v[2]), 0xFF}
+	}}
+	deviceCMYK = &colorSpace{components: 4, convert: func(v []float64) color.NRGBA {
+		k := 1 - v[3]
+		return color.NRGBA{channel((1 - v[0]) * k), channel((1 - v[1]) * k), channel((1 - v[2]) * k), 0xFF}
+	}}
+	// Separation and DeviceN tints are drawn as gray ink.
+	tintSpace = &colorSpace{components: 1, convert: func(v []float64) color.NRGBA {
+		g := channel(1 - v[0])
+		return color.NRGBA{g, g, g, 0xFF}
+	}}
+	// Patterns are not painted.
+	patternSpace = &colorSpace{}
+
+	deviceSpaces = map[string]*colorSpace{"g": deviceGray, "rg": deviceRGB, "k": deviceCMYK}
+)
+
+func channel(v float64) uint8 {
+	return uint8(math.Round(max(0, min(1, v)) * 0xFF))
+}
+
+func (d *pdfDocument) colorSpace(obj any, resources pdfDict) *colorSpace {
+	if name, ok := obj.(pdfName); ok {
+		if space, ok := resources[name]; ok {
+			obj = space
+		}
+	}
+	obj = d.resolve(obj)
+
+	name, _ := obj.(pdfName)
+	arr, isArray := obj.(pdfArray)
+	if isArray && len(arr) > 0 {
+		name, _ = d.resolve(arr[0]).(pdfName)
+	}
+
+	switch name {
+	case "DeviceGray", "G", "CalGray":
+		return deviceGray
+	case "DeviceRGB", "RGB", "CalRGB", "Lab":
+		return deviceRGB
+	case "DeviceCMYK", "CMYK":
+		return deviceCMYK
+	case "Separation", "DeviceN":
+		return tintSpace
+	case "ICCBased":
+		if isArray && len(arr) == 2 {
+			switch toInt(d.resolve(d.dict(arr[1])["N"])) {
+			case 1:
+				return deviceGray
+			case 4:
+				return deviceCMYK
+			}
+		}
+		return deviceRGB
+	case "Indexed", "I":
+		if !isArray || len(arr) != 4 {
+			break
+		}
+		// The base may not be indexed itself, which also stops reference
+		// cycles.
+		baseObj := d.resolve(arr[1])
+		if baseArr, ok := baseObj.(pdfArray); ok && len(baseArr) > 0 {
+			if name, _ := d.resolve(baseArr[0]).(pdfName); name == "Indexed" || name == "I" {
+				break
+			}
+		}
+		base := d.colorSpace(baseObj, nil)
+		if base.components == 0 {
+			break
+		}
+		var lookup []byte
+		switch v := d.resolve(arr[3]).(type) {
+		case string:
+			lookup = []byte(v)
+		case *pdfStream:
+			lookup, _ = d.decodeStream(v)
+		}
+		return &colorSpace{components: 1, base: base, lookup: lookup}
+	}
+	return patternSpace
+}
+
+// initial is the color a space starts out with when selected by cs or CS,
+// which is black for everything but indexed spaces.
+func (cs *colorSpace) initial() color.NRGBA {
+	switch {
+	case cs.components == 0:
+		return color.NRGBA{}
+	case cs.base != nil:
+		return cs.color([]any{0})
+	}
+	return color.NRGBA{A: 0xFF}
+}
+
+// color converts the numeric operands of a color operator. Colors that cannot
+// be painted come back fully transparent.
+func (cs *colorSpace) color(operands []any) color.NRGBA {
+	if cs.components == 0 || len(operands) < cs.components {
+		return color.NRGBA{A: 0}
+	}
+	values := make([]float64, cs.components)
+	for i, v := range operands[:cs.components] {
+		values[i] = toNumber(v)
+	}
+
+	var c color.NRGBA
+	if cs.base != nil {
+		n := cs.base.components
+		i := int(values[0]) * n
+		if i < 0 || i+n > len(cs.lookup) {
+			return color.NRGBA{A: 0}
+		}
+		baseValues := make([]float64, n)
+		for j := range baseValues {
+			baseValues[j] = float64(cs.lookup[i+j]) / 0xFF
+		}
+		c = cs.base.convert(baseValues)
+	} else {
+		c = cs.convert(values)
+	}
+	return c
+}
+
+// renderFont draws the glyphs of a PDF font. When the embedded font program
+// cannot be used, glyphs are looked up by their Unicode value in one of the Go
+// fonts and stretched to the widths the PDF asks for.
+type renderFont struct {
+	text      *pdfFont
+	codeBytes int
+	composite bool
+
+	widths  map[uint32]float64
+	missing float64
+
+	face     *sfnt.Font
+	fallback bool
+	cidToGID []byte
+
+	glyphs map[uint32]*renderGlyph
+}
+
+type renderGlyph struct {
+	segments   sfnt.Segments
+	unitsPerEm float64
+	scaleX     float64
+}
+
+var goFonts struct {
+	once          sync.Once
+	regular, bold *sfnt.Font
+}
+
+func goFont(bold bool) *sfnt.Font {
+	goFonts.once.Do(func() {
+		goFonts.regular, _ = sfnt.Parse(goregular.TTF)
+		goFonts.bold, _ = sfnt.Parse(gobold.TTF)
+	})
+	if bold {
+		return goFonts.bold
+	}
+	return goFonts.regular
+}
+
+// font loads a font resource, caching it for the rest of the page.
+func (r *pageRenderer) font(ref any, name pdfName) *renderFont {
+	if ref == nil {
+		return nil
+	}
+	cacheKey := ref
+	if _, isRef := ref.(pdfRef); !isRef {
+		cacheKey = name
+	}
+	if font, ok := r.fonts[cacheKey]; ok {
+		return font
+	}
+	font := r.doc.loadRenderFont(ref)
+	r.fonts[cacheKey] = font
+	return font
+}
+
+func (d *pdfDocument) loadRenderFont(ref any) *renderFont {
+	dict := d.dict(ref)
+	if dict == nil {
+		return nil
+	}
+
+	font := &renderFont{
+		text:      d.loadFont(ref),
+		codeBytes: 1,
+		widths:    map[uint32]float64{},
+		glyphs:    map[uint32]*renderGlyph{},
+	}
+
+	descriptor := d.dict(dict["FontDescriptor"])
+	if d.resolve(dict["Subtype"]) == pdfName("Type0") {
+		font.composite, font.codeBytes = true, 2
+
+		var descendant pdfDict
+		if kids, ok := d.resolve(dict["DescendantFonts"]).(pdfArray); ok && len(kids) > 0 {
+			descendant = d.dict(kids[0])
+		}
+		font.missing = 1000
+		if v, ok := descendant["DW"]; ok {
+			font.missing = toNumber(d.resolve(v))
+		}
+		if w, ok := d.resolve(descendant["W"]).(pdfArray); ok {
+			d.cidWidths(w, font.widths)
+		}
+		if stream, ok := d.resolve(descendant["CIDToGIDMap"]).(*pdfStream); ok {
+			font.cidToGID, _ = d.decodeStream(stream)
+		}
+		descriptor = d.dict(descendant["FontDescriptor"])
+	} else {
+		first := toInt(d.resolve(dict["FirstChar"]))
+		if widths, ok := d.resolve(dict["Widths"]).(pdfArray); ok && first >= 0 {
+			for i, v := range widths[:min(len(widths), 256)] {
+				font.widths[uint32(first+i)] = toNumber(d.resolve(v))
+			}
+		}
+		font.missing = toNumber(d.resolve(descriptor["MissingWidth"]))
+	}
+
+	for _, key := range []pdfName{"FontFile2", "FontFile3"} {
+		stream, ok := d.resolve(descriptor[key]).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := d.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		if face, err := sfnt.Parse(data); err == nil {
+			font.face = face
+			break
+		}
+	}
+	if font.face == nil {
+		baseFont, _ := d.resolve(dict["BaseFont"]).(pdfName)
+		bold := strings.Contains(string(baseFont), "Bold") || strings.Contains(string(baseFont), "Black")
+		font.face, font.fallback = goFont(bold), true
+	}
+
+	return font
+}
+
+// cidWidths reads the /W array of a CIDFont, which mixes "c [w1 w2 ...]" and
+// "cfirst clast w" entries.
+func (d *pdfDocument) cidWidths(w pdfArray, widths map[uint32]float64) {
+	for i := 0; i+1 < len(w) && len(widths) < maxRenderWidths; {
+		first := toInt(d.resolve(w[i]))
+		if list, ok := d.resolve(w[i+1]).(pdfArray); ok {
+			for j, v := range list {
+				if first+j >= 0 && len(widths) < maxRenderWidths {
+					widths[uint32(first+j)] = toNumber(d.resolve(v))
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, width := toInt(d.resolve(w[i+1])), toNumber(d.resolve(w[i+2]))
+		for c := max(first, 0); c <= last && len(widths) < maxRenderWidths; c++ {
+			widths[uint32(c)] = width
+		}
+		i += 3
+	}
+}
+
+// width returns the advance of a code in thousandths of the font size.
+func (f *renderFont) width(code uint32, buf *sfnt.Buffer) float64 {
+	if w, ok := f.widths[code]; ok {
+		return w
+	}
+	if f.missing > 0 {
+		return f.missing
+	}
+	if gid := f.glyphIndex(code, buf); gid != 0 {
+		ppem := fixed.Int26_6(f.face.UnitsPerEm())
+		if advance, err := f.face.GlyphAdvance(buf, gid, ppem, xfont.HintingNone); err == nil {
+			return float64(advance) / float64(ppem) * 1000
+		}
+	}
+	return 0
+}
+
+func (f *renderFont) glyphIndex(code uint32, buf *sfnt.Buffer) sfnt.GlyphIndex {
+	if f.composite && !f.fallback {
+		if f.cidToGID == nil {
+			return sfnt.GlyphIndex(code)
+		}
+		if i := int(code) * 2; i+1 < len(f.cidToGID) {
+			return sfnt.GlyphIndex(f.cidToGID[i])<<8 | sfnt.GlyphIndex(f.cidToGID[i+1])
+		}
+		return 0
+	}
+
+	var buf8 [4]byte
+	for i := 0; i < f.codeBytes; i++ {
+		buf8[i] = byte(code >> (8 * (f.codeBytes - 1 - i)))
+	}
+	text := []rune(f.text.decode(string(buf8[:f.codeBytes])))
+
+	candidates := make([]rune, 0, 3)
+	if len(text) > 0 {
+		candidates = append(candidates, text[0])
+	}
+	if !f.fallback {
+		// Symbolic TrueType fonts map codes through a (3,0) or (1,0) cmap.
+		candidates = append(candidates, 0xF000+rune(code), rune(code))
+	}
+	for _, c := range candidates {
+		if gid, err := f.face.GlyphIndex(buf, c); err == nil && gid != 0 {
+			return gid
+		}
+	}
+	return 0
+}
+
+// glyph returns the outline of a code, or nil if it has none.
+func (f *renderFont) glyph(code uint32, buf *sfnt.Buffer) *renderGlyph {
+	if g, ok := f.glyphs[code]; ok {
+		return g
+	}
+
+	var g *renderGlyph
+	if gid := f.glyphIndex(code, buf); gid != 0 {
+		unitsPerEm := fixed.Int26_6(f.face.UnitsPerEm())
+		if segments, err := f.face.LoadGlyph(buf, gid, unitsPerEm, nil); err == nil {
+			g = &renderGlyph{
+				segments:   append(sfnt.Segments(nil), segments...),
+				unitsPerEm: float64(unitsPerEm),
+				scaleX:     1,
+			}
+			// Stretch fallback glyphs to the width the document laid out.
+			if w, ok := f.widths[code]; ok && f.fallback && w > 0 {
+				if advance, err := f.face.GlyphAdvance(buf, gid, unitsPerEm, xfont.HintingNone); err == nil && advance > 0 {
+					g.scaleX = max(0.5, min(1.5, w/1000*float64(unitsPerEm)/float64(advance)))
+				}
+			}
+		}
+	}
+
+	f.glyphs[code] = g
+	return g
+}
+
+// isBlank reports whether nothing was drawn on a rendered page.
+func isBlank(img *image.RGBA) bool {
+	for _, v := range img.Pix {
+		if v != 0xFF {
+			return false
+		}
+	}
+	return true
+}
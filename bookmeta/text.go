@@ -0,0 +1,408 @@
+package bookmeta
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ExtractText returns the plain text of every page of a book. EPUB "pages" are
+// spine items, matching the page count reported by Extract.
+func ExtractText(data []byte) ([]string, error) {
+	switch DetectFormat(data) {
+	case FormatPDF:
+		return extractPDFText(data)
+	case FormatEPUB:
+		return extractEPUBText(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// sanitizeText makes extracted text safe to store in a postgres text column.
+func sanitizeText(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	s = strings.ReplaceAll(s, "\x00", "")
+	return strings.TrimSpace(s)
+}
+
+func extractEPUBText(data []byte) ([]string, error) {
+	book, err := openEPUB(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := book.spineItems()
+	pages := make([]string, len(items))
+	for i, item := range items {
+		raw, err := book.readFile(book.resolve(item.Href))
+		if err != nil {
+			continue
+		}
+		pages[i] = sanitizeText(htmlText(raw))
+	}
+
+	return pages, nil
+}
+
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true,
+}
+
+// htmlText collects the character data of an XHTML document, skipping scripts
+// and styles and breaking lines at block elements.
+func htmlText(raw []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var b strings.Builder
+	skip := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "script" || name == "style" || name == "head" {
+				skip++
+			}
+			if htmlBlockElements[name] {
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if (name == "script" || name == "style" || name == "head") && skip > 0 {
+				skip--
+			}
+			if htmlBlockElements[name] {
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if skip == 0 {
+				b.Write(t)
+			}
+		}
+	}
+
+	return collapseSpaces(b.String())
+}
+
+// collapseSpaces squeezes runs of spaces while keeping paragraph breaks.
+func collapseSpaces(s string) string {
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func extractPDFText(data []byte) ([]string, error) {
+	doc, err := openPDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := doc.pages()
+	texts := make([]string, len(pages))
+	fonts := map[any]*pdfFont{}
+	for i, page := range pages {
+		texts[i] = sanitizeText(collapseSpaces(doc.pageText(page, fonts)))
+	}
+
+	return texts, nil
+}
+
+func (d *pdfDocument) pageContents(page pdfDict) []byte {
+	var streams []any
+	switch contents := d.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		streams = []any{contents}
+	case pdfArray:
+		streams = contents
+	}
+
+	var buf bytes.Buffer
+	for _, s := range streams {
+		stream, ok := d.resolve(s).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := d.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// pageText interprets the text operators of a page's content stream. Fonts are
+// cached across pages since most documents share them.
+func (d *pdfDocument) pageText(page pdfDict, fonts map[any]*pdfFont) string {
+	content := d.pageContents(page)
+	fontResources := d.dict(d.dict(page["Resources"])["Font"])
+
+	var b strings.Builder
+	var operands []any
+	var font *pdfFont
+	p := &pdfParser{data: content}
+
+	for {
+		obj, err := p.parseObject(0)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			operands = operands[:0]
+			continue
+		}
+
+		op, isOp := obj.(keyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "BI":
+			// Inline images carry raw binary data up to "EI".
+			end := bytes.Index(p.data[p.pos:], []byte("EI"))
+			if end < 0 {
+				p.pos = len(p.data)
+			} else {
+				p.pos += end + 2
+			}
+		case "Tf":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					ref := fontResources[name]
+					if ref == nil {
+						font = nil
+						break
+					}
+					cacheKey := any(ref)
+					if _, isRef := ref.(pdfRef); !isRef {
+						cacheKey = name
+					}
+					if fonts[cacheKey] == nil {
+						fonts[cacheKey] = d.loadFont(ref)
+					}
+					font = fonts[cacheKey]
+				}
+			}
+		case "Tj", "'", "\"":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(string); ok {
+					if op != "Tj" {
+						b.WriteByte('\n')
+					}
+					b.WriteString(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if arr, ok := operands[len(operands)-1].(pdfArray); ok {
+					for _, item := range arr {
+						switch v := item.(type) {
+						case string:
+							b.WriteString(font.decode(v))
+						case int, float64:
+							// Large negative kerning is how many generators
+							// encode word spacing.
+							if toNumber(v) < -200 {
+								b.WriteByte(' ')
+							}
+						}
+					}
+				}
+			}
+		case "T*", "Td", "TD":
+			b.WriteByte('\n')
+		case "Tm", "ET":
+			b.WriteByte(' ')
+		}
+
+		operands = operands[:0]
+	}
+
+	return b.String()
+}
+
+func toNumber(obj any) float64 {
+	switch v := obj.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// pdfFont knows how to turn the bytes of a shown string into text. Only
+// ToUnicode maps and single byte Latin encodings are supported.
+type pdfFont struct {
+	codeBytes int
+	toUnicode map[uint32]string
+}
+
+func (d *pdfDocument) loadFont(ref any) *pdfFont {
+	dict := d.dict(ref)
+	font := &pdfFont{codeBytes: 1}
+	if dict == nil {
+		return font
+	}
+
+	if d.resolve(dict["Subtype"]) == pdfName("Type0") {
+		font.codeBytes = 2
+	}
+
+	if stream, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := d.decodeStream(stream); err == nil {
+			font.toUnicode, font.codeBytes = parseToUnicodeCMap(data, font.codeBytes)
+		}
+	}
+
+	return font
+}
+
+func (f *pdfFont) decode(s string) string {
+	if f == nil || (f.toUnicode == nil && f.codeBytes == 1) {
+		return decodePDFTextString(s)
+	}
+	if f.toUnicode == nil {
+		// Composite fonts without a ToUnicode map can't be decoded reliably.
+		return ""
+	}
+
+	var b strings.Builder
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		var code uint32
+		for j := 0; j < f.codeBytes; j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+		if text, ok := f.toUnicode[code]; ok {
+			b.WriteString(text)
+		} else if f.codeBytes == 1 {
+			b.WriteRune(rune(code))
+		}
+	}
+	return b.String()
+}
+
+// parseToUnicodeCMap reads the bfchar and bfrange sections of a ToUnicode CMap.
+func parseToUnicodeCMap(data []byte, codeBytes int) (map[uint32]string, int) {
+	mapping := map[uint32]string{}
+	p := &pdfParser{data: data}
+	var operands []any
+
+	for {
+		obj, err := p.parseObject(0)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		op, isOp := obj.(keyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if lo, ok := operands[0].(string); ok && len(lo) > 0 {
+					codeBytes = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(string)
+				dst, ok2 := operands[i+1].(string)
+				if ok1 && ok2 {
+					mapping[codeValue(src)] = decodeUTF16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(string)
+				hi, ok2 := operands[i+1].(string)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+
+				switch dst := operands[i+2].(type) {
+				case string:
+					base := []rune(decodeUTF16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						r := append([]rune(nil), base...)
+						r[len(r)-1] += rune(code - start)
+						mapping[code] = string(r)
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(string); ok && start+uint32(j) <= end {
+							mapping[start+uint32(j)] = decodeUTF16BE(s)
+						}
+					}
+				}
+			}
+		}
+
+		operands = operands[:0]
+	}
+
+	return mapping, codeBytes
+}
+
+func codeValue(s string) uint32 {
+	var v uint32
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | uint32(s[i])
+	}
+	return v
+}
+
+func decodeUTF16BE(s string) string {
+	if len(s)%2 != 0 {
+		return s
+	}
+	u := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	runes := utf16.Decode(u)
+	out := runes[:0]
+	for _, r := range runes {
+		if r != utf8.RuneError {
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,113 @@
+package bookmeta
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestExtractText(t *testing.T) {
+	simplePDF := readFixture(t, "simple.pdf")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "pdf with a standard font",
+			data: simplePDF,
+			want: []string{"Hello, world!\nSecond line", "Second page"},
+		},
+		{
+			name: "pdf with a ToUnicode cmap in an object stream",
+			data: readFixture(t, "compressed.pdf"),
+			want: []string{"Wörs"},
+		},
+		{
+			name: "pdf truncated in the middle of its pages",
+			data: simplePDF[:bytes.Index(simplePDF, []byte("[(Second)"))],
+			want: []string{"Hello, world!\nSecond line", ""},
+		},
+		{
+			name: "epub",
+			data: readFixture(t, "simple.epub"),
+			want: []string{"Chapter One\nIt was a bright cold day in April.", "Second\nchapter & end"},
+		},
+		{
+			name:    "truncated epub",
+			data:    readFixture(t, "simple.epub")[:300],
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			data:    []byte("plain text"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractText(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ExtractText() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExtractText() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "blocks break lines", html: "<div><h1>Title</h1><p>One</p><p>Two</p></div>", want: "Title\nOne\nTwo"},
+		{name: "inline elements do not", html: "<p>very <em>important</em> text</p>", want: "very important text"},
+		{name: "scripts styles and head are skipped", html: "<html><head><title>x</title></head><body><style>p{}</style>Text<script>1</script></body></html>", want: "Text"},
+		{name: "html entities", html: "<p>caf&eacute; &amp; cr&egrave;me&nbsp;br&ucirc;l&eacute;e</p>", want: "café & crème brûlée"},
+		{name: "unclosed tags", html: "<p>one<br>two<p>three", want: "one\ntwo\nthree"},
+		{name: "broken markup keeps what came before", html: "<p>before</p><p <<", want: "before"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlText([]byte(tt.html)); got != tt.want {
+				t.Errorf("htmlText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	if got, want := sanitizeText(" a\x00b\xffc "), "abc"; got != want {
+		t.Errorf("sanitizeText() = %q, want %q", got, want)
+	}
+}
+
+func TestParseToUnicodeCMap(t *testing.T) {
+	cmap := []byte(`begincmap
+1 begincodespacerange <00> <FF> endcodespacerange
+1 beginbfchar <01> <00660069> endbfchar
+2 beginbfrange <41> <43> <0061> <50> <51> [<0078> <0079>] endbfrange
+endcmap`)
+
+	mapping, codeBytes := parseToUnicodeCMap(cmap, 2)
+	if codeBytes != 1 {
+		t.Errorf("codeBytes = %d, want 1 from the codespace range", codeBytes)
+	}
+	want := map[uint32]string{0x01: "fi", 0x41: "a", 0x42: "b", 0x43: "c", 0x50: "x", 0x51: "y"}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("mapping = %q, want %q", mapping, want)
+	}
+}
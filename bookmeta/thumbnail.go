@@ -0,0 +1,64 @@
+package bookmeta
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// Thumbnail scales src down to the given width, keeping the aspect ratio, and
+// encodes it as a JPEG. Images narrower than width are encoded unscaled.
+func Thumbnail(src image.Image, width int) ([]byte, error) {
+	var dst image.Image = src
+	if bounds := src.Bounds(); bounds.Dx() > width {
+		height := max(bounds.Dy()*width/bounds.Dx(), 1)
+		dst = downscale(src, width, height)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale averages every source pixel that falls into a destination pixel,
+// which avoids the aliasing of nearest-neighbour sampling. The source is
+// converted a band of rows at a time, so only a small part of it is ever held
+// as RGBA next to the decoded image.
+func downscale(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+
+	band := image.NewRGBA(image.Rect(0, 0, sw, sh/height+1))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		draw.Draw(band, image.Rect(0, 0, sw, y1-y0), src, image.Pt(bounds.Min.X, bounds.Min.Y+y0), draw.Src)
+
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+
+			var r, g, b, a, n int
+			for sy := 0; sy < y1-y0; sy++ {
+				row := band.Pix[sy*band.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					a += int(row[sx*4+3])
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	changeCreated = "created"
+	changeUpdated = "updated"
+	changeDeleted = "deleted"
+)
+
+const (
+	entityBook            = "book"
+	entityReadingProgress = "reading_progress"
+	entityShelf           = "shelf"
+	entityAnnotation      = "annotation"
+	entityBookmark        = "bookmark"
+)
+
+const (
+	defaultChangesLimit = 500
+	maxChangesLimit     = 1000
+)
+
+type Change struct {
+	Entity    string    `json:"entity"`
+	EntityID  string    `json:"entity_id"`
+	ChangedAt time.Time `json:"changed_at"`
+	Data      any       `json:"data,omitempty"`
+}
+
+type ShelfChange struct {
+	repository.Shelf
+	BookIDs []uuid.UUID `json:"book_ids"`
+}
+
+type ChangesResponse struct {
+	Created   []Change `json:"created"`
+	Updated   []Change `json:"updated"`
+	Deleted   []Change `json:"deleted"`
+	NextToken string   `json:"next_token"`
+	HasMore   bool     `json:"has_more"`
+}
+
+// Change tokens are the opaque form of the id of the last change-log entry a
+// client has seen. An empty token means the start of the log.
+func encodeChangeToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeChangeToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, errors.New("invalid sync token")
+	}
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid sync token")
+	}
+	return id, nil
+}
+
+// collapsedChange is what a run of log entries for one record amounts to.
+type collapsedChange struct {
+	entry      repository.ChangeLog
+	operation  string
+	superseded bool
+}
+
+// collapseChanges folds the log entries of each record into a single change,
+// keeping the order of each record's latest entry. A record that was created
+// and deleted within the entries is dropped altogether, and one that was
+// created and then updated is still reported as created.
+func collapseChanges(entries []repository.ChangeLog) []collapsedChange {
+	type key struct{ entity, id string }
+	index := map[key]int{}
+	var changes []collapsedChange
+
+	for _, entry := range entries {
+		k := key{entry.Entity, entry.EntityID}
+		operation := entry.Operation
+		if i, ok := index[k]; ok {
+			first := changes[i].operation
+			changes[i].superseded = true
+			switch {
+			case first == changeCreated && operation == changeDeleted:
+				operation = ""
+			case first == changeCreated:
+				operation = changeCreated
+			case first == changeDeleted && operation == changeCreated:
+				operation = changeUpdated
+			}
+		}
+		index[k] = len(changes)
+		changes = append(changes, collapsedChange{entry: entry, operation: operation})
+	}
+
+	collapsed := make([]collapsedChange, 0, len(index))
+	for _, change := range changes {
+		if !change.superseded && change.operation != "" {
+			collapsed = append(collapsed, change)
+		}
+	}
+	return collapsed
+}
+
+// loadChangedRecords fetches the current state of every record that was
+// created or updated, keyed by entity and id. A record that is missing has
+// since been deleted or become unreadable, and a later log entry says so.
+func loadChangedRecords(ctx context.Context, q *repository.Queries, userID string, changes []collapsedChange) (map[string]map[string]any, error) {
+	ids := map[string][]uuid.UUID{}
+	for _, change := range changes {
+		if change.operation == changeDeleted {
+			continue
+		}
+		id, err := uuid.Parse(change.entry.EntityID)
+		if err != nil {
+			continue
+		}
+		ids[change.entry.Entity] = append(ids[change.entry.Entity], id)
+	}
+
+	records := map[string]map[string]any{
+		entityBook:            {},
+		entityReadingProgress: {},
+		entityShelf:           {},
+		entityAnnotation:      {},
+		entityBookmark:        {},
+	}
+
+	if len(ids[entityBook]) > 0 {
+		books, err := q.GetReadableBooksByIDs(ctx, repository.GetReadableBooksByIDsParams{Ids: ids[entityBook], UserID: userID})
+		if err != nil {
+			return nil, err
+		}
+		for _, book := range books {
+			records[entityBook][book.ID.String()] = book
+		}
+	}
+
+	if len(ids[entityReadingProgress]) > 0 {
+		progress, err := q.GetReadingProgressByBookIDs(ctx, repository.GetReadingProgressByBookIDsParams{UserID: userID, BookIds: ids[entityReadingProgress]})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range progress {
+			records[entityReadingProgress][p.BookID.String()] = p
+		}
+	}
+
+	if len(ids[entityShelf]) > 0 {
+		shelves, err := q.GetShelvesByIDs(ctx, repository.GetShelvesByIDsParams{UserID: userID, Ids: ids[entityShelf]})
+		if err != nil {
+			return nil, err
+		}
+		shelfBooks, err := q.GetShelfBookIDs(ctx, ids[entityShelf])
+		if err != nil {
+			return nil, err
+		}
+		bookIDs := map[uuid.UUID][]uuid.UUID{}
+		for _, row := range shelfBooks {
+			bookIDs[row.ShelfID] = append(bookIDs[row.ShelfID], row.BookID)
+		}
+		for _, shelf := range shelves {
+			change := ShelfChange{Shelf: shelf, BookIDs: bookIDs[shelf.ID]}
+			if change.BookIDs == nil {
+				change.BookIDs = []uuid.UUID{}
+			}
+			records[entityShelf][shelf.ID.String()] = change
+		}
+	}
+
+	if len(ids[entityAnnotation]) > 0 {
+		highlights, err := q.GetHighlightsByIDs(ctx, repository.GetHighlightsByIDsParams{UserID: userID, Ids: ids[entityAnnotation]})
+		if err != nil {
+			return nil, err
+		}
+		for _, highlight := range highlights {
+			records[entityAnnotation][highlight.ID.String()] = highlight
+		}
+	}
+
+	if len(ids[entityBookmark]) > 0 {
+		bookmarks, err := q.GetBookmarksByIDs(ctx, repository.GetBookmarksByIDsParams{UserID: userID, Ids: ids[entityBookmark]})
+		if err != nil {
+			return nil, err
+		}
+		for _, bookmark := range bookmarks {
+			records[entityBookmark][bookmark.ID.String()] = bookmark
+		}
+	}
+
+	return records, nil
+}
+
+// getChangesHandler returns what changed in the user's books, progress,
+// shelves, annotations and bookmarks since the given token, oldest first. Clients keep
+// calling with next_token while has_more is set.
+func getChangesHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	since, err := decodeChangeToken(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultChangesLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxChangesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxChangesLimit)})
+			return
+		}
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	entries, err := localQueries.GetChangesSince(c, repository.GetChangesSinceParams{UserID: dbUser.ID, Since: since, MaxChanges: int32(limit + 1)})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := ChangesResponse{Created: []Change{}, Updated: []Change{}, Deleted: []Change{}, NextToken: encodeChangeToken(since)}
+	if len(entries) > limit {
+		entries, response.HasMore = entries[:limit], true
+	}
+	if len(entries) > 0 {
+		response.NextToken = encodeChangeToken(entries[len(entries)-1].ID)
+	}
+
+	changes := collapseChanges(entries)
+	records, err := loadChangedRecords(c, localQueries, dbUser.ID, changes)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	for _, change := range changes {
+		entry := change.entry
+		item := Change{Entity: entry.Entity, EntityID: entry.EntityID, ChangedAt: entry.ChangedAt}
+		if change.operation == changeDeleted {
+			response.Deleted = append(response.Deleted, item)
+			continue
+		}
+
+		record, ok := records[entry.Entity][entry.EntityID]
+		if !ok {
+			continue
+		}
+		item.Data = record
+		if change.operation == changeCreated {
+			response.Created = append(response.Created, item)
+		} else {
+			response.Updated = append(response.Updated, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
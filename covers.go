@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/Hodik/noteshelf-be.git/bookmeta"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/Hodik/noteshelf-be.git/utils"
+)
+
+const (
+	coverSmall  = "small"
+	coverMedium = "medium"
+	coverLarge  = "large"
+)
+
+var coverWidths = map[string]int{
+	coverSmall:  160,
+	coverMedium: 320,
+	coverLarge:  640,
+}
+
+// coverKey places thumbnails under a covers/ prefix next to the book itself.
+func coverKey(book repository.Book, size string) string {
+	dir := "."
+	if book.S3Key != nil {
+		dir = path.Dir(*book.S3Key)
+	}
+	return path.Join(dir, "covers", fmt.Sprintf("%s-%s.jpg", book.ID, size))
+}
+
+func coverKeys(book repository.Book) map[string]*string {
+	return map[string]*string{
+		coverSmall:  book.CoverSmallKey,
+		coverMedium: book.CoverMediumKey,
+		coverLarge:  book.CoverLargeKey,
+	}
+}
+
+func generateBookCovers(ctx context.Context, book repository.Book, data []byte) error {
+	img, err := bookmeta.ExtractCover(data)
+	if errors.Is(err, bookmeta.ErrNoCover) || errors.Is(err, bookmeta.ErrUnsupportedFormat) {
+		log.Printf("no cover generated for book %s: %s", book.ID, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]string{}
+	for size, width := range coverWidths {
+		thumbnail, err := bookmeta.Thumbnail(img, width)
+		if err != nil {
+			return err
+		}
+
+		key := coverKey(book, size)
+		if err := utils.UploadObject(ctx, cfg.S3Client, cfg.BucketName, key, thumbnail, "image/jpeg"); err != nil {
+			return err
+		}
+		keys[size] = key
+	}
+
+	small, medium, large := keys[coverSmall], keys[coverMedium], keys[coverLarge]
+	return cfg.Queries.SetBookCovers(ctx, repository.SetBookCoversParams{CoverSmallKey: &small, CoverMediumKey: &medium, CoverLargeKey: &large, ID: book.ID})
+}
+
+// signedCoverURLs returns CloudFront URLs for every generated thumbnail of
+// book, keyed by size. It is nil until the cover job has run.
+func signedCoverURLs(book repository.Book) (map[string]string, error) {
+	var urls map[string]string
+	for size, key := range coverKeys(book) {
+		if key == nil {
+			continue
+		}
+
+		url, err := utils.GeneratePresignedReadURL(cfg.CloudfrontUrl, *key, cfg.KeyPairID, int(cfg.PresignedUrlExpirySeconds), cfg.PrivateSignKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if urls == nil {
+			urls = map[string]string{}
+		}
+		urls[size] = url
+	}
+
+	return urls, nil
+}
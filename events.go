@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const changeLogChannel = "change_log"
+
+const (
+	eventsHeartbeatInterval = 25 * time.Second
+	eventsWriteTimeout      = 10 * time.Second
+	eventsReconnectDelay    = 5 * time.Second
+	eventsSubscriberBuffer  = 64
+)
+
+// changeNotification is a change-log row as sent by pg_notify. Timestamps
+// without a time zone come through without an offset, so changed_at is parsed
+// by hand.
+type changeNotification struct {
+	ID        int64  `json:"id"`
+	UserID    string `json:"user_id"`
+	Entity    string `json:"entity"`
+	EntityID  string `json:"entity_id"`
+	Operation string `json:"operation"`
+	ChangedAt string `json:"changed_at"`
+}
+
+func (n changeNotification) entry() repository.ChangeLog {
+	changedAt, _ := time.ParseInLocation("2006-01-02T15:04:05.999999", n.ChangedAt, time.UTC)
+	return repository.ChangeLog{
+		ID:        n.ID,
+		UserID:    n.UserID,
+		Entity:    n.Entity,
+		EntityID:  n.EntityID,
+		Operation: n.Operation,
+		ChangedAt: changedAt,
+	}
+}
+
+// EventHub listens for change-log notifications on a connection of its own and
+// hands each one to the streams of the user it belongs to. Every replica runs
+// its own hub, so a change made through any of them reaches every stream.
+type EventHub struct {
+	connString string
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan repository.ChangeLog]struct{}
+	closed      bool
+}
+
+func NewEventHub(connString string) *EventHub {
+	return &EventHub{connString: connString, subscribers: map[string]map[chan repository.ChangeLog]struct{}{}}
+}
+
+// Subscribe registers a stream for the user. The channel is closed when the
+// hub shuts down or when the stream falls too far behind; either way the
+// client should reconnect and catch up through GET /changes.
+func (h *EventHub) Subscribe(userID string) chan repository.ChangeLog {
+	ch := make(chan repository.ChangeLog, eventsSubscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[chan repository.ChangeLog]struct{}{}
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	return ch
+}
+
+func (h *EventHub) Unsubscribe(userID string, ch chan repository.ChangeLog) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[userID][ch]; ok {
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+		close(ch)
+	}
+}
+
+func (h *EventHub) publish(entry repository.ChangeLog) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[entry.UserID] {
+		select {
+		case ch <- entry:
+		default:
+			delete(h.subscribers[entry.UserID], ch)
+			close(ch)
+		}
+	}
+	if len(h.subscribers[entry.UserID]) == 0 {
+		delete(h.subscribers, entry.UserID)
+	}
+}
+
+// Close disconnects every stream. It is meant to run on server shutdown, which
+// otherwise waits for the streams to end by themselves.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userID, channels := range h.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+		delete(h.subscribers, userID)
+	}
+}
+
+// Run listens until ctx is done, reconnecting after connection failures.
+// Changes made while the hub is disconnected aren't pushed; clients pick them
+// up from GET /changes.
+func (h *EventHub) Run(ctx context.Context) {
+	for {
+		if err := h.listen(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("event hub: %s, reconnecting in %s", err, eventsReconnectDelay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsReconnectDelay):
+		}
+	}
+}
+
+func (h *EventHub) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, h.connString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+changeLogChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var payload changeNotification
+		if err := json.Unmarshal([]byte(notification.Payload), &payload); err != nil {
+			log.Printf("event hub: bad notification payload: %s", err)
+			continue
+		}
+		h.publish(payload.entry())
+	}
+}
+
+// eventsHandler streams the user's changes as server-sent events. Each event
+// is named after the entity and what happened to it, e.g. book.created, and
+// carries the current record unless it was deleted. Event ids are change
+// tokens, so after a reconnect a client can fetch what it missed from
+// GET /changes?since=<last event id>.
+func eventsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	events := eventHub.Subscribe(dbUser.ID)
+	defer eventHub.Unsubscribe(dbUser.ID, events)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// The server's write timeout is meant for ordinary requests; a stream
+	// pushes the deadline forward before every write instead.
+	rc := http.NewResponseController(c.Writer)
+	write := func(format string, args ...any) bool {
+		if err := rc.SetWriteDeadline(time.Now().Add(eventsWriteTimeout)); err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(c.Writer, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !write(": connected\n\n") {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case entry, ok := <-events:
+			if !ok {
+				return
+			}
+
+			change := collapsedChange{entry: entry, operation: entry.Operation}
+			item := Change{Entity: entry.Entity, EntityID: entry.EntityID, ChangedAt: entry.ChangedAt}
+			if entry.Operation != changeDeleted {
+				records, err := loadChangedRecords(c, cfg.Queries, dbUser.ID, []collapsedChange{change})
+				if err != nil {
+					log.Printf("events: failed to load %s %s: %s", entry.Entity, entry.EntityID, err)
+					continue
+				}
+				record, ok := records[entry.Entity][entry.EntityID]
+				if !ok {
+					continue
+				}
+				item.Data = record
+			}
+
+			data, err := json.Marshal(item)
+			if err != nil {
+				continue
+			}
+			if !write("id: %s\nevent: %s.%s\ndata: %s\n\n", encodeChangeToken(entry.ID), entry.Entity, entry.Operation, data) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package export
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/google/uuid"
+)
+
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+	FormatCSV      Format = "csv"
+)
+
+// BookAnnotations is a book together with the reader's highlights and
+// bookmarks in it.
+type BookAnnotations struct {
+	Book       repository.Book
+	Highlights []repository.Highlight
+	Bookmarks  []repository.Bookmark
+}
+
+// Section is a chapter or page of a book with the highlights made in it.
+type Section struct {
+	Title      string
+	Highlights []repository.Highlight
+	order      int
+}
+
+// spineStep matches the spine reference at the start of an EPUB CFI, e.g. the
+// "/6/4" in "epubcfi(/6/4[chap01]!/4/2/1:0)". Spine items sit at even indexes.
+var spineStep = regexp.MustCompile(`^epubcfi\(/6/(\d+)`)
+
+// Sections groups the highlights of a book by chapter for EPUBs and by page
+// otherwise. EPUB "pages" are spine items, so a page number and a CFI both
+// identify the chapter. Highlights without a position come last.
+func Sections(b BookAnnotations) []Section {
+	epub := b.Book.Format != nil && *b.Book.Format == "epub"
+
+	var sections []Section
+	index := map[int]int{}
+	for _, highlight := range b.Highlights {
+		order := -1
+		if highlight.PageNumber != nil {
+			order = int(*highlight.PageNumber)
+		} else if epub && highlight.CfiRange != nil {
+			if m := spineStep.FindStringSubmatch(*highlight.CfiRange); m != nil {
+				step, _ := strconv.Atoi(m[1])
+				order = step / 2
+			}
+		}
+
+		i, ok := index[order]
+		if !ok {
+			title := "Other highlights"
+			switch {
+			case order >= 0 && epub:
+				title = "Chapter " + strconv.Itoa(order)
+			case order >= 0:
+				title = "Page " + strconv.Itoa(order)
+			}
+			i = len(sections)
+			index[order] = i
+			sections = append(sections, Section{Title: title, order: order})
+		}
+		sections[i].Highlights = append(sections[i].Highlights, highlight)
+	}
+
+	slices.SortStableFunc(sections, func(a, b Section) int {
+		if (a.order < 0) != (b.order < 0) {
+			if a.order < 0 {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.order, b.order)
+	})
+	for _, section := range sections {
+		slices.SortStableFunc(section.Highlights, func(a, b repository.Highlight) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+	}
+
+	return sections
+}
+
+type jsonHighlight struct {
+	ID           uuid.UUID `json:"id"`
+	PageNumber   *int32    `json:"page_number"`
+	CfiRange     *string   `json:"cfi_range"`
+	SelectedText string    `json:"selected_text"`
+	Color        string    `json:"color"`
+	Note         *string   `json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type jsonSection struct {
+	Title      string          `json:"title"`
+	Highlights []jsonHighlight `json:"highlights"`
+}
+
+type jsonBookmark struct {
+	ID                      uuid.UUID `json:"id"`
+	PageNumber              *int32    `json:"page_number"`
+	Location                *int32    `json:"location"`
+	LocatorCfi              *string   `json:"locator_cfi"`
+	LocatorTotalProgression *float64  `json:"locator_total_progression"`
+	Label                   string    `json:"label"`
+	CreatedAt               time.Time `json:"created_at"`
+}
+
+type jsonBook struct {
+	ID        uuid.UUID      `json:"id"`
+	Title     string         `json:"title"`
+	Author    *string        `json:"author"`
+	Isbn      *string        `json:"isbn"`
+	Sections  []jsonSection  `json:"sections"`
+	Bookmarks []jsonBookmark `json:"bookmarks"`
+}
+
+// JSON writes the books as an array, each with its highlights grouped into
+// sections and its bookmarks.
+func JSON(w io.Writer, books []BookAnnotations) error {
+	out := make([]jsonBook, 0, len(books))
+	for _, b := range books {
+		book := jsonBook{ID: b.Book.ID, Title: b.Book.Title, Author: b.Book.Author, Isbn: b.Book.Isbn, Sections: []jsonSection{}, Bookmarks: make([]jsonBookmark, 0, len(b.Bookmarks))}
+		for _, section := range Sections(b) {
+			s := jsonSection{Title: section.Title, Highlights: make([]jsonHighlight, 0, len(section.Highlights))}
+			for _, h := range section.Highlights {
+				s.Highlights = append(s.Highlights, jsonHighlight{
+					ID:           h.ID,
+					PageNumber:   h.PageNumber,
+					CfiRange:     h.CfiRange,
+					SelectedText: h.SelectedText,
+					Color:        h.Color,
+					Note:         h.Note,
+					CreatedAt:    h.CreatedAt,
+					UpdatedAt:    h.UpdatedAt,
+				})
+			}
+			book.Sections = append(book.Sections, s)
+		}
+		for _, bm := range b.Bookmarks {
+			book.Bookmarks = append(book.Bookmarks, jsonBookmark{
+				ID:                      bm.ID,
+				PageNumber:              bm.PageNumber,
+				Location:                bm.Location,
+				LocatorCfi:              bm.LocatorCfi,
+				LocatorTotalProgression: bm.LocatorTotalProgression,
+				Label:                   bm.Label,
+				CreatedAt:               bm.CreatedAt,
+			})
+		}
+		out = append(out, book)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+var csvHeader = []string{
+	"book_id", "book_title", "book_author", "section", "page_number", "cfi_range",
+	"selected_text", "color", "note", "created_at", "updated_at",
+}
+
+// CSV writes one row per highlight, in the same order as the other formats.
+// Bookmarks have no text to put in a row and are left out.
+func CSV(w io.Writer, books []BookAnnotations) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, b := range books {
+		for _, section := range Sections(b) {
+			for _, h := range section.Highlights {
+				page := ""
+				if h.PageNumber != nil {
+					page = strconv.Itoa(int(*h.PageNumber))
+				}
+				record := []string{
+					b.Book.ID.String(),
+					b.Book.Title,
+					deref(b.Book.Author),
+					section.Title,
+					page,
+					deref(h.CfiRange),
+					h.SelectedText,
+					h.Color,
+					deref(h.Note),
+					h.CreatedAt.UTC().Format(time.RFC3339),
+					h.UpdatedAt.UTC().Format(time.RFC3339),
+				}
+				if err := writer.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// BookmarkPosition describes where a bookmark is: its page, its Kindle
+// location, or how far into the book it is.
+func BookmarkPosition(b repository.Bookmark) string {
+	switch {
+	case b.PageNumber != nil:
+		return "page " + strconv.Itoa(int(*b.PageNumber))
+	case b.Location != nil:
+		return "location " + strconv.Itoa(int(*b.Location))
+	case b.LocatorTotalProgression != nil:
+		return strconv.FormatFloat(*b.LocatorTotalProgression*100, 'f', 0, 64) + "%"
+	}
+	return ""
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f]+`)
+
+// Filename turns a book title into a file name without an extension that is
+// safe on the common file systems and as an Obsidian note name.
+func Filename(book repository.Book) string {
+	name := strings.Join(strings.Fields(unsafeFilenameChars.ReplaceAllString(book.Title, " ")), " ")
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return book.ID.String()
+	}
+	if len(name) > 120 {
+		name = strings.ToValidUTF8(name[:120], "")
+	}
+	return name
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/google/uuid"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+var (
+	created = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	// epubBook has every optional detail set and covers chapter sections from
+	// both page numbers and CFIs, notes, and bookmarks.
+	epubBook = BookAnnotations{
+		Book: repository.Book{
+			ID:       uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+			Title:    "The Left Hand of Darkness",
+			Author:   ptr("Ursula K. Le Guin"),
+			Isbn:     ptr("9780441478125"),
+			Language: ptr("en"),
+			Format:   ptr("epub"),
+		},
+		Highlights: []repository.Highlight{
+			{
+				ID:           uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000003"),
+				CfiRange:     ptr("epubcfi(/6/8[chap04]!/4/2/1:0,/1:42)"),
+				SelectedText: "Light is the left hand of darkness\nand darkness the right hand of light.",
+				Color:        "yellow",
+				Note:         ptr("Tormer's Lay.\nCompare with the epigraph."),
+				CreatedAt:    created.Add(2 * time.Hour),
+				UpdatedAt:    created.Add(3 * time.Hour),
+			},
+			{
+				ID:           uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000002"),
+				PageNumber:   ptr[int32](1),
+				SelectedText: "The story is not all mine, nor told by me alone.",
+				Color:        "blue",
+				Note:         ptr("  "),
+				CreatedAt:    created.Add(time.Hour),
+				UpdatedAt:    created.Add(time.Hour),
+			},
+			{
+				ID:           uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000001"),
+				PageNumber:   ptr[int32](1),
+				SelectedText: "I'll make my report as if I told a story.",
+				Color:        "green",
+				CreatedAt:    created,
+				UpdatedAt:    created,
+			},
+			{
+				ID:           uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000004"),
+				SelectedText: "Truth is a matter of the imagination.",
+				Color:        "pink",
+				CreatedAt:    created.Add(4 * time.Hour),
+				UpdatedAt:    created.Add(4 * time.Hour),
+			},
+		},
+		Bookmarks: []repository.Bookmark{
+			{
+				ID:         uuid.MustParse("bbbbbbbb-0000-0000-0000-000000000001"),
+				PageNumber: ptr[int32](4),
+				Label:      "On the Ice",
+				CreatedAt:  created,
+			},
+			{
+				ID:                      uuid.MustParse("bbbbbbbb-0000-0000-0000-000000000002"),
+				LocatorCfi:              ptr("epubcfi(/6/30[chap15]!/4/2/1:0)"),
+				LocatorTotalProgression: ptr(0.73),
+				CreatedAt:               created.Add(time.Hour),
+			},
+		},
+	}
+
+	// pdfBook has no author and a bookmark at a Kindle location.
+	pdfBook = BookAnnotations{
+		Book: repository.Book{
+			ID:     uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+			Title:  "Untitled Notes",
+			Format: ptr("pdf"),
+		},
+		Highlights: []repository.Highlight{
+			{
+				ID:           uuid.MustParse("cccccccc-0000-0000-0000-000000000001"),
+				PageNumber:   ptr[int32](12),
+				SelectedText: "A page twelve highlight.",
+				Color:        "yellow",
+				Note:         ptr("Single line note."),
+				CreatedAt:    created,
+				UpdatedAt:    created,
+			},
+		},
+		Bookmarks: []repository.Bookmark{
+			{
+				ID:        uuid.MustParse("dddddddd-0000-0000-0000-000000000001"),
+				Location:  ptr[int32](340),
+				Label:     "Appendix\nB",
+				CreatedAt: created,
+			},
+		},
+	}
+)
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output does not match %s (run go test -update to rewrite it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		book   BookAnnotations
+		golden string
+	}{
+		{"epub with author and bookmarks", epubBook, "epub.md"},
+		{"pdf without author", pdfBook, "no_author.md"},
+		{"no annotations", BookAnnotations{Book: pdfBook.Book}, "empty.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Markdown(&buf, tt.book); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		books  []BookAnnotations
+		golden string
+	}{
+		{"library", []BookAnnotations{epubBook, pdfBook}, "library.json"},
+		{"no annotations", []BookAnnotations{{Book: pdfBook.Book}}, "empty.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := JSON(&buf, tt.books); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Markdown writes a book's highlights as an Obsidian note. The front matter
+// carries the book's details so notes can be queried with Dataview, each
+// section becomes a heading, and each highlight a quote followed by its color
+// and note. Bookmarks are listed under a heading of their own at the end.
+func Markdown(w io.Writer, b BookAnnotations) error {
+	var sb strings.Builder
+
+	sb.WriteString("---\n")
+	frontMatter(&sb, "title", b.Book.Title)
+	if b.Book.Author != nil {
+		frontMatter(&sb, "author", *b.Book.Author)
+	}
+	if b.Book.Isbn != nil {
+		frontMatter(&sb, "isbn", *b.Book.Isbn)
+	}
+	if b.Book.Language != nil {
+		frontMatter(&sb, "language", *b.Book.Language)
+	}
+	frontMatter(&sb, "book_id", b.Book.ID.String())
+	sb.WriteString("highlights: " + strconv.Itoa(len(b.Highlights)) + "\n")
+	sb.WriteString("bookmarks: " + strconv.Itoa(len(b.Bookmarks)) + "\n")
+	sb.WriteString("tags:\n  - highlights\n")
+	sb.WriteString("---\n\n")
+
+	sb.WriteString("# " + singleLine(b.Book.Title) + "\n")
+	if b.Book.Author != nil {
+		sb.WriteString("\nby " + singleLine(*b.Book.Author) + "\n")
+	}
+
+	for _, section := range Sections(b) {
+		sb.WriteString("\n## " + section.Title + "\n")
+		for _, h := range section.Highlights {
+			sb.WriteString("\n")
+			for _, line := range strings.Split(strings.TrimSpace(h.SelectedText), "\n") {
+				sb.WriteString("> " + strings.TrimRight(line, " \t\r") + "\n")
+			}
+			sb.WriteString("\n- Color: " + h.Color + "\n")
+			if h.Note != nil && strings.TrimSpace(*h.Note) != "" {
+				note := strings.ReplaceAll(strings.TrimSpace(*h.Note), "\n", "\n  ")
+				sb.WriteString("- Note: " + note + "\n")
+			}
+		}
+	}
+
+	if len(b.Bookmarks) > 0 {
+		sb.WriteString("\n## Bookmarks\n\n")
+		for _, bm := range b.Bookmarks {
+			line := singleLine(bm.Label)
+			if position := BookmarkPosition(bm); position != "" {
+				if line == "" {
+					line = position
+				} else {
+					line += " (" + position + ")"
+				}
+			}
+			sb.WriteString("- " + line + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// MarkdownZip writes one note per book into a zip archive that can be
+// unpacked straight into an Obsidian vault. Books with the same title get a
+// numbered suffix.
+func MarkdownZip(w io.Writer, books []BookAnnotations) error {
+	archive := zip.NewWriter(w)
+
+	used := map[string]int{}
+	for _, b := range books {
+		name := Filename(b.Book)
+		used[strings.ToLower(name)]++
+		if n := used[strings.ToLower(name)]; n > 1 {
+			name = fmt.Sprintf("%s (%d)", name, n)
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name + ".md", Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if err := Markdown(file, b); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// frontMatter writes a YAML key with the value as a double-quoted scalar. JSON
+// strings are valid YAML, which takes care of quotes and control characters.
+func frontMatter(sb *strings.Builder, key, value string) {
+	quoted, _ := json.Marshal(value)
+	sb.WriteString(key + ": " + string(quoted) + "\n")
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
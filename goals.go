@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	goalYearlyBooks  = "yearly_books"
+	goalDailyPages   = "daily_pages"
+	goalDailyMinutes = "daily_minutes"
+)
+
+var goalKinds = map[string]bool{
+	goalYearlyBooks:  true,
+	goalDailyPages:   true,
+	goalDailyMinutes: true,
+}
+
+type SetGoalRequest struct {
+	Kind   string `json:"kind" binding:"required"`
+	Target int32  `json:"target" binding:"required,min=1"`
+	Year   *int32 `json:"year" binding:"omitempty,min=1900,max=9999"`
+}
+
+type GoalProgress struct {
+	repository.ReadingGoal
+	Progress  int32 `json:"progress"`
+	Completed bool  `json:"completed"`
+}
+
+type GoalsResponse struct {
+	TimeZone      string         `json:"time_zone"`
+	Today         string         `json:"today"`
+	CurrentStreak int            `json:"current_streak"`
+	LongestStreak int            `json:"longest_streak"`
+	Goals         []GoalProgress `json:"goals"`
+}
+
+// userLocation returns the time zone the user's days and years are counted in.
+func userLocation(dbUser *repository.User) *time.Location {
+	loc, err := time.LoadLocation(dbUser.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// dayBounds returns the UTC instants at which the local day containing t
+// starts and ends.
+func dayBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	t = t.In(loc)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	return start.UTC(), start.AddDate(0, 0, 1).UTC()
+}
+
+// readingStreaks takes the sorted local dates on which the user read and
+// returns the current and the longest run of consecutive days. The current
+// streak is still alive if the last reading day was yesterday, since today
+// isn't over yet.
+func readingStreaks(days []string, today time.Time) (current, longest int) {
+	var last time.Time
+	run := 0
+	for _, raw := range days {
+		day, err := time.Parse(statsDateLayout, raw)
+		if err != nil {
+			continue
+		}
+		if run > 0 && day.Equal(last.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		last = day
+		longest = max(longest, run)
+	}
+
+	todayDate := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if run > 0 && (last.Equal(todayDate) || last.Equal(todayDate.AddDate(0, 0, -1))) {
+		current = run
+	}
+
+	return current, longest
+}
+
+func getGoalsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	loc := userLocation(dbUser)
+	now := time.Now().In(loc)
+	dayStart, dayEnd := dayBounds(now, loc)
+
+	days, err := cfg.Queries.GetReadingDays(c, repository.GetReadingDaysParams{TimeZone: loc.String(), UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	goals, err := cfg.Queries.GetReadingGoalsByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := GoalsResponse{TimeZone: loc.String(), Today: now.Format(statsDateLayout), Goals: make([]GoalProgress, 0, len(goals))}
+	response.CurrentStreak, response.LongestStreak = readingStreaks(days, now)
+
+	for _, goal := range goals {
+		var progress int32
+		switch goal.Kind {
+		case goalYearlyBooks:
+			yearStart := time.Date(int(*goal.Year), time.January, 1, 0, 0, 0, 0, loc)
+			progress, err = cfg.Queries.CountBooksFinishedBetween(c, repository.CountBooksFinishedBetweenParams{UserID: dbUser.ID, FromTime: yearStart.UTC(), ToTime: yearStart.AddDate(1, 0, 0).UTC()})
+		case goalDailyPages:
+			progress, err = cfg.Queries.GetPagesReadBetween(c, repository.GetPagesReadBetweenParams{UserID: dbUser.ID, FromTime: dayStart, ToTime: dayEnd})
+		case goalDailyMinutes:
+			var seconds int32
+			seconds, err = cfg.Queries.GetSecondsReadBetween(c, repository.GetSecondsReadBetweenParams{UserID: dbUser.ID, FromTime: dayStart, ToTime: dayEnd})
+			progress = seconds / 60
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		response.Goals = append(response.Goals, GoalProgress{ReadingGoal: goal, Progress: progress, Completed: progress >= goal.Target})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// setGoalHandler creates a goal or changes the target of an existing goal of
+// the same kind. Yearly goals default to the current year.
+func setGoalHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req SetGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !goalKinds[req.Kind] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported goal kind " + req.Kind})
+		return
+	}
+
+	if req.Kind == goalYearlyBooks {
+		if req.Year == nil {
+			year := int32(time.Now().In(userLocation(dbUser)).Year())
+			req.Year = &year
+		}
+	} else if req.Year != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year is only allowed for " + goalYearlyBooks})
+		return
+	}
+
+	goal, err := cfg.Queries.UpsertReadingGoal(c, repository.UpsertReadingGoalParams{ID: uuid.New(), UserID: dbUser.ID, Kind: req.Kind, Target: req.Target, Year: req.Year})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, goal)
+}
+
+func deleteGoalHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	goalID := c.Param("goal_id")
+	uuidGoalID, err := uuid.Parse(goalID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": goalID + " is not a valid uuid"})
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteReadingGoal(c, repository.DeleteReadingGoalParams{ID: uuidGoalID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,17 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/bookmeta"
 	"github.com/Hodik/noteshelf-be.git/repository"
 	"github.com/Hodik/noteshelf-be.git/setup"
 	"github.com/Hodik/noteshelf-be.git/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func meHandler(c *gin.Context) {
@@ -23,6 +35,40 @@ func meHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, dbUser)
 }
 
+type UpdateMeRequest struct {
+	TimeZone string `json:"time_zone" binding:"required,max=64"`
+}
+
+// updateMeHandler changes the user's settings. The time zone decides where
+// reading days start for goals and streaks.
+func updateMeHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req UpdateMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := time.LoadLocation(req.TimeZone); err != nil || req.TimeZone == "Local" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown time zone " + req.TimeZone})
+		return
+	}
+
+	user, err := cfg.Queries.SetUserTimeZone(c, repository.SetUserTimeZoneParams{TimeZone: req.TimeZone, ID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	auth.ForgetUser(cfg.Authenticator, dbUser.ID)
+
+	c.JSON(http.StatusOK, user)
+}
+
 type UploadBookRequest struct {
 	Name string `json:"name" binding:"required"`
 }
@@ -40,6 +86,10 @@ func generateUploadUrlHandler(c *gin.Context) {
 	}
 
 	key := dbUser.ID + "/" + req.Name
+	if !ownsS3Key(dbUser.ID, key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file name"})
+		return
+	}
 	url, err := utils.GeneratePresignedUploadURL(c, cfg.S3Client, cfg.BucketName, key, cfg.PresignedUrlExpirySeconds)
 
 	if err != nil {
@@ -50,13 +100,151 @@ func generateUploadUrlHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"presigned_url": url})
 }
 
+// ConfirmBookUploadRequest adds an uploaded file to the library. Title and
+// author are optional: left empty, they are read from the file once it has
+// been processed, and a book whose file has no title either is named after
+// the file, e.g. "Dune" for "user_1/Dune.epub".
 type ConfirmBookUploadRequest struct {
-	Title      string `json:"title" binding:"required"`
-	Author     string `json:"author"`
+	Title      string `json:"title" binding:"max=255"`
+	Author     string `json:"author" binding:"max=255"`
 	S3Key      string `json:"s3_key"`
 	TotalPages int    `json:"total_pages"`
 }
 
+// ownsS3Key reports whether key lies under the prefix upload URLs are issued
+// for to userID. Keys are easy to guess, so nothing else is trusted as the
+// user's file.
+func ownsS3Key(userID, key string) bool {
+	return strings.HasPrefix(key, userID+"/") && path.Clean(key) == key
+}
+
+// Sizes of the books columns that extracted metadata is stored in.
+const (
+	maxTitleLength    = 255
+	maxAuthorLength   = 255
+	maxLanguageLength = 35
+)
+
+// maxTotalPages is the largest page count taken from a book's file. Real books
+// are far below it; anything above comes from a broken or hostile file.
+const maxTotalPages = 100_000
+
+// truncateRunes shortens s to at most n characters without splitting one.
+func truncateRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := range s {
+		if n == 0 {
+			return strings.TrimSpace(s[:i])
+		}
+		n--
+	}
+	return s
+}
+
+const maxBookDownloadBytes = 256 << 20
+
+// downloadBook fetches an uploaded book for server side processing. Failures
+// are only logged so that files we cannot process can still be added with the
+// values the client sent.
+func downloadBook(ctx context.Context, s3Key string) []byte {
+	data, err := utils.DownloadObject(ctx, cfg.S3Client, cfg.BucketName, s3Key, maxBookDownloadBytes)
+	if err != nil {
+		log.Printf("failed to download %s for processing: %s", s3Key, err)
+		return nil
+	}
+
+	return data
+}
+
+func extractBookMetadata(data []byte, s3Key string) *bookmeta.Metadata {
+	if data == nil {
+		return nil
+	}
+
+	metadata, err := bookmeta.Extract(data)
+	if err != nil {
+		log.Printf("failed to extract metadata from %s: %s", s3Key, err)
+		return nil
+	}
+
+	return metadata
+}
+
+// applyBookMetadata stores what was extracted from the file of book. The file
+// is the source of truth for the page count and language; title and author
+// only replace the ones derived from the file name, never ones the client
+// sent.
+func applyBookMetadata(ctx context.Context, book repository.Book, metadata *bookmeta.Metadata, keepTitle, keepAuthor bool) (repository.Book, error) {
+	params := repository.SetBookMetadataParams{ID: book.ID}
+	if title := truncateRunes(metadata.Title, maxTitleLength); title != "" && !keepTitle {
+		params.Title = &title
+	}
+	if author := truncateRunes(metadata.Author, maxAuthorLength); author != "" && !keepAuthor {
+		params.Author = &author
+	}
+	if metadata.Pages > 0 && metadata.Pages <= maxTotalPages {
+		pages := int32(metadata.Pages)
+		params.TotalPages = &pages
+	}
+	if language := truncateRunes(metadata.Language, maxLanguageLength); language != "" {
+		params.Language = &language
+	}
+	format := string(metadata.Format)
+	params.Format = &format
+
+	tx, err := cfg.DBPool.Begin(ctx)
+	if err != nil {
+		return book, err
+	}
+	defer tx.Rollback(ctx)
+
+	localQueries := repository.New(tx)
+
+	updatedBook, err := localQueries.SetBookMetadata(ctx, params)
+	if err != nil {
+		return book, err
+	}
+
+	if updatedBook.TotalPages != book.TotalPages {
+		if err := recomputeReadingProgress(ctx, localQueries, updatedBook); err != nil {
+			return book, err
+		}
+	}
+
+	return updatedBook, tx.Commit(ctx)
+}
+
+// processUploadedBook downloads the file of a newly added book once, fills in
+// its metadata, and generates its covers, search index and KOReader hash. It
+// runs as a background job, so the upload is confirmed without waiting for a
+// file of up to maxBookDownloadBytes.
+func processUploadedBook(ctx context.Context, book repository.Book, keepTitle, keepAuthor bool) error {
+	if book.S3Key == nil {
+		return nil
+	}
+
+	data := downloadBook(ctx, *book.S3Key)
+	if data == nil {
+		// Files too large to process can still be hashed by ranged reads.
+		return hashBookForKOReader(ctx, book)
+	}
+
+	if err := storeKOReaderHash(ctx, book, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+
+	if metadata := extractBookMetadata(data, *book.S3Key); metadata != nil {
+		var err error
+		if book, err = applyBookMetadata(ctx, book, metadata, keepTitle, keepAuthor); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(generateBookCovers(ctx, book, data), indexBookContent(ctx, book, data))
+}
+
 func confirmBookUploadHandler(c *gin.Context) {
 	dbUser, err := auth.GetDBUserFromRequest(c)
 	if err != nil {
@@ -70,11 +258,21 @@ func confirmBookUploadHandler(c *gin.Context) {
 		return
 	}
 
+	if !ownsS3Key(dbUser.ID, req.S3Key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "s3 key wasn't issued to this user"})
+		return
+	}
+
 	if !utils.KeyExists(c, cfg.S3Client, cfg.BucketName, req.S3Key) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "s3 key doesn't exists"})
 		return
 	}
 
+	keepTitle, keepAuthor := req.Title != "", req.Author != ""
+	if req.Title == "" {
+		req.Title = truncateRunes(strings.TrimSuffix(path.Base(req.S3Key), path.Ext(req.S3Key)), maxTitleLength)
+	}
+
 	tx, err := cfg.DBPool.Begin(c)
 	defer tx.Rollback(c)
 
@@ -85,7 +283,12 @@ func confirmBookUploadHandler(c *gin.Context) {
 		return
 	}
 
-	book, err := localQueries.CreateBook(c, repository.CreateBookParams{ID: uuid.New(), OwnerID: dbUser.ID, S3Key: req.S3Key, TotalPages: int32(req.TotalPages), Title: req.Title})
+	var author *string
+	if req.Author != "" {
+		author = &req.Author
+	}
+
+	book, err := localQueries.CreateBook(c, repository.CreateBookParams{ID: uuid.New(), OwnerID: dbUser.ID, S3Key: &req.S3Key, TotalPages: int32(req.TotalPages), Title: req.Title, Author: author})
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == setup.UniqueViolationCode {
@@ -106,56 +309,358 @@ func confirmBookUploadHandler(c *gin.Context) {
 		return
 	}
 
+	runBackgroundJob("process "+book.ID.String(), func(ctx context.Context) error {
+		return processUploadedBook(ctx, book, keepTitle, keepAuthor)
+	})
+
 	c.JSON(http.StatusOK, book)
 }
 
-func getBookHandler(c *gin.Context) {
+// getBookParam loads the book referenced by the book_id path parameter. The
+// request is aborted when ok is false.
+func getBookParam(c *gin.Context) (book repository.Book, ok bool) {
 	bookID := c.Param("book_id")
+	uuidBookID, err := uuid.Parse(bookID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": bookID + " is not a valid uuid"})
+		return book, false
+	}
+
+	book, err = cfg.Queries.GetBookByID(c, uuidBookID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return book, false
+		} else {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return book, false
+		}
+	}
+
+	return book, true
+}
+
+// getOwnedBook loads the book referenced by the book_id path parameter and
+// checks that dbUser owns it. The request is aborted when ok is false.
+func getOwnedBook(c *gin.Context, dbUser *repository.User) (book repository.Book, ok bool) {
+	book, ok = getBookParam(c)
+	if !ok {
+		return book, false
+	}
+
+	if book.OwnerID != dbUser.ID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not an owner"})
+		return book, false
+	}
+
+	return book, true
+}
+
+// getReadableBook is like getOwnedBook but also lets through users the book
+// has been shared with.
+func getReadableBook(c *gin.Context, dbUser *repository.User) (book repository.Book, ok bool) {
+	book, ok = getBookParam(c)
+	if !ok {
+		return book, false
+	}
+
+	if book.OwnerID == dbUser.ID {
+		return book, true
+	}
+
+	if _, err := cfg.Queries.GetBookShare(c, repository.GetBookShareParams{BookID: book.ID, UserID: dbUser.ID}); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "book is not shared with you"})
+			return book, false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return book, false
+	}
+
+	return book, true
+}
+
+func getBookHandler(c *gin.Context) {
 	dbUser, err := auth.GetDBUserFromRequest(c)
 	if err != nil {
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	uuidBookID, err := uuid.Parse(bookID)
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	// Books imported from elsewhere may have no file to read.
+	var readURL *string
+	if book.S3Key != nil {
+		url, err := utils.GeneratePresignedReadURL(cfg.CloudfrontUrl, *book.S3Key, cfg.KeyPairID, int(cfg.PresignedUrlExpirySeconds), cfg.PrivateSignKey)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		readURL = &url
+	}
+
+	coverURLs, err := signedCoverURLs(book)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": bookID + " is not a valid uuid"})
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	book, err := cfg.Queries.GetBookByID(c, uuidBookID)
+	response := gin.H{"book": book, "read_url": readURL, "cover_urls": coverURLs}
+
+	for _, include := range strings.Split(c.Query("include"), ",") {
+		switch strings.TrimSpace(include) {
+		case "":
+		case "bookmarks":
+			bookmarks, err := cfg.Queries.GetBookmarksByBookID(c, repository.GetBookmarksByBookIDParams{BookID: book.ID, UserID: dbUser.ID})
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			response["bookmarks"] = bookmarks
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include must be a comma-separated list of: bookmarks"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateBookRequest is a partial update: fields left out are kept. Author,
+// description and isbn are cleared by sending null or an empty string.
+type UpdateBookRequest struct {
+	Title       *string `json:"title" binding:"omitempty,min=1,max=255"`
+	Author      *string `json:"author" binding:"omitempty,max=255"`
+	TotalPages  *int32  `json:"total_pages" binding:"omitempty,min=0"`
+	Description *string `json:"description"`
+	Language    *string `json:"language" binding:"omitempty,max=35"`
+	ISBN        *string `json:"isbn"`
+}
+
+// UnmarshalJSON turns an explicit null in a clearable field into an empty
+// string, which UpdateBook stores as NULL. A plain *string can't tell null
+// from a missing field.
+func (r *UpdateBookRequest) UnmarshalJSON(data []byte) error {
+	type plain UpdateBookRequest
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name, field := range map[string]**string{"author": &r.Author, "description": &r.Description, "isbn": &r.ISBN} {
+		if raw, ok := fields[name]; ok && string(raw) == "null" {
+			*field = new(string)
+		}
+	}
+	return nil
+}
+
+// normalizeISBN strips separators from an ISBN-10 or ISBN-13 and validates its
+// check digit.
+func normalizeISBN(isbn string) (string, error) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+
+	switch len(digits) {
+	case 10:
+		sum := 0
+		for i, r := range digits {
+			var v int
+			switch {
+			case r >= '0' && r <= '9':
+				v = int(r - '0')
+			case r == 'X' && i == 9:
+				v = 10
+			default:
+				return "", errors.New("isbn contains invalid characters")
+			}
+			sum += v * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", errors.New("isbn check digit mismatch")
+		}
+	case 13:
+		sum := 0
+		for i, r := range digits {
+			if r < '0' || r > '9' {
+				return "", errors.New("isbn contains invalid characters")
+			}
+			v := int(r - '0')
+			if i%2 == 1 {
+				v *= 3
+			}
+			sum += v
+		}
+		if sum%10 != 0 {
+			return "", errors.New("isbn check digit mismatch")
+		}
+	default:
+		return "", errors.New("isbn must have 10 or 13 digits")
+	}
+
+	return digits, nil
+}
+
+func updateBookHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req UpdateBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ISBN != nil && *req.ISBN != "" {
+		isbn, err := normalizeISBN(*req.ISBN)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
-		} else {
+		}
+		req.ISBN = &isbn
+	}
+
+	book, ok := getOwnedBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	updatedBook, err := localQueries.UpdateBook(c, repository.UpdateBookParams{
+		Title:       req.Title,
+		Author:      req.Author,
+		TotalPages:  req.TotalPages,
+		Description: req.Description,
+		Language:    req.Language,
+		Isbn:        req.ISBN,
+		ID:          book.ID,
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if updatedBook.TotalPages != book.TotalPages {
+		if err := recomputeReadingProgress(c, localQueries, updatedBook); err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 	}
 
-	if book.OwnerID != dbUser.ID {
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not an owner"})
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-	readURL, err := utils.GeneratePresignedReadURL(cfg.CloudfrontUrl, book.S3Key, cfg.KeyPairID, int(cfg.PresignedUrlExpirySeconds), cfg.PrivateSignKey)
+
+	c.JSON(http.StatusOK, updatedBook)
+}
+
+func deleteBookHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
 	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getOwnedBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	if err := localQueries.DeleteReadingProgressByBookID(c, book.ID); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := localQueries.DeleteBook(c, book.ID); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The files are queued for deletion together with the rows and only
+	// removed once those are committed, so a storage failure can't leave a
+	// book whose file is missing and a crash can't forget a file. Keys
+	// outside the owner's prefix belong to someone else and are left alone.
+	var keys []string
+	for _, key := range []*string{book.S3Key, book.CoverSmallKey, book.CoverMediumKey, book.CoverLargeKey} {
+		if key == nil {
+			continue
+		}
+		if !ownsS3Key(book.OwnerID, *key) {
+			log.Printf("not deleting s3 object %s of book %s outside its owner's prefix", *key, book.ID)
+			continue
+		}
+		keys = append(keys, *key)
+	}
+	if err := queueS3Deletions(c, localQueries, keys); err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"book": book, "read_url": readURL})
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	wakeS3DeletionWorker()
+
+	c.Status(http.StatusNoContent)
 }
 
 type UpdateReadingProgressRequest struct {
-	CurrentPage int `json:"current_page" binding:"required"`
+	DeviceID    string     `json:"device_id" binding:"max=100"`
+	DeviceName  *string    `json:"device_name" binding:"omitempty,max=100"`
+	CurrentPage int32      `json:"current_page" binding:"omitempty,min=1"`
+	Locator     *Locator   `json:"locator"`
+	ReadAt      *time.Time `json:"read_at"`
+}
+
+// progressUpdate resolves the request into an update. Clients predating
+// device sync send neither device_id nor read_at; their reads count as
+// happening now on a shared unknown device.
+func (req UpdateReadingProgressRequest) progressUpdate(now time.Time) progressUpdate {
+	update := progressUpdate{DeviceID: req.DeviceID, DeviceName: req.DeviceName, CurrentPage: req.CurrentPage, Locator: req.Locator, ReadAt: now}
+	if update.DeviceID == "" {
+		update.DeviceID = unknownDeviceID
+	}
+	// Offline devices report when the page was actually read. Clock skew must
+	// not put reads in the future.
+	if req.ReadAt != nil && req.ReadAt.Before(now) {
+		update.ReadAt = req.ReadAt.UTC()
+	}
+	return update
 }
 
 func updateReadingProgressHandler(c *gin.Context) {
-	bookID := c.Param("book_id")
 	dbUser, err := auth.GetDBUserFromRequest(c)
 	if err != nil {
 		c.AbortWithStatus(http.StatusInternalServerError)
+		return
 	}
 
 	var req UpdateReadingProgressRequest
@@ -164,43 +669,292 @@ func updateReadingProgressHandler(c *gin.Context) {
 		return
 	}
 
-	uuidBookID, err := uuid.Parse(bookID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if req.CurrentPage == 0 && (req.Locator == nil || req.Locator.Locations.TotalProgression == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either current_page or a locator with totalProgression is required"})
 		return
 	}
 
-	book, err := cfg.Queries.GetBookByID(c, uuidBookID)
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
 
-	var percentageComplete float64
-	if book.TotalPages < 1 {
-		percentageComplete = 0.0
-	} else {
-		percentageComplete = float64(req.CurrentPage) / float64(book.TotalPages) * 100
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
 	}
+	defer tx.Rollback(c)
 
-	readingProgress, err := cfg.Queries.UpdateReadingProgress(c, repository.UpdateReadingProgressParams{CurrentPage: int32(req.CurrentPage), PercentageComplete: percentageComplete, BookID: uuidBookID, UserID: dbUser.ID})
+	localQueries := repository.New(tx)
+	update := req.progressUpdate(time.Now().UTC())
 
+	readingProgress, err := saveReadingProgress(c, localQueries, book, dbUser.ID, update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, readingProgress)
+	conflict, err := findPositionConflict(c, localQueries, book, dbUser.ID, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, ReadingProgressResponse{ReadingProgress: readingProgress, Conflict: conflict})
+}
+
+type LibraryBook struct {
+	Book               repository.Book   `json:"book"`
+	CurrentPage        *int32            `json:"current_page"`
+	PercentageComplete pgtype.Numeric    `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp  `json:"last_read_at"`
+	Status             *string           `json:"status"`
+	CoverURLs          map[string]string `json:"cover_urls,omitempty"`
+}
+
+const (
+	defaultLibraryPageSize = 50
+	maxLibraryPageSize     = 200
+)
+
+// librarySortDescending maps every supported sort option to its default
+// direction.
+var librarySortDescending = map[string]bool{
+	"added_at":            true,
+	"last_read_at":        true,
+	"percentage_complete": true,
+	"title":               false,
+	"author":              false,
+}
+
+// libraryCursor is the position of the last book on a page. It is handed to
+// clients as opaque base64 and only valid for the sort it was created with.
+type libraryCursor struct {
+	Sort       string    `json:"s"`
+	Descending bool      `json:"d"`
+	Key        string    `json:"k"`
+	ID         uuid.UUID `json:"id"`
+}
+
+func (cursor libraryCursor) encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLibraryCursor(s string) (libraryCursor, error) {
+	var cursor libraryCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, errors.New("invalid cursor")
+	}
+	return cursor, nil
+}
+
+// postgresTimestampLayout parses timestamps as Postgres prints them.
+const postgresTimestampLayout = "2006-01-02 15:04:05.999999999"
+
+// validCursorKey reports whether key can be cast to the type sort pages by,
+// so a tampered cursor is a bad request rather than a query error.
+func validCursorKey(sort, key string) bool {
+	switch sort {
+	case "added_at", "last_read_at":
+		_, err := time.Parse(postgresTimestampLayout, key)
+		return err == nil
+	case "percentage_complete":
+		_, err := strconv.ParseFloat(key, 64)
+		return err == nil
+	}
+	return true
+}
+
+// likeEscaper makes user input match literally in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// libraryParams and libraryRow are the parameters and rows shared by the
+// GetLibraryPageBy* queries. There is one query per sort, so each pages along
+// an index instead of sorting the whole library.
+type (
+	libraryParams repository.GetLibraryPageByTitleParams
+	libraryRow    repository.GetLibraryPageByTitleRow
+)
+
+func getLibraryPage(ctx context.Context, sort string, params libraryParams) ([]libraryRow, error) {
+	var page []libraryRow
+	switch sort {
+	case "title":
+		rows, err := cfg.Queries.GetLibraryPageByTitle(ctx, repository.GetLibraryPageByTitleParams(params))
+		for _, row := range rows {
+			page = append(page, libraryRow(row))
+		}
+		return page, err
+	case "author":
+		rows, err := cfg.Queries.GetLibraryPageByAuthor(ctx, repository.GetLibraryPageByAuthorParams(params))
+		for _, row := range rows {
+			page = append(page, libraryRow(row))
+		}
+		return page, err
+	case "last_read_at":
+		rows, err := cfg.Queries.GetLibraryPageByLastReadAt(ctx, repository.GetLibraryPageByLastReadAtParams(params))
+		for _, row := range rows {
+			page = append(page, libraryRow(row))
+		}
+		return page, err
+	case "percentage_complete":
+		rows, err := cfg.Queries.GetLibraryPageByPercentageComplete(ctx, repository.GetLibraryPageByPercentageCompleteParams(params))
+		for _, row := range rows {
+			page = append(page, libraryRow(row))
+		}
+		return page, err
+	default:
+		rows, err := cfg.Queries.GetLibraryPageByAddedAt(ctx, repository.GetLibraryPageByAddedAtParams(params))
+		for _, row := range rows {
+			page = append(page, libraryRow(row))
+		}
+		return page, err
+	}
 }
 
+// nextPageLink is the request's URL with cursor swapped in, for the Link
+// header.
+func nextPageLink(u *url.URL, cursor string) string {
+	next := *u
+	query := next.Query()
+	query.Set("cursor", cursor)
+	next.RawQuery = query.Encode()
+	return "<" + next.RequestURI() + `>; rel="next"`
+}
+
+// getLibraryHandler lists the readable books as a JSON array. Without limit
+// or cursor it returns all of them, as it always has; with either it returns
+// one page and puts the cursor of the next in the X-Next-Cursor and Link
+// headers.
 func getLibraryHandler(c *gin.Context) {
 	dbUser, err := auth.GetDBUserFromRequest(c)
 	if err != nil {
 		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	sort := c.DefaultQuery("sort", "added_at")
+	descending, ok := librarySortDescending[sort]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported sort " + sort})
+		return
+	}
+
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	// Clients from before paging get the whole library.
+	pageSize := math.MaxInt32 - 1
+	if c.Query("cursor") != "" {
+		pageSize = defaultLibraryPageSize
+	}
+	if limit := c.Query("limit"); limit != "" {
+		pageSize, err = strconv.Atoi(limit)
+		if err != nil || pageSize < 1 || pageSize > maxLibraryPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxLibraryPageSize)})
+			return
+		}
+	}
+
+	params := libraryParams{
+		UserID:     dbUser.ID,
+		Descending: descending,
+		// One extra row tells us whether there is a next page.
+		PageSize: int32(pageSize + 1),
+	}
+
+	if status := c.Query("status"); status != "" {
+		if legacy, ok := legacyReadingStatuses[status]; ok {
+			status = legacy
+		}
+		if !readingStatuses[status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported status " + status})
+			return
+		}
+		params.Status = &status
+	}
+
+	if author := c.Query("author"); author != "" {
+		author = likeEscaper.Replace(author)
+		params.Author = &author
+	}
+
+	if shelfID := c.Query("shelf_id"); shelfID != "" {
+		uuidShelfID, err := uuid.Parse(shelfID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": shelfID + " is not a valid uuid"})
+			return
+		}
+		if _, err := cfg.Queries.GetShelfByID(c, repository.GetShelfByIDParams{ID: uuidShelfID, UserID: dbUser.ID}); err != nil {
+			if strings.Contains(err.Error(), "no rows") {
+				c.JSON(http.StatusNotFound, gin.H{"error": "shelf not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		params.ShelfID = pgtype.UUID{Bytes: uuidShelfID, Valid: true}
 	}
 
-	books, err := cfg.Queries.GetBooksByOwnerID(c, dbUser.ID)
+	if rawCursor := c.Query("cursor"); rawCursor != "" {
+		cursor, err := decodeLibraryCursor(rawCursor)
+		if err != nil || cursor.Sort != sort || cursor.Descending != descending || !validCursorKey(sort, cursor.Key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor does not match the requested sort"})
+			return
+		}
+		params.CursorKey = &cursor.Key
+		params.CursorID = pgtype.UUID{Bytes: cursor.ID, Valid: true}
+	}
 
+	books, err := getLibraryPage(c, sort, params)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, books)
+	if len(books) > pageSize {
+		books = books[:pageSize]
+		last := books[len(books)-1]
+		nextCursor := libraryCursor{Sort: sort, Descending: descending, Key: last.SortKey, ID: last.Book.ID}.encode()
+		c.Header("X-Next-Cursor", nextCursor)
+		c.Header("Link", nextPageLink(c.Request.URL, nextCursor))
+	}
+
+	library := make([]LibraryBook, 0, len(books))
+	for _, book := range books {
+		coverURLs, err := signedCoverURLs(book.Book)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		library = append(library, LibraryBook{
+			Book:               book.Book,
+			CurrentPage:        book.CurrentPage,
+			PercentageComplete: book.PercentageComplete,
+			LastReadAt:         book.LastReadAt,
+			Status:             book.Status,
+			CoverURLs:          coverURLs,
+		})
+	}
+
+	c.JSON(http.StatusOK, library)
 }
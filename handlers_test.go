@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "short", s: "Dune", n: 10, want: "Dune"},
+		{name: "exact", s: "Dune", n: 4, want: "Dune"},
+		{name: "ascii", s: "Dune Messiah", n: 4, want: "Dune"},
+		{name: "trailing space", s: "Dune Messiah", n: 5, want: "Dune"},
+		{name: "multibyte", s: "Война и мир", n: 5, want: "Война"},
+		{name: "multibyte under limit in runes", s: "Война", n: 5, want: "Война"},
+		{name: "emoji", s: "📚📖📕", n: 2, want: "📚📖"},
+		{name: "empty", s: "", n: 3, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateRunes(tt.s, tt.n); got != tt.want {
+				t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateReadingProgressRequestDefaults(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour).In(time.FixedZone("CET", 3600))
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		req        UpdateReadingProgressRequest
+		wantDevice string
+		wantReadAt time.Time
+	}{
+		{name: "old client", req: UpdateReadingProgressRequest{CurrentPage: 12}, wantDevice: unknownDeviceID, wantReadAt: now},
+		{name: "offline read", req: UpdateReadingProgressRequest{DeviceID: "kobo", CurrentPage: 12, ReadAt: &earlier}, wantDevice: "kobo", wantReadAt: now.Add(-time.Hour)},
+		{name: "clock skew", req: UpdateReadingProgressRequest{DeviceID: "kobo", CurrentPage: 12, ReadAt: &later}, wantDevice: "kobo", wantReadAt: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := tt.req.progressUpdate(now)
+			if update.DeviceID != tt.wantDevice || !update.ReadAt.Equal(tt.wantReadAt) || update.ReadAt.Location() != time.UTC {
+				t.Errorf("progressUpdate() = %q at %v, want %q at %v", update.DeviceID, update.ReadAt, tt.wantDevice, tt.wantReadAt)
+			}
+		})
+	}
+}
+
+func TestOwnsS3Key(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "user_1/Dune.epub", want: true},
+		{key: "user_1/covers/0b3c-small.jpg", want: true},
+		{key: "user_2/Dune.epub"},
+		{key: "user_10/Dune.epub"},
+		{key: "user_1"},
+		{key: "Dune.epub"},
+		{key: "user_1/../user_2/Dune.epub"},
+		{key: "user_1//Dune.epub"},
+	}
+
+	for _, tt := range tests {
+		if got := ownsS3Key("user_1", tt.key); got != tt.want {
+			t.Errorf("ownsS3Key(user_1, %q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestValidCursorKey(t *testing.T) {
+	tests := []struct {
+		sort, key string
+		want      bool
+	}{
+		{sort: "added_at", key: "2024-03-01 12:00:00.123456", want: true},
+		{sort: "last_read_at", key: "1970-01-01 00:00:00", want: true},
+		{sort: "added_at", key: "20240301120000123456"},
+		{sort: "percentage_complete", key: "42.50", want: true},
+		{sort: "percentage_complete", key: "'; DROP TABLE books"},
+		{sort: "title", key: "anything goes", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := validCursorKey(tt.sort, tt.key); got != tt.want {
+			t.Errorf("validCursorKey(%q, %q) = %v, want %v", tt.sort, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLikeEscaper(t *testing.T) {
+	if got, want := likeEscaper.Replace(`100%_sure\`), `100\%\_sure\\`; got != want {
+		t.Errorf("likeEscaper.Replace() = %q, want %q", got, want)
+	}
+}
+
+func TestNextPageLink(t *testing.T) {
+	u, err := url.Parse("/books?sort=title&limit=20&cursor=old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nextPageLink(u, "next"), `</books?cursor=next&limit=20&sort=title>; rel="next"`; got != want {
+		t.Errorf("nextPageLink() = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateBookRequestClearsFields(t *testing.T) {
+	var req UpdateBookRequest
+	if err := json.Unmarshal([]byte(`{"title":"Dune","author":null,"isbn":""}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Title == nil || *req.Title != "Dune" {
+		t.Errorf("Title = %v, want Dune", req.Title)
+	}
+	if req.Author == nil || *req.Author != "" {
+		t.Errorf("Author = %v, want an empty string for null", req.Author)
+	}
+	if req.ISBN == nil || *req.ISBN != "" {
+		t.Errorf("ISBN = %v, want an empty string", req.ISBN)
+	}
+	if req.Description != nil {
+		t.Errorf("Description = %q, want nil for a missing field", *req.Description)
+	}
+}
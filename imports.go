@@ -0,0 +1,354 @@
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/kindle"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxKindleClippingsSize = 32 << 20
+
+type KindleImportBook struct {
+	BookID     uuid.UUID `json:"book_id"`
+	Title      string    `json:"title"`
+	Author     *string   `json:"author"`
+	Highlights int       `json:"highlights"`
+	Notes      int       `json:"notes"`
+	Bookmarks  int       `json:"bookmarks"`
+}
+
+type KindleImportSkip struct {
+	Title    string `json:"title"`
+	Kind     string `json:"kind,omitempty"`
+	Location *int   `json:"location,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+type KindleImportReport struct {
+	Matched []*KindleImportBook `json:"matched"`
+	Created []*KindleImportBook `json:"created"`
+	Skipped []KindleImportSkip  `json:"skipped"`
+}
+
+// kindleBook is a title/author pair from the clippings file with everything
+// clipped from it.
+type kindleBook struct {
+	title     string
+	author    string
+	clippings []kindle.Clipping
+}
+
+// groupClippings groups clippings by book. Titles and authors are cut to what
+// a book can hold first, so a runaway line in the file can't make matching
+// expensive.
+func groupClippings(clippings []kindle.Clipping) []*kindleBook {
+	var books []*kindleBook
+	index := map[[2]string]*kindleBook{}
+	for _, clipping := range clippings {
+		key := [2]string{truncateRunes(clipping.Title, maxTitleLength), truncateRunes(clipping.Author, maxAuthorLength)}
+		book, ok := index[key]
+		if !ok {
+			book = &kindleBook{title: key[0], author: key[1]}
+			index[key] = book
+			books = append(books, book)
+		}
+		book.clippings = append(book.clippings, clipping)
+	}
+	return books
+}
+
+// supersededHighlights marks highlights a reader later extended. The Kindle
+// keeps both entries, and the later one starts at the same location and
+// contains the earlier text.
+func supersededHighlights(clippings []kindle.Clipping) map[int]bool {
+	superseded := map[int]bool{}
+	for i, earlier := range clippings {
+		if earlier.Kind != kindle.KindHighlight || earlier.LocationStart == nil {
+			continue
+		}
+		for _, later := range clippings[i+1:] {
+			if later.Kind == kindle.KindHighlight && later.LocationStart != nil && *later.LocationStart == *earlier.LocationStart &&
+				strings.Contains(later.Text, earlier.Text) {
+				superseded[i] = true
+				break
+			}
+		}
+	}
+	return superseded
+}
+
+// kindlePage keeps a Kindle page number only where it means the same thing
+// here. EPUB pages are spine items, and a PDF only has the pages it has.
+func kindlePage(book repository.Book, clipping kindle.Clipping) *int32 {
+	page := optionalInt32(clipping.Page)
+	if page == nil {
+		return nil
+	}
+	if book.Format != nil && (*book.Format == "epub" || *page > book.TotalPages) {
+		return nil
+	}
+	return page
+}
+
+// optionalInt32 drops values that don't fit the column rather than letting
+// them wrap.
+func optionalInt32(v *int) *int32 {
+	if v == nil || *v < math.MinInt32 || *v > math.MaxInt32 {
+		return nil
+	}
+	n := int32(*v)
+	return &n
+}
+
+// readKindleClippings accepts the file either as a multipart upload in the
+// file field or as the raw request body.
+func readKindleClippings(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxKindleClippingsSize)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	return io.ReadAll(c.Request.Body)
+}
+
+// importKindleHandler imports the highlights, notes and bookmarks of a Kindle
+// "My Clippings.txt" file. Clippings go to the readable book that best
+// matches their title and author; books without a match are created with
+// metadata only. Importing the same file again only adds what is new.
+func importKindleHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	data, err := readKindleClippings(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clippings, invalid, err := kindle.Parse(strings.NewReader(string(data)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := KindleImportReport{Matched: []*KindleImportBook{}, Created: []*KindleImportBook{}, Skipped: []KindleImportSkip{}}
+	for _, entry := range invalid {
+		report.Skipped = append(report.Skipped, KindleImportSkip{Title: entry.Title, Reason: entry.Reason})
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	books, err := localQueries.GetReadableBooksByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	candidates := make([]kindle.Candidate, 0, len(books))
+	for _, book := range books {
+		candidates = append(candidates, kindle.Candidate{Title: book.Title, Author: deref(book.Author)})
+	}
+
+	summaries := map[uuid.UUID]*KindleImportBook{}
+	now := time.Now().UTC()
+
+	for _, group := range groupClippings(clippings) {
+		var book repository.Book
+		created := false
+		if i := kindle.Match(group.title, group.author, candidates); i >= 0 {
+			book = books[i]
+		} else {
+			var author *string
+			if group.author != "" {
+				author = &group.author
+			}
+			book, err = localQueries.CreateBook(c, repository.CreateBookParams{ID: uuid.New(), OwnerID: dbUser.ID, Title: group.title, Author: author})
+			if err == nil {
+				_, err = localQueries.CreateReadingProgress(c, repository.CreateReadingProgressParams{BookID: book.ID, UserID: dbUser.ID})
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			books = append(books, book)
+			candidates = append(candidates, kindle.Candidate{Title: book.Title, Author: deref(book.Author)})
+			created = true
+		}
+
+		summary, ok := summaries[book.ID]
+		if !ok {
+			summary = &KindleImportBook{BookID: book.ID, Title: book.Title, Author: book.Author}
+			summaries[book.ID] = summary
+			if created {
+				report.Created = append(report.Created, summary)
+			} else {
+				report.Matched = append(report.Matched, summary)
+			}
+		}
+
+		skip := func(clipping kindle.Clipping, reason string) {
+			report.Skipped = append(report.Skipped, KindleImportSkip{Title: group.title, Kind: string(clipping.Kind), Location: clipping.LocationStart, Reason: reason})
+		}
+
+		// Highlights go in first so notes can be attached to them.
+		superseded := supersededHighlights(group.clippings)
+		for i, clipping := range group.clippings {
+			if clipping.Kind != kindle.KindHighlight {
+				continue
+			}
+			if superseded[i] {
+				skip(clipping, "replaced by a longer highlight")
+				continue
+			}
+
+			exists, err := localQueries.HighlightExists(c, repository.HighlightExistsParams{BookID: book.ID, UserID: dbUser.ID, SelectedText: clipping.Text, LocationStart: optionalInt32(clipping.LocationStart)})
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if exists {
+				skip(clipping, "already imported")
+				continue
+			}
+
+			createdAt := now
+			if clipping.AddedAt != nil {
+				createdAt = *clipping.AddedAt
+			}
+			if _, err := localQueries.CreateImportedHighlight(c, repository.CreateImportedHighlightParams{
+				ID:            uuid.New(),
+				BookID:        book.ID,
+				UserID:        dbUser.ID,
+				PageNumber:    kindlePage(book, clipping),
+				LocationStart: optionalInt32(clipping.LocationStart),
+				LocationEnd:   optionalInt32(clipping.LocationEnd),
+				SelectedText:  clipping.Text,
+				Color:         defaultAnnotationColor,
+				CreatedAt:     createdAt,
+			}); err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			summary.Highlights++
+		}
+
+		for _, clipping := range group.clippings {
+			switch clipping.Kind {
+			case kindle.KindNote:
+				location := optionalInt32(clipping.LocationStart)
+				if location == nil {
+					skip(clipping, "note has no location")
+					continue
+				}
+
+				highlight, err := localQueries.GetHighlightAtLocation(c, repository.GetHighlightAtLocationParams{BookID: book.ID, UserID: dbUser.ID, Location: *location})
+				if err != nil {
+					if strings.Contains(err.Error(), "no rows") {
+						skip(clipping, "no highlight at this location")
+						continue
+					}
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+
+				note := clipping.Text
+				if highlight.Note != nil && *highlight.Note != "" {
+					if strings.Contains(*highlight.Note, clipping.Text) {
+						skip(clipping, "already imported")
+						continue
+					}
+					note = *highlight.Note + "\n\n" + clipping.Text
+				}
+				if _, err := localQueries.UpdateHighlight(c, repository.UpdateHighlightParams{Note: &note, ID: highlight.ID, BookID: book.ID, UserID: dbUser.ID}); err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				summary.Notes++
+
+			case kindle.KindBookmark:
+				page := kindlePage(book, clipping)
+				location := optionalInt32(clipping.LocationStart)
+				if page == nil && location == nil {
+					skip(clipping, "bookmark has no position")
+					continue
+				}
+
+				exists, err := localQueries.BookmarkExists(c, repository.BookmarkExistsParams{BookID: book.ID, UserID: dbUser.ID, PageNumber: page, Location: location})
+				if err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if exists {
+					skip(clipping, "already imported")
+					continue
+				}
+
+				label := "Kindle bookmark"
+				if location != nil {
+					label += ", location " + strconv.Itoa(int(*location))
+				} else {
+					label += ", page " + strconv.Itoa(int(*page))
+				}
+				createdAt := now
+				if clipping.AddedAt != nil {
+					createdAt = *clipping.AddedAt
+				}
+				if _, err := localQueries.CreateImportedBookmark(c, repository.CreateImportedBookmarkParams{
+					ID:         uuid.New(),
+					BookID:     book.ID,
+					UserID:     dbUser.ID,
+					PageNumber: page,
+					Location:   location,
+					Label:      label,
+					CreatedAt:  createdAt,
+				}); err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				summary.Bookmarks++
+			}
+		}
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
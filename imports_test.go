@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Hodik/noteshelf-be.git/kindle"
+	"github.com/Hodik/noteshelf-be.git/repository"
+)
+
+func kindleHighlight(location int, text string) kindle.Clipping {
+	return kindle.Clipping{Kind: kindle.KindHighlight, LocationStart: &location, Text: text}
+}
+
+func TestSupersededHighlights(t *testing.T) {
+	tests := []struct {
+		name      string
+		clippings []kindle.Clipping
+		want      map[int]bool
+	}{
+		{
+			name: "extended highlight replaces the earlier one",
+			clippings: []kindle.Clipping{
+				kindleHighlight(10, "I must not fear."),
+				kindleHighlight(10, "I must not fear. Fear is the mind-killer."),
+			},
+			want: map[int]bool{0: true},
+		},
+		{
+			name: "chain of extensions keeps only the last",
+			clippings: []kindle.Clipping{
+				kindleHighlight(10, "I must"),
+				kindleHighlight(10, "I must not fear."),
+				kindleHighlight(10, "I must not fear. Fear is the mind-killer."),
+			},
+			want: map[int]bool{0: true, 1: true},
+		},
+		{
+			name: "shortened highlight keeps both",
+			clippings: []kindle.Clipping{
+				kindleHighlight(10, "I must not fear. Fear is the mind-killer."),
+				kindleHighlight(10, "I must not fear."),
+			},
+			want: map[int]bool{},
+		},
+		{
+			name: "different location keeps both",
+			clippings: []kindle.Clipping{
+				kindleHighlight(10, "fear"),
+				kindleHighlight(11, "fear is the mind-killer"),
+			},
+			want: map[int]bool{},
+		},
+		{
+			name: "notes and highlights without a location are ignored",
+			clippings: []kindle.Clipping{
+				{Kind: kindle.KindHighlight, Text: "fear"},
+				{Kind: kindle.KindHighlight, Text: "fear is the mind-killer"},
+				{Kind: kindle.KindNote, LocationStart: kindleHighlight(10, "").LocationStart, Text: "fear"},
+				kindleHighlight(10, "fear is the mind-killer"),
+			},
+			want: map[int]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := supersededHighlights(tt.clippings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("supersededHighlights() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupClippings(t *testing.T) {
+	clippings := []kindle.Clipping{
+		{Title: "Dune", Author: "Frank Herbert", Text: "a"},
+		{Title: "Emma", Author: "Jane Austen", Text: "b"},
+		{Title: "Dune", Author: "Frank Herbert", Text: "c"},
+		{Title: "Dune", Text: "d"},
+	}
+
+	books := groupClippings(clippings)
+	if len(books) != 3 {
+		t.Fatalf("groupClippings() returned %d books, want 3", len(books))
+	}
+	if books[0].title != "Dune" || len(books[0].clippings) != 2 || books[0].clippings[1].Text != "c" {
+		t.Errorf("first group = %+v, want both Dune clippings in file order", books[0])
+	}
+	if books[2].author != "" || len(books[2].clippings) != 1 {
+		t.Errorf("third group = %+v, want Dune without an author on its own", books[2])
+	}
+}
+
+func TestGroupClippingsTruncatesTitles(t *testing.T) {
+	long := strings.Repeat("a", 1<<20)
+	clippings := []kindle.Clipping{
+		{Title: long, Author: long, Text: "a"},
+		{Title: long + "b", Author: long, Text: "b"},
+	}
+
+	books := groupClippings(clippings)
+	if len(books) != 1 {
+		t.Fatalf("groupClippings() returned %d books, want titles equal up to the limit grouped together", len(books))
+	}
+	if len(books[0].title) != maxTitleLength || len(books[0].author) != maxAuthorLength {
+		t.Errorf("group title and author are %d and %d bytes, want %d and %d", len(books[0].title), len(books[0].author), maxTitleLength, maxAuthorLength)
+	}
+}
+
+func TestKindlePage(t *testing.T) {
+	epub, pdf := "epub", "pdf"
+	page := func(v int) *int { return &v }
+
+	tests := []struct {
+		name     string
+		book     repository.Book
+		clipping kindle.Clipping
+		want     *int32
+	}{
+		{name: "no page", book: repository.Book{Format: &pdf, TotalPages: 10}},
+		{name: "pdf page", book: repository.Book{Format: &pdf, TotalPages: 10}, clipping: kindle.Clipping{Page: page(3)}, want: optionalInt32(page(3))},
+		{name: "pdf page past the end", book: repository.Book{Format: &pdf, TotalPages: 10}, clipping: kindle.Clipping{Page: page(11)}},
+		{name: "epub page", book: repository.Book{Format: &epub, TotalPages: 10}, clipping: kindle.Clipping{Page: page(3)}},
+		{name: "book without a file", book: repository.Book{TotalPages: 0}, clipping: kindle.Clipping{Page: page(3)}, want: optionalInt32(page(3))},
+		{name: "page that would wrap", book: repository.Book{Format: &pdf, TotalPages: 10}, clipping: kindle.Clipping{Page: page(1<<32 + 3)}},
+		{name: "page past int32 without a file", clipping: kindle.Clipping{Page: page(math.MaxInt32 + 1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kindlePage(tt.book, tt.clipping); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kindlePage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionalInt32(t *testing.T) {
+	value := func(v int) *int { return &v }
+	n := int32(math.MaxInt32)
+
+	tests := []struct {
+		name string
+		v    *int
+		want *int32
+	}{
+		{name: "nil"},
+		{name: "in range", v: value(math.MaxInt32), want: &n},
+		{name: "too large", v: value(math.MaxInt32 + 1)},
+		{name: "too small", v: value(math.MinInt32 - 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := optionalInt32(tt.v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("optionalInt32() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	backgroundJobTimeout = 5 * time.Minute
+	// maxConcurrentBackgroundJobs bounds the jobs running at once. Processing
+	// an upload holds the whole file, up to maxBookDownloadBytes, in memory,
+	// so a burst of uploads waits its turn instead of exhausting it.
+	maxConcurrentBackgroundJobs = 4
+	// backgroundJobsShutdownTimeout is how long shutdown waits for jobs
+	// before cancelling them.
+	backgroundJobsShutdownTimeout = 30 * time.Second
+)
+
+var (
+	backgroundJobs     sync.WaitGroup
+	backgroundJobSlots = make(chan struct{}, maxConcurrentBackgroundJobs)
+
+	// backgroundJobsCtx is cancelled when shutdown gives up waiting, which
+	// stops running jobs and drops queued ones.
+	backgroundJobsCtx, stopBackgroundJobs = context.WithCancel(context.Background())
+)
+
+// runBackgroundJob runs fn detached from the request that scheduled it, once
+// one of the job slots is free. There is nobody left to report to, so errors
+// and panics are only logged.
+func runBackgroundJob(name string, fn func(ctx context.Context) error) {
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+
+		select {
+		case backgroundJobSlots <- struct{}{}:
+		case <-backgroundJobsCtx.Done():
+			log.Printf("background job %s dropped at shutdown", name)
+			return
+		}
+		defer func() { <-backgroundJobSlots }()
+
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("background job %s panicked: %v", name, r)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(backgroundJobsCtx, backgroundJobTimeout)
+		defer cancel()
+
+		if err := fn(ctx); err != nil {
+			log.Printf("background job %s failed: %s", name, err)
+		}
+	}()
+}
+
+// waitForBackgroundJobs waits up to timeout for background jobs to finish,
+// then cancels whatever is still running or queued. It reports whether all
+// of them finished in time.
+func waitForBackgroundJobs(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		backgroundJobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		stopBackgroundJobs()
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBackgroundJobBoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+
+	const jobs = 3 * maxConcurrentBackgroundJobs
+	for range jobs {
+		runBackgroundJob("test", func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for running.Load() < maxConcurrentBackgroundJobs && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := running.Load(); got != maxConcurrentBackgroundJobs {
+		t.Errorf("%d jobs running, want %d", got, maxConcurrentBackgroundJobs)
+	}
+
+	close(release)
+	if !waitForBackgroundJobs(5 * time.Second) {
+		t.Fatal("waitForBackgroundJobs() timed out")
+	}
+	if got := peak.Load(); got > maxConcurrentBackgroundJobs {
+		t.Errorf("peak of %d jobs running, want at most %d", got, maxConcurrentBackgroundJobs)
+	}
+}
@@ -0,0 +1,178 @@
+package kindle
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindHighlight Kind = "highlight"
+	KindNote      Kind = "note"
+	KindBookmark  Kind = "bookmark"
+)
+
+// Clipping is one entry of a Kindle "My Clippings.txt" file.
+type Clipping struct {
+	Title         string
+	Author        string
+	Kind          Kind
+	Page          *int
+	LocationStart *int
+	LocationEnd   *int
+	AddedAt       *time.Time
+	Text          string
+}
+
+// InvalidEntry is an entry that couldn't be read, with the title line if
+// there was one.
+type InvalidEntry struct {
+	Title  string
+	Reason string
+}
+
+const separator = "=========="
+
+var (
+	titleAuthor = regexp.MustCompile(`^(.*?)\s*\(([^()]*)\)\s*$`)
+	metaLine    = regexp.MustCompile(`(?i)^-\s*(?:your\s+)?(highlight|note|bookmark)\b(.*?)(?:\|\s*added on\s+(.*))?$`)
+	pageRef     = regexp.MustCompile(`(?i)\bpage\s+(\d+)`)
+	locationRef = regexp.MustCompile(`(?i)\b(?:location|loc\.)\s+(\d+)(?:-(\d+))?`)
+)
+
+// Kindles in different regions write the date in different orders.
+var dateLayouts = []string{
+	"Monday, January 2, 2006 3:04:05 PM",
+	"Monday, January 2, 2006, 3:04:05 PM",
+	"Monday, January 2, 2006 3:04 PM",
+	"Monday, January 2, 2006, 3:04 PM",
+	"Monday, 2 January 2006 15:04:05",
+	"Monday, 2 January 2006, 15:04:05",
+	"Monday, 2 January 2006 15:04",
+}
+
+// Parse reads a "My Clippings.txt" file. Entries in a language or layout it
+// doesn't recognize are returned as invalid rather than failing the file.
+func Parse(r io.Reader) ([]Clipping, []InvalidEntry, error) {
+	var clippings []Clipping
+	var invalid []InvalidEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		clipping, err := parseEntry(lines)
+		if err != nil {
+			invalid = append(invalid, *err)
+		} else {
+			clippings = append(clippings, clipping)
+		}
+		lines = nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.ReplaceAll(line, "\ufeff", "")
+		if strings.TrimSpace(line) == separator {
+			flush()
+			continue
+		}
+		if len(lines) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	flush()
+
+	return clippings, invalid, nil
+}
+
+func parseEntry(lines []string) (Clipping, *InvalidEntry) {
+	var clipping Clipping
+
+	clipping.Title = strings.TrimSpace(lines[0])
+	if m := titleAuthor.FindStringSubmatch(clipping.Title); m != nil && strings.TrimSpace(m[1]) != "" {
+		clipping.Title = strings.TrimSpace(m[1])
+		clipping.Author = normalizeAuthor(m[2])
+	}
+
+	if len(lines) < 2 {
+		return clipping, &InvalidEntry{Title: clipping.Title, Reason: "entry has no description line"}
+	}
+
+	m := metaLine.FindStringSubmatch(strings.TrimSpace(lines[1]))
+	if m == nil {
+		return clipping, &InvalidEntry{Title: clipping.Title, Reason: "unrecognized description: " + strings.TrimSpace(lines[1])}
+	}
+	clipping.Kind = Kind(strings.ToLower(m[1]))
+
+	if pm := pageRef.FindStringSubmatch(m[2]); pm != nil {
+		if page, err := strconv.Atoi(pm[1]); err == nil {
+			clipping.Page = &page
+		}
+	}
+	if lm := locationRef.FindStringSubmatch(m[2]); lm != nil {
+		start, _ := strconv.Atoi(lm[1])
+		end := start
+		if lm[2] != "" {
+			end = expandLocationEnd(lm[1], lm[2])
+		}
+		clipping.LocationStart, clipping.LocationEnd = &start, &end
+	}
+
+	if m[3] != "" {
+		raw := strings.Join(strings.Fields(m[3]), " ")
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				clipping.AddedAt = &t
+				break
+			}
+		}
+	}
+
+	clipping.Text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	if clipping.Kind != KindBookmark && clipping.Text == "" {
+		return clipping, &InvalidEntry{Title: clipping.Title, Reason: "empty " + string(clipping.Kind)}
+	}
+
+	return clipping, nil
+}
+
+// expandLocationEnd undoes the shorthand older Kindles use for ranges, where
+// "1234-38" means 1234 to 1238.
+func expandLocationEnd(start, end string) int {
+	if len(end) < len(start) {
+		end = start[:len(start)-len(end)] + end
+	}
+	n, _ := strconv.Atoi(end)
+	if s, _ := strconv.Atoi(start); n < s {
+		return s
+	}
+	return n
+}
+
+// normalizeAuthor turns "Last, First" into "First Last". Lists of several
+// authors are left as they are.
+func normalizeAuthor(author string) string {
+	author = strings.TrimSpace(author)
+	parts := strings.Split(author, ",")
+	if len(parts) == 2 && !strings.Contains(author, ";") && !strings.Contains(author, "&") {
+		last, first := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if first != "" && last != "" && !strings.Contains(last, " ") {
+			return first + " " + last
+		}
+	}
+	return author
+}
@@ -0,0 +1,171 @@
+package kindle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        []Clipping
+		wantInvalid []InvalidEntry
+	}{
+		{
+			name: "us highlight with page and location range",
+			input: "Dune (Herbert, Frank)\n" +
+				"- Your Highlight on page 12 | Location 170-72 | Added on Monday, March 4, 2024 9:05:07 PM\n" +
+				"\n" +
+				"I must not fear.\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title: "Dune", Author: "Frank Herbert", Kind: KindHighlight,
+				Page: intPtr(12), LocationStart: intPtr(170), LocationEnd: intPtr(172),
+				AddedAt: timePtr(time.Date(2024, 3, 4, 21, 5, 7, 0, time.UTC)),
+				Text:    "I must not fear.",
+			}},
+		},
+		{
+			name: "uk note with day first date",
+			input: "Dune (Frank Herbert)\n" +
+				"- Your Note at location 171 | Added on Monday, 4 March 2024 21:05:07\n" +
+				"\n" +
+				"Fear is the mind-killer.\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title: "Dune", Author: "Frank Herbert", Kind: KindNote,
+				LocationStart: intPtr(171), LocationEnd: intPtr(171),
+				AddedAt: timePtr(time.Date(2024, 3, 4, 21, 5, 7, 0, time.UTC)),
+				Text:    "Fear is the mind-killer.",
+			}},
+		},
+		{
+			name: "older kindle without your and with loc.",
+			input: "Emma (Austen, Jane)\n" +
+				"- Highlight Loc. 1234-38 | Added on Tuesday, January 2, 2018, 3:04 PM\n" +
+				"\n" +
+				"Handsome, clever, and rich.\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title: "Emma", Author: "Jane Austen", Kind: KindHighlight,
+				LocationStart: intPtr(1234), LocationEnd: intPtr(1238),
+				AddedAt: timePtr(time.Date(2018, 1, 2, 15, 4, 0, 0, time.UTC)),
+				Text:    "Handsome, clever, and rich.",
+			}},
+		},
+		{
+			name: "byte order mark and crlf line endings",
+			input: "\ufeffDune (Frank Herbert)\r\n" +
+				"- Your Highlight on Location 170 | Added on Monday, March 4, 2024 9:05:07 PM\r\n" +
+				"\r\n" +
+				"First line\r\n" +
+				"second line\r\n" +
+				"==========\r\n" +
+				"\ufeffDune (Frank Herbert)\r\n" +
+				"- Your Bookmark on Location 200 | Added on Monday, March 4, 2024 9:06:00 PM\r\n" +
+				"\r\n" +
+				"\r\n" +
+				"==========\r\n",
+			want: []Clipping{
+				{
+					Title: "Dune", Author: "Frank Herbert", Kind: KindHighlight,
+					LocationStart: intPtr(170), LocationEnd: intPtr(170),
+					AddedAt: timePtr(time.Date(2024, 3, 4, 21, 5, 7, 0, time.UTC)),
+					Text:    "First line\nsecond line",
+				},
+				{
+					Title: "Dune", Author: "Frank Herbert", Kind: KindBookmark,
+					LocationStart: intPtr(200), LocationEnd: intPtr(200),
+					AddedAt: timePtr(time.Date(2024, 3, 4, 21, 6, 0, 0, time.UTC)),
+				},
+			},
+		},
+		{
+			name: "pdf highlight without location or date",
+			input: "report.pdf\n" +
+				"- Your Highlight on page 3\n" +
+				"\n" +
+				"Some text\n" +
+				"==========\n",
+			want: []Clipping{{Title: "report.pdf", Kind: KindHighlight, Page: intPtr(3), Text: "Some text"}},
+		},
+		{
+			name: "title with parentheses and several authors",
+			input: "Good Omens (The Nice and Accurate Prophecies) (Gaiman, Neil; Pratchett, Terry)\n" +
+				"- Your Highlight on Location 5\n" +
+				"\n" +
+				"Text\n" +
+				"==========\n",
+			want: []Clipping{{
+				Title: "Good Omens (The Nice and Accurate Prophecies)", Author: "Gaiman, Neil; Pratchett, Terry",
+				Kind: KindHighlight, LocationStart: intPtr(5), LocationEnd: intPtr(5), Text: "Text",
+			}},
+		},
+		{
+			name: "unrecognized locale and broken entries are invalid",
+			input: "Der Process (Kafka, Franz)\n" +
+				"- Ihre Markierung bei Position 10-12 | Hinzugefügt am Montag, 4. März 2024 21:05:07\n" +
+				"\n" +
+				"Jemand mußte Josef K. verleumdet haben\n" +
+				"==========\n" +
+				"Lonely title\n" +
+				"==========\n" +
+				"Dune (Frank Herbert)\n" +
+				"- Your Highlight on Location 1\n" +
+				"\n" +
+				"\n" +
+				"==========\n",
+			wantInvalid: []InvalidEntry{
+				{Title: "Der Process", Reason: "unrecognized description: - Ihre Markierung bei Position 10-12 | Hinzugefügt am Montag, 4. März 2024 21:05:07"},
+				{Title: "Lonely title", Reason: "entry has no description line"},
+				{Title: "Dune", Reason: "empty highlight"},
+			},
+		},
+		{
+			name: "unknown date layout keeps the clipping",
+			input: "Dune (Frank Herbert)\n" +
+				"- Your Highlight on Location 1 | Added on 2024-03-04\n" +
+				"\n" +
+				"Text\n",
+			want: []Clipping{{Title: "Dune", Author: "Frank Herbert", Kind: KindHighlight, LocationStart: intPtr(1), LocationEnd: intPtr(1), Text: "Text"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, invalid, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() clippings = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(invalid, tt.wantInvalid) {
+				t.Errorf("Parse() invalid = %+v, want %+v", invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+func TestNormalizeAuthor(t *testing.T) {
+	tests := map[string]string{
+		"Herbert, Frank":                  "Frank Herbert",
+		"Frank Herbert":                   "Frank Herbert",
+		"Le Guin, Ursula K.":              "Le Guin, Ursula K.",
+		"Gaiman, Neil; Pratchett, Terry":  "Gaiman, Neil; Pratchett, Terry",
+		"  Austen,   Jane ":               "Jane Austen",
+		"Strunk & White, The Elements of": "Strunk & White, The Elements of",
+	}
+	for in, want := range tests {
+		if got := normalizeAuthor(in); got != want {
+			t.Errorf("normalizeAuthor(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
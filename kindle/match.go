@@ -0,0 +1,161 @@
+package kindle
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	titleMatchThreshold = 0.85
+	// Main titles are short and shared by every volume of a series, so they
+	// have to match almost exactly, and only one candidate may have them.
+	mainTitleMatchThreshold = 0.95
+	authorMatchThreshold    = 0.5
+)
+
+// Candidate is a book a clipping may belong to.
+type Candidate struct {
+	Title  string
+	Author string
+}
+
+// Match returns the index of the candidate that best fits the title and
+// author, or -1. Full titles are compared first. Only when none is close
+// enough are titles compared without their subtitle, since Kindle store
+// titles often carry one the uploaded file doesn't; that fallback needs a
+// near-exact main title and gives up when several candidates share it.
+// Titles with different volume numbers never match. Authors only have to
+// share a name when both sides have one.
+func Match(title, author string, candidates []Candidate) int {
+	best, bestScore := -1, 0.0
+	var fallback []int
+	for i, candidate := range candidates {
+		if !sameVolume(title, candidate.Title) {
+			continue
+		}
+		if author != "" && candidate.Author != "" && authorOverlap(author, candidate.Author) < authorMatchThreshold {
+			continue
+		}
+
+		if score := similarity(normalizeTitle(title), normalizeTitle(candidate.Title)); score >= titleMatchThreshold && score > bestScore {
+			best, bestScore = i, score
+		}
+		if mainTitleSimilarity(title, candidate.Title) >= mainTitleMatchThreshold {
+			fallback = append(fallback, i)
+		}
+	}
+
+	if best >= 0 {
+		return best
+	}
+	if len(fallback) == 1 {
+		return fallback[0]
+	}
+	return -1
+}
+
+func mainTitleSimilarity(a, b string) float64 {
+	mainA, mainB := normalizeTitle(mainTitle(a)), normalizeTitle(mainTitle(b))
+	if mainA == "" || mainB == "" {
+		return 0
+	}
+	return similarity(mainA, mainB)
+}
+
+// mainTitle drops a subtitle or series note.
+func mainTitle(title string) string {
+	if i := strings.IndexAny(title, ":(["); i > 0 {
+		title = title[:i]
+	}
+	if i := strings.Index(title, " - "); i > 0 {
+		title = title[:i]
+	}
+	return title
+}
+
+// sameVolume tells "Book 1" and "Book 2" of a series apart: titles that both
+// carry numbers must carry the same ones.
+func sameVolume(a, b string) bool {
+	numbersA, numbersB := numbers(a), numbers(b)
+	if len(numbersA) == 0 || len(numbersB) == 0 {
+		return true
+	}
+	return slices.Equal(numbersA, numbersB)
+}
+
+func numbers(title string) []string {
+	var nums []string
+	for _, word := range tokens(title) {
+		if strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			nums = append(nums, strings.TrimLeft(word, "0"))
+		}
+	}
+	return nums
+}
+
+func normalizeTitle(title string) string {
+	words := tokens(title)
+	if len(words) > 1 {
+		switch words[0] {
+		case "the", "a", "an":
+			words = words[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func tokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// authorOverlap is the share of the shorter name's words found in the other.
+func authorOverlap(a, b string) float64 {
+	wordsA, wordsB := tokens(a), tokens(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, w := range wordsA {
+		set[w] = true
+	}
+	shared := 0
+	for _, w := range wordsB {
+		if set[w] {
+			shared++
+			delete(set, w)
+		}
+	}
+	return float64(shared) / float64(min(len(wordsA), len(wordsB)))
+}
+
+// similarity is one minus the edit distance relative to the longer string.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(max(len(ra), len(rb)))
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
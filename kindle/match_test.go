@@ -0,0 +1,112 @@
+package kindle
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		title      string
+		author     string
+		candidates []Candidate
+		want       int
+	}{
+		{
+			name:       "exact title",
+			title:      "Dune",
+			candidates: []Candidate{{Title: "Emma"}, {Title: "Dune"}},
+			want:       1,
+		},
+		{
+			name:       "leading article and punctuation",
+			title:      "The Left Hand of Darkness",
+			candidates: []Candidate{{Title: "left hand of darkness!"}},
+			want:       0,
+		},
+		{
+			name:       "small typo is above the threshold",
+			title:      "The Brothers Karamazov",
+			candidates: []Candidate{{Title: "The Brothers Karamazof"}},
+			want:       0,
+		},
+		{
+			name:       "different title is below the threshold",
+			title:      "Dune Messiah",
+			candidates: []Candidate{{Title: "Dune"}},
+			want:       -1,
+		},
+		{
+			name:       "store subtitle falls back to the main title",
+			title:      "Dune (Dune Chronicles Book 1)",
+			candidates: []Candidate{{Title: "Dune"}, {Title: "Dune Messiah"}},
+			want:       0,
+		},
+		{
+			name:       "full title beats a shared main title",
+			title:      "Foundation: The Psychohistorians",
+			candidates: []Candidate{{Title: "Foundation: A Novel"}, {Title: "Foundation: The Psychohistorians"}},
+			want:       1,
+		},
+		{
+			name:       "ambiguous main title is not matched",
+			title:      "Foundation: The Psychohistorians",
+			candidates: []Candidate{{Title: "Foundation: A Novel"}, {Title: "Foundation - Special Edition"}},
+			want:       -1,
+		},
+		{
+			name:       "series volumes do not match each other",
+			title:      "The Expanse: Book 1",
+			candidates: []Candidate{{Title: "The Expanse: Book 2"}},
+			want:       -1,
+		},
+		{
+			name:       "same volume of a series matches",
+			title:      "The Expanse: Book 2",
+			candidates: []Candidate{{Title: "The Expanse: Book 1"}, {Title: "The Expanse: Book 02"}},
+			want:       1,
+		},
+		{
+			name:       "author must share a name",
+			title:      "Emma",
+			author:     "Jane Austen",
+			candidates: []Candidate{{Title: "Emma", Author: "Emma Donoghue"}, {Title: "Emma", Author: "Austen"}},
+			want:       1,
+		},
+		{
+			name:       "missing author on either side is ignored",
+			title:      "Emma",
+			candidates: []Candidate{{Title: "Emma", Author: "Jane Austen"}},
+			want:       0,
+		},
+		{
+			name:  "no candidates",
+			title: "Dune",
+			want:  -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.title, tt.author, tt.candidates); got != tt.want {
+				t.Errorf("Match(%q, %q) = %d, want %d", tt.title, tt.author, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"dune", "dune", 1},
+		{"dune", "", 0},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"ärger", "arger", 0.8},
+	}
+	for _, tt := range tests {
+		if got := similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
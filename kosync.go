@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/bookmeta"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/Hodik/noteshelf-be.git/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Error codes of the kosync protocol.
+const (
+	kosyncUnknownError     = 2000
+	kosyncUnauthorized     = 2001
+	kosyncUserExists       = 2002
+	kosyncInvalidRequest   = 2003
+	kosyncDocumentRequired = 2004
+)
+
+const kosyncDevicePrefix = "koreader:"
+
+func kosyncError(c *gin.Context, status, code int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"code": code, "message": message})
+}
+
+// kosyncKey is what KOReader sends in place of the password: its MD5 in hex.
+func kosyncKey(password string) string {
+	sum := md5.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	// kosyncVerifiedTTL is how long a checked key skips bcrypt. KOReader
+	// authenticates every request, often several a minute.
+	kosyncVerifiedTTL     = 10 * time.Minute
+	maxKosyncVerifiedKeys = 10_000
+	// maxKosyncKeyChecks bounds the bcrypt comparisons running at once, so a
+	// flood of wrong keys can't take every CPU.
+	maxKosyncKeyChecks = 4
+
+	// kosyncDummyHash is compared against for unknown usernames so they take
+	// as long to reject as wrong keys.
+	kosyncDummyHash = "$2a$10$AQUE3McozF9CYuFMeSkG/OkRSg5imNcC7E2SGfH7fiIixaKw4LzUa"
+)
+
+var (
+	errKosyncUnauthorized = errors.New("unauthorized")
+
+	kosyncKeyChecks = make(chan struct{}, maxKosyncKeyChecks)
+
+	kosyncVerifiedMu sync.Mutex
+	// kosyncVerified holds when each verified key stops being trusted, keyed
+	// by the stored hash and the key together so changing the password
+	// drops it.
+	kosyncVerified = map[[sha256.Size]byte]time.Time{}
+)
+
+func kosyncVerifiedKey(keyHash, key string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(keyHash + "\x00" + key))
+}
+
+// compareKosyncKey checks key against keyHash, skipping bcrypt for keys it
+// verified recently.
+func compareKosyncKey(ctx context.Context, keyHash, key string) error {
+	cacheKey := kosyncVerifiedKey(keyHash, key)
+	now := time.Now()
+
+	kosyncVerifiedMu.Lock()
+	expires, ok := kosyncVerified[cacheKey]
+	kosyncVerifiedMu.Unlock()
+	if ok && now.Before(expires) {
+		return nil
+	}
+
+	select {
+	case kosyncKeyChecks <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(keyHash), []byte(key))
+	<-kosyncKeyChecks
+	if err != nil {
+		return errKosyncUnauthorized
+	}
+
+	kosyncVerifiedMu.Lock()
+	defer kosyncVerifiedMu.Unlock()
+	if len(kosyncVerified) >= maxKosyncVerifiedKeys {
+		for k, expires := range kosyncVerified {
+			if !now.Before(expires) {
+				delete(kosyncVerified, k)
+			}
+		}
+		if len(kosyncVerified) >= maxKosyncVerifiedKeys {
+			clear(kosyncVerified)
+		}
+	}
+	kosyncVerified[cacheKey] = now.Add(kosyncVerifiedTTL)
+	return nil
+}
+
+// verifyKosyncKey returns the account of username if key is its key.
+// Unknown usernames and wrong keys both fail with errKosyncUnauthorized.
+func verifyKosyncKey(ctx context.Context, username, key string) (repository.KosyncAccount, error) {
+	account, err := cfg.Queries.GetKosyncAccountByUsername(ctx, username)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			_ = compareKosyncKey(ctx, kosyncDummyHash, key)
+			return account, errKosyncUnauthorized
+		}
+		return account, err
+	}
+
+	if err := compareKosyncKey(ctx, account.KeyHash, key); err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+type KosyncAccountRequest struct {
+	Username string `json:"username" binding:"required,max=100"`
+	Password string `json:"password" binding:"required,min=8,max=200"`
+}
+
+type KosyncAccountResponse struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func getKosyncAccountHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	account, err := cfg.Queries.GetKosyncAccountByUserID(c, dbUser.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no KOReader account"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, KosyncAccountResponse{Username: account.Username, CreatedAt: account.CreatedAt, UpdatedAt: account.UpdatedAt})
+}
+
+// setKosyncAccountHandler sets the username and password the user enters in
+// KOReader's progress sync settings. KOReader can't sign in through Clerk, so
+// this is the only way its requests are tied to a user.
+func setKosyncAccountHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !requireSession(c) {
+		return
+	}
+
+	var req KosyncAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	keyHash, err := bcrypt.GenerateFromPassword([]byte(kosyncKey(req.Password)), bcrypt.DefaultCost)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := cfg.Queries.UpsertKosyncAccount(c, repository.UpsertKosyncAccountParams{UserID: dbUser.ID, Username: strings.TrimSpace(req.Username), KeyHash: string(keyHash)})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "username is taken"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := dbUser.ID
+	runBackgroundJob("koreader hashes "+userID, func(ctx context.Context) error {
+		return hashReadableBooksForKOReader(ctx, userID)
+	})
+
+	c.JSON(http.StatusOK, KosyncAccountResponse{Username: account.Username, CreatedAt: account.CreatedAt, UpdatedAt: account.UpdatedAt})
+}
+
+func deleteKosyncAccountHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !requireSession(c) {
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteKosyncAccount(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no KOReader account"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// hashBookForKOReader stores the hash KOReader will know the book's file by.
+// Only the sampled ranges are read from storage.
+func hashBookForKOReader(ctx context.Context, book repository.Book) error {
+	if book.S3Key == nil {
+		return nil
+	}
+
+	reader, err := utils.NewObjectReader(ctx, cfg.S3Client, cfg.BucketName, *book.S3Key)
+	if err != nil {
+		return err
+	}
+
+	return storeKOReaderHash(ctx, book, reader, reader.Size)
+}
+
+// storeKOReaderHash hashes a book's file read through r.
+func storeKOReaderHash(ctx context.Context, book repository.Book, r io.ReaderAt, size int64) error {
+	hash, err := bookmeta.KOReaderHash(r, size)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Queries.SetBookKOReaderHash(ctx, repository.SetBookKOReaderHashParams{KoreaderHash: &hash, ID: book.ID})
+}
+
+// hashReadableBooksForKOReader fills in the hashes of books uploaded before
+// KOReader sync existed.
+func hashReadableBooksForKOReader(ctx context.Context, userID string) error {
+	books, err := cfg.Queries.GetReadableBooksWithoutKOReaderHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if err := hashBookForKOReader(ctx, book); err != nil {
+			return fmt.Errorf("book %s: %w", book.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// kosyncAuthMiddleware authenticates KOReader by the x-auth-user and
+// x-auth-key headers and puts the matching user where the Clerk middleware
+// would.
+func kosyncAuthMiddleware(c *gin.Context) {
+	username, key := c.GetHeader("x-auth-user"), c.GetHeader("x-auth-key")
+	if username == "" || key == "" {
+		kosyncError(c, http.StatusUnauthorized, kosyncUnauthorized, "Unauthorized")
+		return
+	}
+
+	account, err := verifyKosyncKey(c, username, key)
+	if err != nil {
+		if errors.Is(err, errKosyncUnauthorized) {
+			kosyncError(c, http.StatusUnauthorized, kosyncUnauthorized, "Unauthorized")
+			return
+		}
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	user, err := cfg.Queries.GetUserById(c, account.UserID)
+	if err != nil {
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	c.Set("dbUser", &user)
+	c.Next()
+}
+
+type KosyncCreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// kosyncCreateUserHandler answers KOReader's register button. Accounts are
+// set up in the app, so registering only succeeds for an existing account
+// with the same password, which lets KOReader carry on as if it had created
+// it. Unknown usernames and wrong passwords get the same answer so the
+// endpoint can't be used to find accounts.
+func kosyncCreateUserHandler(c *gin.Context) {
+	var req KosyncCreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" || req.Password == "" {
+		kosyncError(c, http.StatusBadRequest, kosyncInvalidRequest, "Invalid request")
+		return
+	}
+
+	account, err := verifyKosyncKey(c, req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, errKosyncUnauthorized) {
+			kosyncError(c, http.StatusForbidden, kosyncUnauthorized, "Set up KOReader sync in the Noteshelf app first, with the same username and password")
+			return
+		}
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"username": account.Username})
+}
+
+func kosyncAuthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"authorized": "OK"})
+}
+
+type KosyncProgressRequest struct {
+	Document   string  `json:"document"`
+	Progress   string  `json:"progress"`
+	Percentage float64 `json:"percentage"`
+	Device     string  `json:"device"`
+	DeviceID   string  `json:"device_id"`
+}
+
+type KosyncProgressResponse struct {
+	Document   string  `json:"document"`
+	Progress   string  `json:"progress"`
+	Percentage float64 `json:"percentage"`
+	Device     string  `json:"device"`
+	DeviceID   string  `json:"device_id"`
+	Timestamp  int64   `json:"timestamp"`
+}
+
+// docFragment matches the spine item of a crengine xpointer such as
+// "/body/DocFragment[12]/body/p[3]/text().0". Fragments count from 1, like
+// our EPUB pages.
+var docFragment = regexp.MustCompile(`^/body/DocFragment\[(\d+)\]`)
+
+// kosyncPage translates a KOReader position to a page. Reflowable documents
+// report an xpointer and paged ones a page number; anything else falls back
+// to the percentage.
+func kosyncPage(book repository.Book, progress string, percentage float64) int32 {
+	var page int
+	if m := docFragment.FindStringSubmatch(progress); m != nil {
+		page, _ = strconv.Atoi(m[1])
+	} else if n, err := strconv.Atoi(strings.TrimSpace(progress)); err == nil {
+		page = n
+	}
+	if page < 1 {
+		page = int(math.Ceil(percentage * float64(book.TotalPages)))
+	}
+	if book.TotalPages > 0 {
+		page = min(page, int(book.TotalPages))
+	}
+	return int32(max(page, 1))
+}
+
+// kosyncProgress translates our progress into what KOReader expects. EPUB
+// pages are spine items, which crengine can jump to by fragment.
+func kosyncProgress(book repository.Book, progress repository.ReadingProgress) string {
+	if book.Format != nil && *book.Format == "epub" {
+		return fmt.Sprintf("/body/DocFragment[%d]/body", max(progress.CurrentPage, 1))
+	}
+	return strconv.Itoa(int(max(progress.CurrentPage, 1)))
+}
+
+// kosyncUpdateProgressHandler stores a KOReader position. For documents that
+// match a book it also moves the book's reading progress, as any other device
+// would.
+func kosyncUpdateProgressHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req KosyncProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		kosyncError(c, http.StatusBadRequest, kosyncInvalidRequest, "Invalid request")
+		return
+	}
+	if req.Document == "" || len(req.Document) > 32 {
+		kosyncError(c, http.StatusBadRequest, kosyncDocumentRequired, "Field 'document' not provided.")
+		return
+	}
+	if req.Percentage < 0 || req.Percentage > 1 || len(req.Device) > 100 || len(req.DeviceID) > 100-len(kosyncDevicePrefix) {
+		kosyncError(c, http.StatusBadRequest, kosyncInvalidRequest, "Invalid request")
+		return
+	}
+
+	now := time.Now().UTC()
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	if _, err := localQueries.UpsertKosyncProgress(c, repository.UpsertKosyncProgressParams{
+		UserID:     dbUser.ID,
+		Document:   req.Document,
+		Progress:   req.Progress,
+		Percentage: req.Percentage,
+		Device:     req.Device,
+		DeviceID:   req.DeviceID,
+		UpdatedAt:  now,
+	}); err != nil {
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	book, err := localQueries.GetReadableBookByKOReaderHash(c, repository.GetReadableBookByKOReaderHashParams{KoreaderHash: &req.Document, UserID: dbUser.ID})
+	switch {
+	case err == nil:
+		device := req.Device
+		update := progressUpdate{
+			DeviceID:    kosyncDevicePrefix + req.DeviceID,
+			DeviceName:  &device,
+			CurrentPage: kosyncPage(book, req.Progress, req.Percentage),
+			ReadAt:      now,
+		}
+		if _, err := saveReadingProgress(c, localQueries, book, dbUser.ID, update); err != nil {
+			kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+			return
+		}
+	case !strings.Contains(err.Error(), "no rows"):
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"document": req.Document, "timestamp": now.Unix()})
+}
+
+// kosyncGetProgressHandler returns the latest position of a document. When
+// the book was read more recently on another device that position is
+// translated; otherwise KOReader gets back exactly what it last sent.
+func kosyncGetProgressHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	document := c.Param("document")
+
+	var response *KosyncProgressResponse
+
+	stored, err := cfg.Queries.GetKosyncProgress(c, repository.GetKosyncProgressParams{UserID: dbUser.ID, Document: document})
+	switch {
+	case err == nil:
+		response = &KosyncProgressResponse{
+			Document:   stored.Document,
+			Progress:   stored.Progress,
+			Percentage: stored.Percentage,
+			Device:     stored.Device,
+			DeviceID:   stored.DeviceID,
+			Timestamp:  stored.UpdatedAt.Unix(),
+		}
+	case !strings.Contains(err.Error(), "no rows"):
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	book, err := cfg.Queries.GetReadableBookByKOReaderHash(c, repository.GetReadableBookByKOReaderHashParams{KoreaderHash: &document, UserID: dbUser.ID})
+	if err == nil {
+		progress, err := cfg.Queries.GetReadingProgress(c, repository.GetReadingProgressParams{BookID: book.ID, UserID: dbUser.ID})
+		if err != nil && !strings.Contains(err.Error(), "no rows") {
+			kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+			return
+		}
+		if err == nil && progress.LastReadAt.Valid && progress.CurrentPage > 0 && (response == nil || progress.LastReadAt.Time.After(stored.UpdatedAt)) {
+			response = &KosyncProgressResponse{
+				Document:   document,
+				Progress:   kosyncProgress(book, progress),
+				Percentage: progress.PercentageComplete / 100,
+				Device:     "Noteshelf",
+				DeviceID:   "noteshelf",
+				Timestamp:  progress.LastReadAt.Time.Unix(),
+			}
+		}
+	} else if !strings.Contains(err.Error(), "no rows") {
+		kosyncError(c, http.StatusInternalServerError, kosyncUnknownError, "Unknown server error")
+		return
+	}
+
+	if response == nil {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
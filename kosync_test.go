@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCompareKosyncKey(t *testing.T) {
+	key := kosyncKey("correct horse")
+	hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := compareKosyncKey(ctx, string(hash), kosyncKey("wrong")); !errors.Is(err, errKosyncUnauthorized) {
+		t.Fatalf("compareKosyncKey() with a wrong key error = %v, want errKosyncUnauthorized", err)
+	}
+	if err := compareKosyncKey(ctx, string(hash), key); err != nil {
+		t.Fatalf("compareKosyncKey() error = %v", err)
+	}
+
+	// With every check slot taken only cached keys can get through.
+	for range maxKosyncKeyChecks {
+		kosyncKeyChecks <- struct{}{}
+	}
+	defer func() {
+		for range maxKosyncKeyChecks {
+			<-kosyncKeyChecks
+		}
+	}()
+	busy, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	if err := compareKosyncKey(busy, string(hash), key); err != nil {
+		t.Errorf("compareKosyncKey() of a verified key error = %v, want it cached", err)
+	}
+	if err := compareKosyncKey(busy, string(hash), kosyncKey("wrong")); err == nil {
+		t.Error("compareKosyncKey() cached a wrong key")
+	}
+
+	rotated, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compareKosyncKey(busy, string(rotated), key); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("compareKosyncKey() after the hash changed error = %v, want a fresh bcrypt check", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"log"
 
@@ -16,6 +17,7 @@ import (
 )
 
 var cfg setup.Config
+var eventHub *EventHub
 func main() {
   cfg = setup.Setup(30)
 	defer func() {
@@ -25,15 +27,85 @@ func main() {
 		}
 	}()
 
+	eventHub = NewEventHub(os.Getenv("POSTGRESQL_URL"))
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	defer stopHub()
+	go eventHub.Run(hubCtx)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		runS3DeletionWorker(workerCtx)
+	}()
+
 	router := gin.Default()
 
-	router.Use(auth.AuthMiddleware(cfg.Queries))
+	// KOReader can't sign in through Clerk, so the kosync routes authenticate
+	// on their own and are registered before the Clerk middleware.
+	kosync := router.Group("/kosync")
+	kosync.POST("/users/create", kosyncCreateUserHandler)
+	kosync.GET("/users/auth", kosyncAuthMiddleware, kosyncAuthHandler)
+	kosync.PUT("/syncs/progress", kosyncAuthMiddleware, kosyncUpdateProgressHandler)
+	kosync.GET("/syncs/progress/:document", kosyncAuthMiddleware, kosyncGetProgressHandler)
+
+	// E-reader catalog apps can't send Clerk tokens either. The catalog takes
+	// the user's feed token in the path or as the HTTP Basic password.
+	registerOPDSRoutes(router.Group("/opds", opdsAuthMiddleware))
+	registerOPDSRoutes(router.Group("/opds/t/:feed_token", opdsAuthMiddleware))
+
+	router.Use(auth.AuthMiddleware(cfg.Authenticator, cfg.Queries))
 	router.GET("/me", meHandler)
+	router.PATCH("/me", updateMeHandler)
+	router.GET("/me/kosync", getKosyncAccountHandler)
+	router.PUT("/me/kosync", setKosyncAccountHandler)
+	router.DELETE("/me/kosync", deleteKosyncAccountHandler)
+	router.GET("/me/opds-token", getOPDSFeedTokenHandler)
+	router.POST("/me/opds-token", createOPDSFeedTokenHandler)
+	router.DELETE("/me/opds-token", deleteOPDSFeedTokenHandler)
+	router.GET("/me/api-tokens", listAPITokensHandler)
+	router.POST("/me/api-tokens", createAPITokenHandler)
+	router.DELETE("/me/api-tokens/:token_id", deleteAPITokenHandler)
 	router.POST("/upload-book", generateUploadUrlHandler)
 	router.POST("/books", confirmBookUploadHandler)
 	router.GET("/books", getLibraryHandler)
 	router.GET("/books/:book_id", getBookHandler)
+	router.PATCH("/books/:book_id", updateBookHandler)
+	router.DELETE("/books/:book_id", deleteBookHandler)
 	router.PATCH("/books/:book_id/reading-progress", updateReadingProgressHandler)
+	router.GET("/books/:book_id/reading-positions", listReadingPositionsHandler)
+	router.POST("/sync/progress", syncProgressHandler)
+	router.PUT("/books/:book_id/reading-status", updateReadingStatusHandler)
+	router.GET("/books/:book_id/completed-reads", listCompletedReadsHandler)
+	router.GET("/books/:book_id/annotations", listAnnotationsHandler)
+	router.POST("/books/:book_id/annotations", createAnnotationHandler)
+	router.GET("/books/:book_id/annotations/export", exportAnnotationsHandler)
+	router.GET("/books/:book_id/annotations/:annotation_id", getAnnotationHandler)
+	router.PATCH("/books/:book_id/annotations/:annotation_id", updateAnnotationHandler)
+	router.DELETE("/books/:book_id/annotations/:annotation_id", deleteAnnotationHandler)
+	router.GET("/annotations/export", exportLibraryAnnotationsHandler)
+	router.POST("/import/kindle", importKindleHandler)
+	router.GET("/books/:book_id/bookmarks", listBookmarksHandler)
+	router.POST("/books/:book_id/bookmarks", createBookmarkHandler)
+	router.DELETE("/books/:book_id/bookmarks/:bookmark_id", deleteBookmarkHandler)
+	router.GET("/books/:book_id/shares", listBookSharesHandler)
+	router.POST("/books/:book_id/shares", shareBookHandler)
+	router.DELETE("/books/:book_id/shares/:user_id", revokeBookShareHandler)
+	router.GET("/search", searchHandler)
+	router.GET("/changes", getChangesHandler)
+	router.GET("/events", eventsHandler)
+	router.GET("/stats", getStatsHandler)
+	router.GET("/goals", getGoalsHandler)
+	router.PUT("/goals", setGoalHandler)
+	router.DELETE("/goals/:goal_id", deleteGoalHandler)
+	router.GET("/shelves", listShelvesHandler)
+	router.POST("/shelves", createShelfHandler)
+	router.PUT("/shelves/order", reorderShelvesHandler)
+	router.PATCH("/shelves/:shelf_id", renameShelfHandler)
+	router.DELETE("/shelves/:shelf_id", deleteShelfHandler)
+	router.PUT("/shelves/:shelf_id/books/:book_id", addBookToShelfHandler)
+	router.DELETE("/shelves/:shelf_id/books/:book_id", removeBookFromShelfHandler)
 
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -42,6 +114,7 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	srv.RegisterOnShutdown(eventHub.Close)
 
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -61,5 +134,11 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	log.Println("Waiting for background jobs...")
+	stopWorkers()
+	if !waitForBackgroundJobs(backgroundJobsShutdownTimeout) {
+		log.Println("Background jobs didn't finish in time and were cancelled")
+	}
+
 	log.Println("Server exited gracefully")
 }
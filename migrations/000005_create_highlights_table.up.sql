@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS highlights(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  book_id UUID NOT NULL,
+  user_id VARCHAR(50) NOT NULL,
+  page_number INTEGER,
+  cfi_range TEXT,
+  selected_text TEXT NOT NULL,
+  color VARCHAR(20) NOT NULL DEFAULT 'yellow',
+  note TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS highlights_book_id_user_id_idx ON highlights(book_id, user_id);
@@ -0,0 +1,4 @@
+ALTER TABLE books
+DROP COLUMN description,
+DROP COLUMN language,
+DROP COLUMN isbn;
@@ -0,0 +1,4 @@
+ALTER TABLE books
+ADD description TEXT,
+ADD language VARCHAR(35),
+ADD isbn VARCHAR(17);
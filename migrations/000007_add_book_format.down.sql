@@ -0,0 +1,2 @@
+ALTER TABLE books
+DROP COLUMN format;
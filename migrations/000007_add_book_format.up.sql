@@ -0,0 +1,2 @@
+ALTER TABLE books
+ADD format VARCHAR(10);
@@ -0,0 +1,4 @@
+ALTER TABLE books
+DROP COLUMN cover_small_key,
+DROP COLUMN cover_medium_key,
+DROP COLUMN cover_large_key;
@@ -0,0 +1,4 @@
+ALTER TABLE books
+ADD cover_small_key VARCHAR(255),
+ADD cover_medium_key VARCHAR(255),
+ADD cover_large_key VARCHAR(255);
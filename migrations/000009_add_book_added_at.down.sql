@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS books_owner_id_idx;
+
+ALTER TABLE books
+DROP COLUMN added_at;
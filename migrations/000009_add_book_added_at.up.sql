@@ -0,0 +1,4 @@
+ALTER TABLE books
+ADD added_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS books_owner_id_idx ON books(owner_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS book_pages;
+
+ALTER TABLE books
+DROP COLUMN IF EXISTS search_vector;
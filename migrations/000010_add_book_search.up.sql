@@ -0,0 +1,17 @@
+ALTER TABLE books
+ADD search_vector TSVECTOR NOT NULL GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', COALESCE(author, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS books_search_vector_idx ON books USING GIN (search_vector);
+
+CREATE TABLE IF NOT EXISTS book_pages(
+  book_id UUID NOT NULL,
+  page_number INTEGER NOT NULL,
+  content TEXT NOT NULL,
+  search_vector TSVECTOR NOT NULL GENERATED ALWAYS AS (to_tsvector('english', content)) STORED,
+  PRIMARY KEY (book_id, page_number),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS book_pages_search_vector_idx ON book_pages USING GIN (search_vector);
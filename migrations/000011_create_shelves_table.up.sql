@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS shelves(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id VARCHAR(50) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  position INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, name),
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS shelf_books(
+  shelf_id UUID NOT NULL,
+  book_id UUID NOT NULL,
+  added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (shelf_id, book_id),
+  FOREIGN KEY (shelf_id) REFERENCES shelves(id) ON DELETE CASCADE,
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS shelf_books_book_id_idx ON shelf_books(book_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS book_shares(
+  book_id UUID NOT NULL,
+  user_id VARCHAR(50) NOT NULL,
+  shared_by VARCHAR(50) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (book_id, user_id),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (shared_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS book_shares_user_id_idx ON book_shares(user_id);
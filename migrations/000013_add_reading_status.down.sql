@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS completed_reads;
+
+ALTER TABLE reading_progress
+DROP COLUMN IF EXISTS finished_at,
+DROP COLUMN IF EXISTS started_at,
+DROP COLUMN IF EXISTS status;
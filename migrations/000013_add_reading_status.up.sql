@@ -0,0 +1,24 @@
+ALTER TABLE reading_progress
+ADD status VARCHAR(20) NOT NULL DEFAULT 'want_to_read' CHECK (status IN ('want_to_read', 'reading', 'finished', 'abandoned')),
+ADD started_at TIMESTAMP,
+ADD finished_at TIMESTAMP;
+
+UPDATE reading_progress
+SET status = CASE WHEN percentage_complete >= 100 THEN 'finished' WHEN percentage_complete > 0 THEN 'reading' ELSE 'want_to_read' END,
+    started_at = CASE WHEN percentage_complete > 0 THEN last_read_at END,
+    finished_at = CASE WHEN percentage_complete >= 100 THEN last_read_at END;
+
+CREATE TABLE IF NOT EXISTS completed_reads(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id VARCHAR(50) NOT NULL,
+  book_id UUID NOT NULL,
+  started_at TIMESTAMP,
+  finished_at TIMESTAMP NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS completed_reads_user_id_book_id_idx ON completed_reads(user_id, book_id);
+
+INSERT INTO completed_reads (user_id, book_id, started_at, finished_at)
+SELECT user_id, book_id, started_at, finished_at FROM reading_progress WHERE status = 'finished';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reading_events;
+
+DROP TABLE IF EXISTS reading_sessions;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS reading_sessions(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id VARCHAR(50) NOT NULL,
+  book_id UUID NOT NULL,
+  started_at TIMESTAMP NOT NULL,
+  ended_at TIMESTAMP NOT NULL,
+  start_page INTEGER NOT NULL,
+  end_page INTEGER NOT NULL,
+  pages_read INTEGER NOT NULL DEFAULT 0,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS reading_sessions_user_id_started_at_idx ON reading_sessions(user_id, started_at);
+
+CREATE TABLE IF NOT EXISTS reading_events(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  session_id UUID NOT NULL,
+  user_id VARCHAR(50) NOT NULL,
+  book_id UUID NOT NULL,
+  from_page INTEGER NOT NULL,
+  to_page INTEGER NOT NULL,
+  read_at TIMESTAMP NOT NULL,
+  recorded_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (session_id) REFERENCES reading_sessions(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS reading_events_user_id_read_at_idx ON reading_events(user_id, read_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reading_goals;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS time_zone;
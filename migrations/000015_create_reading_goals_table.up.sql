@@ -0,0 +1,16 @@
+ALTER TABLE users
+ADD time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE IF NOT EXISTS reading_goals(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id VARCHAR(50) NOT NULL,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('yearly_books', 'daily_pages', 'daily_minutes')),
+  target INTEGER NOT NULL CHECK (target > 0),
+  year INTEGER,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  CHECK ((kind = 'yearly_books') = (year IS NOT NULL)),
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS reading_goals_user_id_kind_year_idx ON reading_goals(user_id, kind, COALESCE(year, 0));
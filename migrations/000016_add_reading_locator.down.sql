@@ -0,0 +1,5 @@
+ALTER TABLE reading_progress
+DROP COLUMN IF EXISTS locator_total_progression,
+DROP COLUMN IF EXISTS locator_progression,
+DROP COLUMN IF EXISTS locator_cfi,
+DROP COLUMN IF EXISTS locator_href;
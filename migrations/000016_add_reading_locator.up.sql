@@ -0,0 +1,5 @@
+ALTER TABLE reading_progress
+ADD locator_href TEXT,
+ADD locator_cfi TEXT,
+ADD locator_progression DOUBLE PRECISION CHECK (locator_progression BETWEEN 0 AND 1),
+ADD locator_total_progression DOUBLE PRECISION CHECK (locator_total_progression BETWEEN 0 AND 1);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS reading_positions(
+  user_id VARCHAR(50) NOT NULL,
+  book_id UUID NOT NULL,
+  device_id VARCHAR(100) NOT NULL,
+  device_name VARCHAR(100),
+  current_page INTEGER NOT NULL,
+  percentage_complete DECIMAL(5, 2) NOT NULL,
+  locator_href TEXT,
+  locator_cfi TEXT,
+  locator_progression DOUBLE PRECISION,
+  locator_total_progression DOUBLE PRECISION,
+  read_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, book_id, device_id),
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE
+);
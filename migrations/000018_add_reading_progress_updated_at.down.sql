@@ -0,0 +1,2 @@
+ALTER TABLE reading_progress
+DROP COLUMN IF EXISTS updated_at;
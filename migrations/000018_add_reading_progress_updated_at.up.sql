@@ -0,0 +1,4 @@
+ALTER TABLE reading_progress
+ADD updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS reading_progress_user_id_updated_at_idx ON reading_progress(user_id, updated_at);
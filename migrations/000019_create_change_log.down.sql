@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS highlights_change_log ON highlights;
+DROP TRIGGER IF EXISTS shelf_books_change_log ON shelf_books;
+DROP TRIGGER IF EXISTS shelves_change_log ON shelves;
+DROP TRIGGER IF EXISTS reading_progress_change_log ON reading_progress;
+DROP TRIGGER IF EXISTS book_shares_change_log ON book_shares;
+DROP TRIGGER IF EXISTS books_change_log_delete ON books;
+DROP TRIGGER IF EXISTS books_change_log ON books;
+
+DROP FUNCTION IF EXISTS log_annotation_change();
+DROP FUNCTION IF EXISTS log_shelf_book_change();
+DROP FUNCTION IF EXISTS log_shelf_change();
+DROP FUNCTION IF EXISTS log_reading_progress_change();
+DROP FUNCTION IF EXISTS log_book_share_change();
+DROP FUNCTION IF EXISTS log_book_change();
+DROP FUNCTION IF EXISTS log_change(TEXT, TEXT, TEXT, TEXT);
+
+DROP TABLE IF EXISTS change_log;
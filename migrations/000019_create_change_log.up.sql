@@ -0,0 +1,160 @@
+CREATE TABLE IF NOT EXISTS change_log(
+  id BIGSERIAL PRIMARY KEY,
+  user_id VARCHAR(50) NOT NULL,
+  entity VARCHAR(30) NOT NULL CHECK (entity IN ('book', 'reading_progress', 'shelf', 'annotation')),
+  entity_id TEXT NOT NULL,
+  operation VARCHAR(10) NOT NULL CHECK (operation IN ('created', 'updated', 'deleted')),
+  changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS change_log_user_id_id_idx ON change_log(user_id, id);
+
+-- Writers take a transaction-wide lock so change ids become visible in the
+-- order they were handed out. Without it a transaction could commit a lower id
+-- after a client has already synced past it, and the change would be lost.
+CREATE OR REPLACE FUNCTION log_change(p_user_id TEXT, p_entity TEXT, p_entity_id TEXT, p_operation TEXT) RETURNS void AS $$
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('change_log'));
+  INSERT INTO change_log (user_id, entity, entity_id, operation)
+  VALUES (p_user_id, p_entity, p_entity_id, p_operation);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Books are logged for the owner and for everyone the book is shared with.
+-- Deletions are logged before the row goes so the shares are still there.
+CREATE OR REPLACE FUNCTION log_book_change() RETURNS trigger AS $$
+DECLARE
+  book books%ROWTYPE;
+  op TEXT;
+  recipient TEXT;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    book := OLD;
+    op := 'deleted';
+  ELSIF TG_OP = 'INSERT' THEN
+    book := NEW;
+    op := 'created';
+  ELSE
+    book := NEW;
+    op := 'updated';
+  END IF;
+
+  PERFORM log_change(book.owner_id, 'book', book.id::text, op);
+  FOR recipient IN SELECT user_id FROM book_shares WHERE book_id = book.id LOOP
+    PERFORM log_change(recipient, 'book', book.id::text, op);
+  END LOOP;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER books_change_log AFTER INSERT OR UPDATE ON books
+FOR EACH ROW EXECUTE FUNCTION log_book_change();
+
+CREATE TRIGGER books_change_log_delete BEFORE DELETE ON books
+FOR EACH ROW EXECUTE FUNCTION log_book_change();
+
+-- Gaining or losing a share shows up to the recipient as the book appearing
+-- or disappearing.
+CREATE OR REPLACE FUNCTION log_book_share_change() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    PERFORM log_change(OLD.user_id, 'book', OLD.book_id::text, 'deleted');
+    RETURN OLD;
+  END IF;
+  PERFORM log_change(NEW.user_id, 'book', NEW.book_id::text, 'created');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER book_shares_change_log AFTER INSERT OR DELETE ON book_shares
+FOR EACH ROW EXECUTE FUNCTION log_book_share_change();
+
+-- Reading progress is keyed by book, since a user has one row per book.
+CREATE OR REPLACE FUNCTION log_reading_progress_change() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    PERFORM log_change(OLD.user_id, 'reading_progress', OLD.book_id::text, 'deleted');
+    RETURN OLD;
+  END IF;
+  PERFORM log_change(NEW.user_id, 'reading_progress', NEW.book_id::text, CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reading_progress_change_log AFTER INSERT OR UPDATE OR DELETE ON reading_progress
+FOR EACH ROW EXECUTE FUNCTION log_reading_progress_change();
+
+CREATE OR REPLACE FUNCTION log_shelf_change() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    PERFORM log_change(OLD.user_id, 'shelf', OLD.id::text, 'deleted');
+    RETURN OLD;
+  END IF;
+  PERFORM log_change(NEW.user_id, 'shelf', NEW.id::text, CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER shelves_change_log AFTER INSERT OR UPDATE OR DELETE ON shelves
+FOR EACH ROW EXECUTE FUNCTION log_shelf_change();
+
+-- A shelf's books are part of the shelf, so membership changes are logged as
+-- shelf updates. When the whole shelf is deleted the shelf is already gone and
+-- its own deletion covers it.
+CREATE OR REPLACE FUNCTION log_shelf_book_change() RETURNS trigger AS $$
+DECLARE
+  shelf_owner TEXT;
+  changed_shelf UUID;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    changed_shelf := OLD.shelf_id;
+  ELSE
+    changed_shelf := NEW.shelf_id;
+  END IF;
+
+  SELECT user_id INTO shelf_owner FROM shelves WHERE id = changed_shelf;
+  IF shelf_owner IS NOT NULL THEN
+    PERFORM log_change(shelf_owner, 'shelf', changed_shelf::text, 'updated');
+  END IF;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER shelf_books_change_log AFTER INSERT OR DELETE ON shelf_books
+FOR EACH ROW EXECUTE FUNCTION log_shelf_book_change();
+
+CREATE OR REPLACE FUNCTION log_annotation_change() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    PERFORM log_change(OLD.user_id, 'annotation', OLD.id::text, 'deleted');
+    RETURN OLD;
+  END IF;
+  PERFORM log_change(NEW.user_id, 'annotation', NEW.id::text, CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER highlights_change_log AFTER INSERT OR UPDATE OR DELETE ON highlights
+FOR EACH ROW EXECUTE FUNCTION log_annotation_change();
+
+-- Seed the log with what already exists so a client syncing from the start
+-- of the log gets the full picture.
+INSERT INTO change_log (user_id, entity, entity_id, operation)
+SELECT owner_id, 'book', id::text, 'created' FROM books
+UNION ALL
+SELECT user_id, 'book', book_id::text, 'created' FROM book_shares
+UNION ALL
+SELECT user_id, 'reading_progress', book_id::text, 'created' FROM reading_progress
+UNION ALL
+SELECT user_id, 'shelf', id::text, 'created' FROM shelves
+UNION ALL
+SELECT user_id, 'annotation', id::text, 'created' FROM highlights;
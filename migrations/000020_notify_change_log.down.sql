@@ -0,0 +1,7 @@
+CREATE OR REPLACE FUNCTION log_change(p_user_id TEXT, p_entity TEXT, p_entity_id TEXT, p_operation TEXT) RETURNS void AS $$
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('change_log'));
+  INSERT INTO change_log (user_id, entity, entity_id, operation)
+  VALUES (p_user_id, p_entity, p_entity_id, p_operation);
+END;
+$$ LANGUAGE plpgsql;
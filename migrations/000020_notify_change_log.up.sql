@@ -0,0 +1,15 @@
+-- Every change is also announced on the change_log channel so each API
+-- replica can push it to the clients connected to it. Notifications are only
+-- delivered once the writing transaction commits.
+CREATE OR REPLACE FUNCTION log_change(p_user_id TEXT, p_entity TEXT, p_entity_id TEXT, p_operation TEXT) RETURNS void AS $$
+DECLARE
+  entry change_log%ROWTYPE;
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('change_log'));
+  INSERT INTO change_log (user_id, entity, entity_id, operation)
+  VALUES (p_user_id, p_entity, p_entity_id, p_operation)
+  RETURNING * INTO entry;
+
+  PERFORM pg_notify('change_log', row_to_json(entry)::text);
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS bookmarks_change_log ON bookmarks;
+DROP FUNCTION IF EXISTS log_bookmark_change();
+
+DELETE FROM change_log WHERE entity = 'bookmark';
+
+ALTER TABLE change_log
+DROP CONSTRAINT change_log_entity_check,
+ADD CONSTRAINT change_log_entity_check CHECK (entity IN ('book', 'reading_progress', 'shelf', 'annotation'));
+
+DROP TABLE IF EXISTS bookmarks;
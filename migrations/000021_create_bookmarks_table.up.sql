@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS bookmarks(
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  book_id UUID NOT NULL,
+  user_id VARCHAR(50) NOT NULL,
+  page_number INTEGER,
+  locator_href TEXT,
+  locator_cfi TEXT,
+  locator_progression DOUBLE PRECISION CHECK (locator_progression BETWEEN 0 AND 1),
+  locator_total_progression DOUBLE PRECISION CHECK (locator_total_progression BETWEEN 0 AND 1),
+  label VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  CHECK (page_number IS NOT NULL OR locator_href IS NOT NULL),
+  FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS bookmarks_book_id_user_id_idx ON bookmarks(book_id, user_id);
+
+ALTER TABLE change_log
+DROP CONSTRAINT change_log_entity_check,
+ADD CONSTRAINT change_log_entity_check CHECK (entity IN ('book', 'reading_progress', 'shelf', 'annotation', 'bookmark'));
+
+CREATE OR REPLACE FUNCTION log_bookmark_change() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    PERFORM log_change(OLD.user_id, 'bookmark', OLD.id::text, 'deleted');
+    RETURN OLD;
+  END IF;
+  PERFORM log_change(NEW.user_id, 'bookmark', NEW.id::text, CASE TG_OP WHEN 'INSERT' THEN 'created' ELSE 'updated' END);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bookmarks_change_log AFTER INSERT OR UPDATE OR DELETE ON bookmarks
+FOR EACH ROW EXECUTE FUNCTION log_bookmark_change();
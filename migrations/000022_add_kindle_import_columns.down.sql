@@ -0,0 +1,16 @@
+DELETE FROM bookmarks WHERE page_number IS NULL AND locator_href IS NULL;
+
+ALTER TABLE bookmarks
+DROP CONSTRAINT bookmarks_position_check,
+ADD CONSTRAINT bookmarks_check CHECK (page_number IS NOT NULL OR locator_href IS NOT NULL),
+DROP COLUMN location;
+
+ALTER TABLE highlights
+DROP COLUMN location_end,
+DROP COLUMN location_start;
+
+DELETE FROM reading_progress WHERE book_id IN (SELECT id FROM books WHERE s3_key IS NULL);
+DELETE FROM books WHERE s3_key IS NULL;
+
+ALTER TABLE books
+ALTER COLUMN s3_key SET NOT NULL;
@@ -0,0 +1,12 @@
+-- Books imported from a Kindle have metadata and highlights but no file.
+ALTER TABLE books
+ALTER COLUMN s3_key DROP NOT NULL;
+
+ALTER TABLE highlights
+ADD location_start INTEGER,
+ADD location_end INTEGER;
+
+ALTER TABLE bookmarks
+ADD location INTEGER,
+DROP CONSTRAINT bookmarks_check,
+ADD CONSTRAINT bookmarks_position_check CHECK (page_number IS NOT NULL OR locator_href IS NOT NULL OR location IS NOT NULL);
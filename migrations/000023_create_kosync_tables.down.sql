@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS kosync_progress;
+DROP TABLE IF EXISTS kosync_accounts;
+
+DROP INDEX IF EXISTS books_koreader_hash_idx;
+
+ALTER TABLE books
+DROP COLUMN koreader_hash;
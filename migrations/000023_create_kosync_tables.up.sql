@@ -0,0 +1,28 @@
+-- KOReader identifies documents by a partial MD5 of the file.
+ALTER TABLE books
+ADD koreader_hash VARCHAR(32);
+
+CREATE INDEX IF NOT EXISTS books_koreader_hash_idx ON books(koreader_hash);
+
+CREATE TABLE IF NOT EXISTS kosync_accounts(
+  user_id VARCHAR(50) PRIMARY KEY,
+  username VARCHAR(100) NOT NULL UNIQUE,
+  key_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- The position exactly as KOReader reported it, so it gets back the xpointer
+-- it sent rather than our translation of it.
+CREATE TABLE IF NOT EXISTS kosync_progress(
+  user_id VARCHAR(50) NOT NULL,
+  document VARCHAR(32) NOT NULL,
+  progress TEXT NOT NULL,
+  percentage DOUBLE PRECISION NOT NULL,
+  device VARCHAR(100) NOT NULL,
+  device_id VARCHAR(100) NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, document),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
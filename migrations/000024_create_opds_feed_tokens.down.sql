@@ -0,0 +1 @@
+DROP TABLE IF EXISTS opds_feed_tokens;
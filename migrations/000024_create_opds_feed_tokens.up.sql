@@ -0,0 +1,9 @@
+-- E-reader apps can't sign in through Clerk, so each user can have a feed
+-- token to put in the catalog URL or send as the HTTP Basic password. Only its
+-- SHA-256 is stored.
+CREATE TABLE IF NOT EXISTS opds_feed_tokens(
+  user_id VARCHAR(50) PRIMARY KEY,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
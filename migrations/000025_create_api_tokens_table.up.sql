@@ -0,0 +1,17 @@
+-- Personal access tokens for scripts and integrations that can't use Clerk
+-- sessions. Only the SHA-256 of a token is stored; the prefix is kept so
+-- users can tell their tokens apart.
+CREATE TABLE IF NOT EXISTS api_tokens(
+  id UUID PRIMARY KEY,
+  user_id VARCHAR(50) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  token_prefix VARCHAR(16) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_used_at TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['read', 'write']::TEXT[])
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_user_id_idx ON api_tokens(user_id);
@@ -0,0 +1,4 @@
+-- When the profile was last copied from the identity provider. Lets every
+-- instance skip the provider lookup while the stored row is fresh.
+ALTER TABLE users
+ADD profile_synced_at TIMESTAMP;
@@ -0,0 +1,7 @@
+UPDATE reading_progress
+SET last_read_at = updated_at
+WHERE last_read_at IS NULL;
+
+ALTER TABLE reading_progress
+ALTER COLUMN last_read_at SET DEFAULT CURRENT_TIMESTAMP,
+ALTER COLUMN last_read_at SET NOT NULL;
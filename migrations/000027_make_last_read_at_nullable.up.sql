@@ -0,0 +1,14 @@
+-- A progress row is created before the book is read, e.g. when it is shared,
+-- so last_read_at is only set by an actual read. Until then it is NULL and the
+-- first update from any device is never treated as out of date.
+ALTER TABLE reading_progress
+ALTER COLUMN last_read_at DROP NOT NULL,
+ALTER COLUMN last_read_at DROP DEFAULT;
+
+UPDATE reading_progress
+SET last_read_at = NULL
+WHERE status = 'want_to_read' AND percentage_complete = 0
+  AND NOT EXISTS (
+    SELECT 1 FROM reading_events
+    WHERE reading_events.user_id = reading_progress.user_id AND reading_events.book_id = reading_progress.book_id
+  );
@@ -0,0 +1,41 @@
+CREATE OR REPLACE FUNCTION log_change(p_user_id TEXT, p_entity TEXT, p_entity_id TEXT, p_operation TEXT) RETURNS void AS $$
+DECLARE
+  entry change_log%ROWTYPE;
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('change_log'));
+  INSERT INTO change_log (user_id, entity, entity_id, operation)
+  VALUES (p_user_id, p_entity, p_entity_id, p_operation)
+  RETURNING * INTO entry;
+
+  PERFORM pg_notify('change_log', row_to_json(entry)::text);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION log_book_change() RETURNS trigger AS $$
+DECLARE
+  book books%ROWTYPE;
+  op TEXT;
+  recipient TEXT;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    book := OLD;
+    op := 'deleted';
+  ELSIF TG_OP = 'INSERT' THEN
+    book := NEW;
+    op := 'created';
+  ELSE
+    book := NEW;
+    op := 'updated';
+  END IF;
+
+  PERFORM log_change(book.owner_id, 'book', book.id::text, op);
+  FOR recipient IN SELECT user_id FROM book_shares WHERE book_id = book.id LOOP
+    PERFORM log_change(recipient, 'book', book.id::text, op);
+  END LOOP;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
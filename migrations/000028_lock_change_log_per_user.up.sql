@@ -0,0 +1,56 @@
+-- Change ids only have to become visible in order within one user's log, since
+-- clients sync their own user's changes. Locking per user keeps that guarantee
+-- without making every writer in the database wait on each other.
+CREATE OR REPLACE FUNCTION log_change(p_user_id TEXT, p_entity TEXT, p_entity_id TEXT, p_operation TEXT) RETURNS void AS $$
+DECLARE
+  entry change_log%ROWTYPE;
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('change_log:' || p_user_id));
+  INSERT INTO change_log (user_id, entity, entity_id, operation)
+  VALUES (p_user_id, p_entity, p_entity_id, p_operation)
+  RETURNING * INTO entry;
+
+  PERFORM pg_notify('change_log', row_to_json(entry)::text);
+END;
+$$ LANGUAGE plpgsql;
+
+-- A book change is logged for its owner and every recipient. Their locks are
+-- taken up front in a fixed order, so two transactions logging for the same
+-- users can't deadlock by locking them in opposite orders.
+CREATE OR REPLACE FUNCTION log_book_change() RETURNS trigger AS $$
+DECLARE
+  book books%ROWTYPE;
+  op TEXT;
+  recipient TEXT;
+  lock_key INTEGER;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    book := OLD;
+    op := 'deleted';
+  ELSIF TG_OP = 'INSERT' THEN
+    book := NEW;
+    op := 'created';
+  ELSE
+    book := NEW;
+    op := 'updated';
+  END IF;
+
+  FOR lock_key IN
+    SELECT DISTINCT hashtext('change_log:' || user_id)
+    FROM (SELECT book.owner_id AS user_id UNION SELECT user_id FROM book_shares WHERE book_id = book.id) AS users
+    ORDER BY 1
+  LOOP
+    PERFORM pg_advisory_xact_lock(lock_key);
+  END LOOP;
+
+  PERFORM log_change(book.owner_id, 'book', book.id::text, op);
+  FOR recipient IN SELECT user_id FROM book_shares WHERE book_id = book.id LOOP
+    PERFORM log_change(recipient, 'book', book.id::text, op);
+  END LOOP;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,6 @@
+DELETE FROM book_pages WHERE part > 0;
+
+ALTER TABLE book_pages DROP CONSTRAINT IF EXISTS book_pages_pkey;
+ALTER TABLE book_pages ADD PRIMARY KEY (book_id, page_number);
+
+ALTER TABLE book_pages DROP COLUMN IF EXISTS part;
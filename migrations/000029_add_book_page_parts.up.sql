@@ -0,0 +1,5 @@
+-- A page whose text is too long for one tsvector is stored as several parts.
+ALTER TABLE book_pages ADD COLUMN IF NOT EXISTS part INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE book_pages DROP CONSTRAINT IF EXISTS book_pages_pkey;
+ALTER TABLE book_pages ADD PRIMARY KEY (book_id, page_number, part);
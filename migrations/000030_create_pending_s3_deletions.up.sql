@@ -0,0 +1,13 @@
+-- Objects still to be removed from S3. Rows are written in the same
+-- transaction that deletes what referenced the objects and are only removed
+-- once S3 confirms the delete, so a failure or restart can't orphan a file.
+CREATE TABLE IF NOT EXISTS pending_s3_deletions(
+  id BIGSERIAL PRIMARY KEY,
+  s3_key TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS pending_s3_deletions_next_attempt_at_idx ON pending_s3_deletions(next_attempt_at);
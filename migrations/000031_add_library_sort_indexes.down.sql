@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS books_owner_id_title_idx;
+DROP INDEX IF EXISTS books_owner_id_author_idx;
+DROP INDEX IF EXISTS books_owner_id_added_at_idx;
+DROP INDEX IF EXISTS reading_progress_user_id_last_read_at_idx;
+DROP INDEX IF EXISTS reading_progress_user_id_percentage_complete_idx;
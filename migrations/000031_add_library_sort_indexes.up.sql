@@ -0,0 +1,17 @@
+-- The library is paged along these indexes: owned books by their own
+-- columns, and every readable book by its reader's progress.
+CREATE INDEX IF NOT EXISTS books_owner_id_title_idx ON books(owner_id, lower(title), id);
+CREATE INDEX IF NOT EXISTS books_owner_id_author_idx ON books(owner_id, lower(COALESCE(author, '')), id);
+CREATE INDEX IF NOT EXISTS books_owner_id_added_at_idx ON books(owner_id, added_at, id);
+CREATE INDEX IF NOT EXISTS reading_progress_user_id_last_read_at_idx ON reading_progress(user_id, COALESCE(last_read_at, 'epoch'::timestamp), book_id);
+CREATE INDEX IF NOT EXISTS reading_progress_user_id_percentage_complete_idx ON reading_progress(user_id, percentage_complete, book_id);
+
+-- Sorting by progress only sees books with a progress row, which owners and
+-- share recipients get when the book is added or shared.
+INSERT INTO reading_progress (book_id, user_id)
+SELECT id, owner_id FROM books
+ON CONFLICT DO NOTHING;
+
+INSERT INTO reading_progress (book_id, user_id)
+SELECT book_id, user_id FROM book_shares
+ON CONFLICT DO NOTHING;
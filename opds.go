@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/opds"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/Hodik/noteshelf-be.git/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	opdsPageSize    = 50
+	opdsSearchLimit = 50
+)
+
+var opdsAcquisitionTypes = map[string]string{
+	"epub": "application/epub+zip",
+	"pdf":  "application/pdf",
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type OPDSFeedTokenResponse struct {
+	Token       string    `json:"token,omitempty"`
+	CatalogPath string    `json:"catalog_path,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func getOPDSFeedTokenHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	token, err := cfg.Queries.GetOPDSFeedTokenByUserID(c, dbUser.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no feed token"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, OPDSFeedTokenResponse{CreatedAt: token.CreatedAt})
+}
+
+// createOPDSFeedTokenHandler issues a new feed token, replacing any previous
+// one. The token is only ever shown in this response.
+func createOPDSFeedTokenHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !requireSession(c) {
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	stored, err := cfg.Queries.UpsertOPDSFeedToken(c, repository.UpsertOPDSFeedTokenParams{UserID: dbUser.ID, TokenHash: hashFeedToken(token)})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, OPDSFeedTokenResponse{Token: token, CatalogPath: "/opds/t/" + token, CreatedAt: stored.CreatedAt})
+}
+
+func deleteOPDSFeedTokenHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !requireSession(c) {
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteOPDSFeedToken(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "no feed token"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// opdsAuthMiddleware authenticates catalog requests by the feed token, taken
+// from the path when the catalog was added by URL or from the HTTP Basic
+// password otherwise. The Basic username is not checked.
+func opdsAuthMiddleware(c *gin.Context) {
+	token := c.Param("feed_token")
+	if token == "" {
+		_, password, ok := c.Request.BasicAuth()
+		if !ok || password == "" {
+			c.Header("WWW-Authenticate", `Basic realm="Noteshelf", charset="UTF-8"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		token = password
+	}
+
+	user, err := cfg.Queries.GetUserByOPDSFeedToken(c, hashFeedToken(token))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.Header("WWW-Authenticate", `Basic realm="Noteshelf", charset="UTF-8"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set("dbUser", &user)
+	c.Next()
+}
+
+func opdsVersionMiddleware(version opds.Version) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("opdsVersion", version)
+		c.Next()
+	}
+}
+
+// registerOPDSRoutes serves the catalog as OPDS 1.2 at the root of group and
+// as OPDS 2.0 under /v2.
+func registerOPDSRoutes(group *gin.RouterGroup) {
+	v1 := group.Group("", opdsVersionMiddleware(opds.Version1))
+	v2 := group.Group("/v2", opdsVersionMiddleware(opds.Version2))
+	for _, g := range []*gin.RouterGroup{v1, v2} {
+		g.GET("", opdsRootHandler)
+		g.GET("/books", opdsBooksHandler)
+		g.GET("/recent", opdsRecentHandler)
+		g.GET("/authors", opdsAuthorsHandler)
+		g.GET("/shelves", opdsShelvesHandler)
+		g.GET("/shelves/:shelf_id", opdsShelfHandler)
+		g.GET("/search", opdsSearchHandler)
+	}
+	v1.GET("/opensearch.xml", opdsOpenSearchHandler)
+}
+
+func opdsVersion(c *gin.Context) opds.Version {
+	return c.MustGet("opdsVersion").(opds.Version)
+}
+
+// opdsBase is the path the catalog was requested under, which every link in
+// it has to keep.
+func opdsBase(c *gin.Context) string {
+	base := "/opds"
+	if token := c.Param("feed_token"); token != "" {
+		base += "/t/" + url.PathEscape(token)
+	}
+	if opdsVersion(c) == opds.Version2 {
+		base += "/v2"
+	}
+	return base
+}
+
+func newOPDSFeed(c *gin.Context, id, title, path string) opds.Feed {
+	return opds.Feed{
+		Base:    opdsBase(c),
+		ID:      "urn:noteshelf:opds:" + id,
+		Title:   title,
+		Updated: time.Now().UTC(),
+		Path:    path,
+	}
+}
+
+func sendOPDSFeed(c *gin.Context, feed opds.Feed) {
+	var buf bytes.Buffer
+	var err error
+	contentType := opds.JSONType
+	if opdsVersion(c) == opds.Version1 {
+		contentType = opds.NavigationType
+		if feed.Acquisition {
+			contentType = opds.AcquisitionType
+		}
+		err = opds.Atom(&buf, feed)
+	} else {
+		err = opds.JSON(&buf, feed)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// opdsPublication links a book to signed CloudFront URLs for its file and
+// cover. Books imported without a file are listed without a download.
+func opdsPublication(book repository.Book) (opds.Publication, error) {
+	publication := opds.Publication{
+		ID:          "urn:uuid:" + book.ID.String(),
+		Title:       book.Title,
+		Author:      deref(book.Author),
+		Language:    deref(book.Language),
+		ISBN:        deref(book.Isbn),
+		Description: deref(book.Description),
+		Updated:     book.AddedAt,
+	}
+
+	if book.S3Key != nil {
+		url, err := utils.GeneratePresignedReadURL(cfg.CloudfrontUrl, *book.S3Key, cfg.KeyPairID, int(cfg.PresignedUrlExpirySeconds), cfg.PrivateSignKey)
+		if err != nil {
+			return publication, err
+		}
+		publication.Acquisition = url
+		publication.AcquisitionType = "application/octet-stream"
+		if contentType, ok := opdsAcquisitionTypes[deref(book.Format)]; ok {
+			publication.AcquisitionType = contentType
+		}
+	}
+
+	coverURLs, err := signedCoverURLs(book)
+	if err != nil {
+		return publication, err
+	}
+	publication.Image = cmp.Or(coverURLs[coverLarge], coverURLs[coverMedium])
+	publication.Thumbnail = cmp.Or(coverURLs[coverSmall], coverURLs[coverMedium])
+
+	return publication, nil
+}
+
+// sendOPDSBooks sends one page of books as an acquisition feed. Feed paths
+// keep the request's query so paging through a filtered list stays filtered.
+func sendOPDSBooks(c *gin.Context, feed opds.Feed, books []repository.Book) {
+	page := 1
+	if raw := c.Query("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive number"})
+			return
+		}
+		page = n
+	}
+
+	pagePath := func(n int) string {
+		query := c.Request.URL.Query()
+		query.Del("page")
+		if n > 1 {
+			query.Set("page", strconv.Itoa(n))
+		}
+		if len(query) == 0 {
+			return feed.Path
+		}
+		return feed.Path + "?" + query.Encode()
+	}
+
+	feed.Acquisition = true
+	feed.Page, feed.PageSize, feed.Total = page, opdsPageSize, len(books)
+	start := min((page-1)*opdsPageSize, len(books))
+	end := min(start+opdsPageSize, len(books))
+	if end < len(books) {
+		feed.Next = pagePath(page + 1)
+	}
+	if page > 1 {
+		feed.Previous = pagePath(page - 1)
+	}
+	feed.Path = pagePath(page)
+
+	for _, book := range books[start:end] {
+		publication, err := opdsPublication(book)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		feed.Publications = append(feed.Publications, publication)
+	}
+
+	sendOPDSFeed(c, feed)
+}
+
+// getOPDSLibrary returns the user's own books, sorted by title. The request
+// is aborted when ok is false.
+func getOPDSLibrary(c *gin.Context) (rows []repository.GetBooksByOwnerIDRow, ok bool) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	rows, err = cfg.Queries.GetBooksByOwnerID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return rows, true
+}
+
+func opdsRootHandler(c *gin.Context) {
+	rows, ok := getOPDSLibrary(c)
+	if !ok {
+		return
+	}
+
+	feed := newOPDSFeed(c, "root", "Noteshelf", "")
+	feed.Navigation = []opds.Navigation{
+		{ID: feed.ID + ":books", Title: "All books", Summary: strconv.Itoa(len(rows)) + " books", Path: "/books", Acquisition: true, Updated: feed.Updated},
+		{ID: feed.ID + ":recent", Title: "Recently read", Path: "/recent", Acquisition: true, Updated: feed.Updated},
+		{ID: feed.ID + ":authors", Title: "Authors", Path: "/authors", Updated: feed.Updated},
+		{ID: feed.ID + ":shelves", Title: "Shelves", Path: "/shelves", Updated: feed.Updated},
+	}
+
+	sendOPDSFeed(c, feed)
+}
+
+// opdsBooksHandler lists the whole library, or the books of one author when
+// the author query parameter is set.
+func opdsBooksHandler(c *gin.Context) {
+	rows, ok := getOPDSLibrary(c)
+	if !ok {
+		return
+	}
+
+	feed := newOPDSFeed(c, "books", "All books", "/books")
+	author := c.Query("author")
+	if author != "" {
+		feed.ID += ":author:" + url.PathEscape(author)
+		feed.Title = author
+		feed.Up = "/authors"
+	}
+
+	books := make([]repository.Book, 0, len(rows))
+	for _, row := range rows {
+		if author == "" || deref(row.Book.Author) == author {
+			books = append(books, row.Book)
+		}
+	}
+
+	sendOPDSBooks(c, feed, books)
+}
+
+func opdsRecentHandler(c *gin.Context) {
+	rows, ok := getOPDSLibrary(c)
+	if !ok {
+		return
+	}
+
+	rows = slices.DeleteFunc(rows, func(row repository.GetBooksByOwnerIDRow) bool {
+		return !row.LastReadAt.Valid
+	})
+	slices.SortStableFunc(rows, func(a, b repository.GetBooksByOwnerIDRow) int {
+		return b.LastReadAt.Time.Compare(a.LastReadAt.Time)
+	})
+
+	books := make([]repository.Book, 0, len(rows))
+	for _, row := range rows {
+		books = append(books, row.Book)
+	}
+
+	sendOPDSBooks(c, newOPDSFeed(c, "recent", "Recently read", "/recent"), books)
+}
+
+func opdsAuthorsHandler(c *gin.Context) {
+	rows, ok := getOPDSLibrary(c)
+	if !ok {
+		return
+	}
+
+	counts := map[string]int{}
+	for _, row := range rows {
+		if author := deref(row.Book.Author); author != "" {
+			counts[author]++
+		}
+	}
+
+	authors := make([]string, 0, len(counts))
+	for author := range counts {
+		authors = append(authors, author)
+	}
+	slices.SortFunc(authors, func(a, b string) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a), strings.ToLower(b)), cmp.Compare(a, b))
+	})
+
+	feed := newOPDSFeed(c, "authors", "Authors", "/authors")
+	for _, author := range authors {
+		feed.Navigation = append(feed.Navigation, opds.Navigation{
+			ID:          feed.ID + ":" + url.PathEscape(author),
+			Title:       author,
+			Summary:     strconv.Itoa(counts[author]) + " books",
+			Path:        "/books?" + url.Values{"author": {author}}.Encode(),
+			Acquisition: true,
+			Updated:     feed.Updated,
+		})
+	}
+
+	sendOPDSFeed(c, feed)
+}
+
+func opdsShelvesHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelves, err := cfg.Queries.GetShelvesByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	feed := newOPDSFeed(c, "shelves", "Shelves", "/shelves")
+	for _, shelf := range shelves {
+		feed.Navigation = append(feed.Navigation, opds.Navigation{
+			ID:          feed.ID + ":" + shelf.Shelf.ID.String(),
+			Title:       shelf.Shelf.Name,
+			Summary:     strconv.Itoa(int(shelf.BookCount)) + " books",
+			Path:        "/shelves/" + shelf.Shelf.ID.String(),
+			Acquisition: true,
+			Updated:     shelf.Shelf.UpdatedAt,
+		})
+	}
+
+	sendOPDSFeed(c, feed)
+}
+
+// opdsShelfHandler lists a shelf in the order books were added to it. Books
+// shared with the user can be shelved but aren't part of the catalog, so they
+// are left out.
+func opdsShelfHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelfID := c.Param("shelf_id")
+	uuidShelfID, err := uuid.Parse(shelfID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": shelfID + " is not a valid uuid"})
+		return
+	}
+
+	shelf, err := cfg.Queries.GetShelfByID(c, repository.GetShelfByIDParams{ID: uuidShelfID, UserID: dbUser.ID})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "shelf not found"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	shelved, err := cfg.Queries.GetShelfBookIDs(c, []uuid.UUID{shelf.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, ok := getOPDSLibrary(c)
+	if !ok {
+		return
+	}
+	owned := make(map[uuid.UUID]repository.Book, len(rows))
+	for _, row := range rows {
+		owned[row.Book.ID] = row.Book
+	}
+
+	books := make([]repository.Book, 0, len(shelved))
+	for _, entry := range shelved {
+		if book, ok := owned[entry.BookID]; ok {
+			books = append(books, book)
+		}
+	}
+
+	feed := newOPDSFeed(c, "shelves:"+shelf.ID.String(), shelf.Name, "/shelves/"+shelf.ID.String())
+	feed.Up = "/shelves"
+	sendOPDSBooks(c, feed, books)
+}
+
+// opdsSearchHandler searches titles and authors. OPDS 1.2 clients fill in the
+// q parameter of the OpenSearch template and OPDS 2.0 clients query.
+func opdsSearchHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.TrimSpace(cmp.Or(c.Query("q"), c.Query("query")))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	results, err := cfg.Queries.SearchBooks(c, repository.SearchBooksParams{Query: query, UserID: dbUser.ID, ResultLimit: opdsSearchLimit})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, ok := getOPDSLibrary(c)
+	if !ok {
+		return
+	}
+	owned := make(map[uuid.UUID]repository.Book, len(rows))
+	for _, row := range rows {
+		owned[row.Book.ID] = row.Book
+	}
+
+	books := make([]repository.Book, 0, len(results))
+	for _, result := range results {
+		if book, ok := owned[result.BookID]; ok {
+			books = append(books, book)
+		}
+	}
+
+	feed := newOPDSFeed(c, "search:"+url.PathEscape(query), "Search: "+query, "/search")
+	sendOPDSBooks(c, feed, books)
+}
+
+func opdsOpenSearchHandler(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := opds.OpenSearch(&buf, opdsBase(c)); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, opds.OpenSearchType, buf.Bytes())
+}
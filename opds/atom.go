@@ -0,0 +1,184 @@
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	relAcquisition = "http://opds-spec.org/acquisition"
+	relImage       = "http://opds-spec.org/image"
+	relThumbnail   = "http://opds-spec.org/image/thumbnail"
+)
+
+type atomFeed struct {
+	XMLName      xml.Name    `xml:"feed"`
+	Xmlns        string      `xml:"xmlns,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string      `xml:"xmlns:opds,attr"`
+	XmlnsSearch  string      `xml:"xmlns:opensearch,attr"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	Author       atomAuthor  `xml:"author"`
+	TotalResults int         `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int         `xml:"opensearch:itemsPerPage,omitempty"`
+	Links        []atomLink  `xml:"link"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string       `xml:"id"`
+	Title      string       `xml:"title"`
+	Updated    string       `xml:"updated"`
+	Authors    []atomAuthor `xml:"author,omitempty"`
+	Language   string       `xml:"dc:language,omitempty"`
+	Identifier string       `xml:"dc:identifier,omitempty"`
+	Summary    *atomText    `xml:"summary,omitempty"`
+	Content    *atomText    `xml:"content,omitempty"`
+	Links      []atomLink   `xml:"link"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (f Feed) kind() string {
+	if f.Acquisition {
+		return AcquisitionType
+	}
+	return NavigationType
+}
+
+// Atom writes the feed as an OPDS 1.2 Atom document.
+func Atom(w io.Writer, f Feed) error {
+	feed := atomFeed{
+		Xmlns:       "http://www.w3.org/2005/Atom",
+		XmlnsDC:     "http://purl.org/dc/terms/",
+		XmlnsOPDS:   "http://opds-spec.org/2010/catalog",
+		XmlnsSearch: "http://a9.com/-/spec/opensearch/1.1/",
+		ID:          f.ID,
+		Title:       f.Title,
+		Updated:     atomTime(f.Updated),
+		Author:      atomAuthor{Name: "Noteshelf"},
+		Links: []atomLink{
+			{Rel: "self", Href: f.href(f.Path), Type: f.kind()},
+			{Rel: "start", Href: f.href(""), Type: NavigationType},
+			{Rel: "search", Href: f.href("/opensearch.xml"), Type: OpenSearchType},
+		},
+	}
+	if f.Acquisition {
+		feed.TotalResults, feed.ItemsPerPage = f.Total, f.PageSize
+	}
+	if f.Up != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "up", Href: f.href(f.Up), Type: NavigationType})
+	}
+	if f.Previous != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "previous", Href: f.href(f.Previous), Type: f.kind()})
+	}
+	if f.Next != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Href: f.href(f.Next), Type: f.kind()})
+	}
+
+	for _, n := range f.Navigation {
+		kind := NavigationType
+		if n.Acquisition {
+			kind = AcquisitionType
+		}
+		entry := atomEntry{
+			ID:      n.ID,
+			Title:   n.Title,
+			Updated: atomTime(n.Updated),
+			Links:   []atomLink{{Rel: "subsection", Href: f.href(n.Path), Type: kind}},
+		}
+		if n.Summary != "" {
+			entry.Content = &atomText{Type: "text", Text: n.Summary}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	for _, p := range f.Publications {
+		entry := atomEntry{
+			ID:       p.ID,
+			Title:    p.Title,
+			Updated:  atomTime(p.Updated),
+			Language: p.Language,
+		}
+		if p.Author != "" {
+			entry.Authors = []atomAuthor{{Name: p.Author}}
+		}
+		if p.ISBN != "" {
+			entry.Identifier = "urn:isbn:" + p.ISBN
+		}
+		if p.Description != "" {
+			entry.Summary = &atomText{Type: "text", Text: p.Description}
+		}
+		if p.Image != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: relImage, Href: p.Image, Type: "image/jpeg"})
+		}
+		if p.Thumbnail != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: relThumbnail, Href: p.Thumbnail, Type: "image/jpeg"})
+		}
+		if p.Acquisition != "" {
+			entry.Links = append(entry.Links, atomLink{Rel: relAcquisition, Href: p.Acquisition, Type: p.AcquisitionType})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+type openSearchDescription struct {
+	XMLName     xml.Name        `xml:"OpenSearchDescription"`
+	Xmlns       string          `xml:"xmlns,attr"`
+	ShortName   string          `xml:"ShortName"`
+	Description string          `xml:"Description"`
+	URLs        []openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// OpenSearch writes the description OPDS 1.2 clients read to find out how to
+// search the catalog at base.
+func OpenSearch(w io.Writer, base string) error {
+	description := openSearchDescription{
+		Xmlns:       "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:   "Noteshelf",
+		Description: "Search your library",
+		URLs: []openSearchURL{
+			{Type: AcquisitionType, Template: base + "/search?q={searchTerms}"},
+			{Type: "application/atom+xml", Template: base + "/search?q={searchTerms}"},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(description)
+}
@@ -0,0 +1,74 @@
+package opds
+
+import "time"
+
+type Version string
+
+const (
+	Version1 Version = "1.2"
+	Version2 Version = "2.0"
+)
+
+const (
+	NavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType  = "application/opensearchdescription+xml"
+	JSONType        = "application/opds+json"
+)
+
+// Feed is a catalog page independent of the OPDS version it is served in.
+// Hrefs of the feed itself and of its navigation entries are paths below
+// Base, so the same feed can be rendered for either version and either way of
+// authenticating.
+type Feed struct {
+	Base    string
+	ID      string
+	Title   string
+	Updated time.Time
+	Path    string
+	// Acquisition feeds list publications, navigation feeds only link to
+	// other feeds.
+	Acquisition  bool
+	Up           string
+	Navigation   []Navigation
+	Publications []Publication
+	Page         int
+	PageSize     int
+	Total        int
+	// Next and Previous are set when the feed is one page of a longer list.
+	Next     string
+	Previous string
+}
+
+// Navigation is an entry linking to another feed of the catalog.
+type Navigation struct {
+	ID          string
+	Title       string
+	Summary     string
+	Path        string
+	Acquisition bool
+	Updated     time.Time
+}
+
+// Publication is a book with the signed links to download it and its cover.
+type Publication struct {
+	ID          string
+	Title       string
+	Author      string
+	Language    string
+	ISBN        string
+	Description string
+	Updated     time.Time
+	// Acquisition is empty for books that only have metadata.
+	Acquisition     string
+	AcquisitionType string
+	Image           string
+	Thumbnail       string
+}
+
+func (f Feed) href(path string) string {
+	if path == "" {
+		return f.Base
+	}
+	return f.Base + path
+}
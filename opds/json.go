@@ -0,0 +1,102 @@
+package opds
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+type jsonLink struct {
+	Rel       string `json:"rel,omitempty"`
+	Href      string `json:"href"`
+	Type      string `json:"type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+type jsonPublication struct {
+	Metadata jsonPublicationMetadata `json:"metadata"`
+	Links    []jsonLink              `json:"links"`
+	Images   []jsonLink              `json:"images,omitempty"`
+}
+
+type jsonPublicationMetadata struct {
+	Type        string `json:"@type"`
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Author      string `json:"author,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Description string `json:"description,omitempty"`
+	Modified    string `json:"modified"`
+}
+
+// JSON writes the feed as an OPDS 2.0 document.
+func JSON(w io.Writer, f Feed) error {
+	feed := jsonFeed{
+		Metadata: jsonFeedMetadata{Title: f.Title, Modified: atomTime(f.Updated)},
+		Links: []jsonLink{
+			{Rel: "self", Href: f.href(f.Path), Type: JSONType},
+			{Rel: "start", Href: f.href(""), Type: JSONType},
+			{Rel: "search", Href: f.href("/search{?query}"), Type: JSONType, Templated: true},
+		},
+	}
+	if f.Acquisition {
+		feed.Metadata.NumberOfItems, feed.Metadata.ItemsPerPage, feed.Metadata.CurrentPage = f.Total, f.PageSize, f.Page
+	}
+	if f.Up != "" {
+		feed.Links = append(feed.Links, jsonLink{Rel: "up", Href: f.href(f.Up), Type: JSONType})
+	}
+	if f.Previous != "" {
+		feed.Links = append(feed.Links, jsonLink{Rel: "previous", Href: f.href(f.Previous), Type: JSONType})
+	}
+	if f.Next != "" {
+		feed.Links = append(feed.Links, jsonLink{Rel: "next", Href: f.href(f.Next), Type: JSONType})
+	}
+
+	for _, n := range f.Navigation {
+		feed.Navigation = append(feed.Navigation, jsonLink{Rel: "subsection", Href: f.href(n.Path), Type: JSONType, Title: n.Title})
+	}
+
+	for _, p := range f.Publications {
+		publication := jsonPublication{
+			Metadata: jsonPublicationMetadata{
+				Type:        "http://schema.org/Book",
+				Identifier:  p.ID,
+				Title:       p.Title,
+				Author:      p.Author,
+				Language:    p.Language,
+				Description: p.Description,
+				Modified:    atomTime(p.Updated),
+			},
+			Links: []jsonLink{},
+		}
+		if p.Acquisition != "" {
+			publication.Links = append(publication.Links, jsonLink{Rel: relAcquisition, Href: p.Acquisition, Type: p.AcquisitionType})
+		}
+		if p.Image != "" {
+			publication.Images = append(publication.Images, jsonLink{Href: p.Image, Type: "image/jpeg"})
+		}
+		if p.Thumbnail != "" {
+			publication.Images = append(publication.Images, jsonLink{Href: p.Thumbnail, Type: "image/jpeg"})
+		}
+		feed.Publications = append(feed.Publications, publication)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(feed)
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/bookmeta"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	statusWantToRead = "want_to_read"
+	statusReading    = "reading"
+	statusFinished   = "finished"
+	statusAbandoned  = "abandoned"
+)
+
+var readingStatuses = map[string]bool{
+	statusWantToRead: true,
+	statusReading:    true,
+	statusFinished:   true,
+	statusAbandoned:  true,
+}
+
+// legacyReadingStatuses maps the statuses the library filter accepted before
+// they were stored explicitly.
+var legacyReadingStatuses = map[string]string{
+	"not_started": statusWantToRead,
+	"in_progress": statusReading,
+}
+
+func percentageOf(page, totalPages int32) float64 {
+	if totalPages < 1 {
+		return 0
+	}
+	return min(float64(page)/float64(totalPages)*100, 100)
+}
+
+// Locator is a Readium-style position in a book. Reflowable formats have no
+// stable pages, so EPUB readers report where they are with it instead.
+type Locator struct {
+	Href      string           `json:"href" binding:"required"`
+	Type      string           `json:"type"`
+	Locations LocatorLocations `json:"locations"`
+}
+
+type LocatorLocations struct {
+	CFI              *string  `json:"cfi"`
+	Progression      *float64 `json:"progression" binding:"omitempty,min=0,max=1"`
+	TotalProgression *float64 `json:"totalProgression" binding:"omitempty,min=0,max=1"`
+}
+
+// unknownDeviceID stands in for the device of clients that don't identify
+// theirs.
+const unknownDeviceID = "unknown"
+
+// progressUpdate is a single position reported by one of a reader's devices.
+type progressUpdate struct {
+	DeviceID    string
+	DeviceName  *string
+	CurrentPage int32
+	Locator     *Locator
+	ReadAt      time.Time
+}
+
+// position resolves the page and percentage of an update. For EPUBs with a
+// locator the percentage comes from totalProgression, and the page is derived
+// from it when the client didn't send one.
+func (u progressUpdate) position(book repository.Book) (page int32, percentage float64) {
+	page = u.CurrentPage
+	percentage = percentageOf(page, book.TotalPages)
+
+	if u.Locator == nil || u.Locator.Locations.TotalProgression == nil {
+		return page, percentage
+	}
+
+	total := *u.Locator.Locations.TotalProgression
+	if page < 1 {
+		page = max(int32(math.Ceil(total*float64(book.TotalPages))), 1)
+	}
+	if book.Format != nil && bookmeta.Format(*book.Format) == bookmeta.FormatEPUB {
+		percentage = total * 100
+	}
+
+	return page, percentage
+}
+
+// advanceStatus moves the status of progress along for a read at readAt that
+// ends at percentage: reading starts the book, and reaching the end finishes
+// it. finishing reports whether a completed read should be recorded.
+func advanceStatus(progress repository.ReadingProgress, percentage float64, readAt time.Time) (status string, startedAt, finishedAt pgtype.Timestamp, finishing bool) {
+	status, startedAt, finishedAt = progress.Status, progress.StartedAt, progress.FinishedAt
+
+	if status == statusWantToRead || status == statusAbandoned {
+		status = statusReading
+		if !startedAt.Valid {
+			startedAt = pgtype.Timestamp{Time: readAt, Valid: true}
+		}
+	}
+
+	finishing = percentage >= 100 && status != statusFinished
+	if finishing {
+		status = statusFinished
+		finishedAt = pgtype.Timestamp{Time: readAt, Valid: true}
+	}
+
+	return status, startedAt, finishedAt, finishing
+}
+
+// locatorDerived reports whether the percentage of progress came from an EPUB
+// locator rather than from its page, see progressUpdate.position.
+func locatorDerived(book repository.Book, progress repository.ReadingProgress) bool {
+	return progress.LocatorTotalProgression != nil && book.Format != nil && bookmeta.Format(*book.Format) == bookmeta.FormatEPUB
+}
+
+// recomputeReadingProgress updates every reader's percentage after the page
+// count of book changed. Percentages taken from a locator don't depend on
+// pages and are kept. A book being read goes through advanceStatus again, so
+// a page that is now the last one finishes it; statuses a reader chose by
+// hand are left alone. q should be bound to a transaction.
+func recomputeReadingProgress(ctx context.Context, q *repository.Queries, book repository.Book) error {
+	rows, err := q.GetReadingProgressByBookID(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, progress := range rows {
+		if locatorDerived(book, progress) {
+			continue
+		}
+
+		percentage := percentageOf(progress.CurrentPage, book.TotalPages)
+		status, startedAt, finishedAt, finishing := progress.Status, progress.StartedAt, progress.FinishedAt, false
+		if progress.Status == statusReading && progress.LastReadAt.Valid {
+			status, startedAt, finishedAt, finishing = advanceStatus(progress, percentage, progress.LastReadAt.Time)
+		}
+
+		if err := q.SetReadingProgressPercentage(ctx, repository.SetReadingProgressPercentageParams{
+			PercentageComplete: percentage,
+			Status:             status,
+			StartedAt:          startedAt,
+			FinishedAt:         finishedAt,
+			BookID:             book.ID,
+			UserID:             progress.UserID,
+		}); err != nil {
+			return err
+		}
+
+		if finishing {
+			if _, err := q.CreateCompletedRead(ctx, repository.CreateCompletedReadParams{ID: uuid.New(), UserID: progress.UserID, BookID: book.ID, StartedAt: startedAt, FinishedAt: progress.LastReadAt.Time}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isOutOfOrder reports whether an update read at readAt is older than the
+// stored progress. A book that was never read, e.g. one just shared, has no
+// last_read_at yet, so its first update is always applied.
+func isOutOfOrder(progress repository.ReadingProgress, readAt time.Time) bool {
+	return progress.LastReadAt.Valid && readAt.Before(progress.LastReadAt.Time)
+}
+
+// saveReadingProgress moves userID to the position of update in book. Reading a
+// book starts it, and reaching the end finishes it and records a completed
+// read. Every call is also kept as a reading event; updates older than the
+// stored progress, e.g. from a device that was offline, only add the event and
+// never rewind the position. q should be bound to a transaction, which holds
+// the progress row locked until it ends, so updates from several devices at
+// once are applied one after the other.
+func saveReadingProgress(ctx context.Context, q *repository.Queries, book repository.Book, userID string, update progressUpdate) (repository.ReadingProgress, error) {
+	if err := q.EnsureReadingProgress(ctx, repository.EnsureReadingProgressParams{BookID: book.ID, UserID: userID}); err != nil {
+		return repository.ReadingProgress{}, err
+	}
+
+	progress, err := q.GetReadingProgressForUpdate(ctx, repository.GetReadingProgressForUpdateParams{BookID: book.ID, UserID: userID})
+	if err != nil {
+		return progress, err
+	}
+
+	readAt := update.ReadAt
+	currentPage, percentage := update.position(book)
+
+	position := repository.UpsertReadingPositionParams{
+		UserID:             userID,
+		BookID:             book.ID,
+		DeviceID:           update.DeviceID,
+		DeviceName:         update.DeviceName,
+		CurrentPage:        currentPage,
+		PercentageComplete: percentage,
+		ReadAt:             readAt,
+	}
+	if update.Locator != nil {
+		position.LocatorHref = &update.Locator.Href
+		position.LocatorCfi = update.Locator.Locations.CFI
+		position.LocatorProgression = update.Locator.Locations.Progression
+		position.LocatorTotalProgression = update.Locator.Locations.TotalProgression
+	}
+	if err := q.UpsertReadingPosition(ctx, position); err != nil {
+		return progress, err
+	}
+
+	if isOutOfOrder(progress, readAt) {
+		previousPage, err := q.GetPageReadBefore(ctx, repository.GetPageReadBeforeParams{UserID: userID, BookID: book.ID, ReadAt: readAt})
+		if err != nil {
+			if !strings.Contains(err.Error(), "no rows") {
+				return progress, err
+			}
+			previousPage = currentPage
+		}
+		_, err = recordReadingEvent(ctx, q, book.ID, userID, previousPage, currentPage, readAt)
+		return progress, err
+	}
+
+	// A book that was never read has no previous position to count from; its
+	// first update only sets one, rather than counting every page before it.
+	previousPage := progress.CurrentPage
+	if !progress.LastReadAt.Valid {
+		previousPage = currentPage
+	}
+	status, startedAt, finishedAt, finishing := advanceStatus(progress, percentage, readAt)
+
+	progress, err = q.UpdateReadingProgress(ctx, repository.UpdateReadingProgressParams{
+		CurrentPage:             currentPage,
+		PercentageComplete:      percentage,
+		ReadAt:                  readAt,
+		Status:                  status,
+		StartedAt:               startedAt,
+		FinishedAt:              finishedAt,
+		LocatorHref:             position.LocatorHref,
+		LocatorCfi:              position.LocatorCfi,
+		LocatorProgression:      position.LocatorProgression,
+		LocatorTotalProgression: position.LocatorTotalProgression,
+		BookID:                  book.ID,
+		UserID:                  userID,
+	})
+	if err != nil {
+		return progress, err
+	}
+
+	if finishing {
+		if _, err := q.CreateCompletedRead(ctx, repository.CreateCompletedReadParams{ID: uuid.New(), UserID: userID, BookID: book.ID, StartedAt: startedAt, FinishedAt: readAt}); err != nil {
+			return progress, err
+		}
+	}
+
+	if _, err := recordReadingEvent(ctx, q, book.ID, userID, previousPage, currentPage, readAt); err != nil {
+		return progress, err
+	}
+
+	return progress, nil
+}
+
+// positionConflictThreshold is how far apart, in percent of the book, two
+// devices must be before the reader is asked which position to keep.
+const positionConflictThreshold = 1.0
+
+const (
+	conflictMoreRecent = "more_recent"
+	conflictFurther    = "further"
+)
+
+// PositionConflict points a client at another device's position that it may
+// want to jump to.
+type PositionConflict struct {
+	Reason   string                     `json:"reason"`
+	Position repository.ReadingPosition `json:"position"`
+}
+
+type ReadingProgressResponse struct {
+	repository.ReadingProgress
+	Conflict *PositionConflict `json:"conflict,omitempty"`
+}
+
+// findPositionConflict compares update with the latest positions of the
+// reader's other devices. The policy is:
+//
+//   - the book's progress always follows the most recent read, so a device
+//     that was offline never rewinds it;
+//   - if another device read more recently at a different position, that
+//     position is offered ("more_recent");
+//   - otherwise, if another device got further into the book, the furthest
+//     position is offered ("further").
+//
+// Positions closer than positionConflictThreshold are not conflicts. q should
+// be bound to the transaction that saved update: the positions are read under
+// the progress row lock, so an update another device is saving at the same
+// time is either already visible or not yet started, and one of the two sees
+// the other.
+func findPositionConflict(ctx context.Context, q *repository.Queries, book repository.Book, userID string, update progressUpdate) (*PositionConflict, error) {
+	if _, err := q.GetReadingProgressForUpdate(ctx, repository.GetReadingProgressForUpdateParams{BookID: book.ID, UserID: userID}); err != nil {
+		return nil, err
+	}
+
+	positions, err := q.GetReadingPositions(ctx, repository.GetReadingPositionsParams{UserID: userID, BookID: book.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	_, percentage := update.position(book)
+
+	var further *PositionConflict
+	for _, position := range positions {
+		if position.DeviceID == update.DeviceID || math.Abs(position.PercentageComplete-percentage) < positionConflictThreshold {
+			continue
+		}
+
+		// Positions are sorted by read_at, most recent first.
+		if position.ReadAt.After(update.ReadAt) {
+			return &PositionConflict{Reason: conflictMoreRecent, Position: position}, nil
+		}
+
+		if position.PercentageComplete > percentage && (further == nil || position.PercentageComplete > further.Position.PercentageComplete) {
+			further = &PositionConflict{Reason: conflictFurther, Position: position}
+		}
+	}
+
+	return further, nil
+}
+
+func listReadingPositionsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	positions, err := cfg.Queries.GetReadingPositions(c, repository.GetReadingPositionsParams{UserID: dbUser.ID, BookID: book.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, positions)
+}
+
+// setReadingStatus applies a status chosen by the user. Moving a finished book
+// back to reading starts a re-read; the earlier read stays in completed_reads.
+func setReadingStatus(ctx context.Context, q *repository.Queries, book repository.Book, userID string, status string) (repository.ReadingProgress, error) {
+	if err := q.EnsureReadingProgress(ctx, repository.EnsureReadingProgressParams{BookID: book.ID, UserID: userID}); err != nil {
+		return repository.ReadingProgress{}, err
+	}
+
+	progress, err := q.GetReadingProgressForUpdate(ctx, repository.GetReadingProgressForUpdateParams{BookID: book.ID, UserID: userID})
+	if err != nil {
+		return progress, err
+	}
+
+	if progress.Status == status {
+		return progress, nil
+	}
+
+	now := pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+	startedAt, finishedAt := progress.StartedAt, progress.FinishedAt
+
+	switch status {
+	case statusWantToRead:
+		startedAt, finishedAt = pgtype.Timestamp{}, pgtype.Timestamp{}
+	case statusReading:
+		if progress.Status == statusFinished {
+			startedAt, finishedAt = now, pgtype.Timestamp{}
+		} else if !startedAt.Valid {
+			startedAt = now
+		}
+	case statusFinished:
+		finishedAt = now
+	}
+
+	progress, err = q.SetReadingStatus(ctx, repository.SetReadingStatusParams{Status: status, StartedAt: startedAt, FinishedAt: finishedAt, BookID: book.ID, UserID: userID})
+	if err != nil {
+		return progress, err
+	}
+
+	if status == statusFinished {
+		if _, err := q.CreateCompletedRead(ctx, repository.CreateCompletedReadParams{ID: uuid.New(), UserID: userID, BookID: book.ID, StartedAt: startedAt, FinishedAt: now.Time}); err != nil {
+			return progress, err
+		}
+	}
+
+	return progress, nil
+}
+
+type UpdateReadingStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+func updateReadingStatusHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req UpdateReadingStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !readingStatuses[req.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported status " + req.Status})
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	readingProgress, err := setReadingStatus(c, repository.New(tx), book, dbUser.ID, req.Status)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, readingProgress)
+}
+
+func listCompletedReadsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	reads, err := cfg.Queries.GetCompletedReads(c, repository.GetCompletedReadsParams{BookID: book.ID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reads)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestIsOutOfOrder(t *testing.T) {
+	lastRead := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		lastReadAt pgtype.Timestamp
+		readAt     time.Time
+		want       bool
+	}{
+		{
+			name:   "first update on a newly shared book",
+			readAt: lastRead.Add(-time.Hour),
+		},
+		{
+			name:       "newer than the stored progress",
+			lastReadAt: pgtype.Timestamp{Time: lastRead, Valid: true},
+			readAt:     lastRead.Add(time.Minute),
+		},
+		{
+			name:       "same time as the stored progress",
+			lastReadAt: pgtype.Timestamp{Time: lastRead, Valid: true},
+			readAt:     lastRead,
+		},
+		{
+			name:       "older than the stored progress",
+			lastReadAt: pgtype.Timestamp{Time: lastRead, Valid: true},
+			readAt:     lastRead.Add(-time.Minute),
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := repository.ReadingProgress{LastReadAt: tt.lastReadAt}
+			if got := isOutOfOrder(progress, tt.readAt); got != tt.want {
+				t.Errorf("isOutOfOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvanceStatus(t *testing.T) {
+	readAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := pgtype.Timestamp{Time: readAt.Add(-24 * time.Hour), Valid: true}
+	now := pgtype.Timestamp{Time: readAt, Valid: true}
+
+	tests := []struct {
+		name          string
+		progress      repository.ReadingProgress
+		percentage    float64
+		wantStatus    string
+		wantStarted   pgtype.Timestamp
+		wantFinished  pgtype.Timestamp
+		wantFinishing bool
+	}{
+		{
+			name:        "first read starts the book",
+			progress:    repository.ReadingProgress{Status: statusWantToRead},
+			percentage:  10,
+			wantStatus:  statusReading,
+			wantStarted: now,
+		},
+		{
+			name:        "reading again an abandoned book keeps its start",
+			progress:    repository.ReadingProgress{Status: statusAbandoned, StartedAt: earlier},
+			percentage:  10,
+			wantStatus:  statusReading,
+			wantStarted: earlier,
+		},
+		{
+			name:          "reaching the end finishes the book",
+			progress:      repository.ReadingProgress{Status: statusReading, StartedAt: earlier},
+			percentage:    100,
+			wantStatus:    statusFinished,
+			wantStarted:   earlier,
+			wantFinished:  now,
+			wantFinishing: true,
+		},
+		{
+			name:         "a finished book is only finished once",
+			progress:     repository.ReadingProgress{Status: statusFinished, StartedAt: earlier, FinishedAt: earlier},
+			percentage:   100,
+			wantStatus:   statusFinished,
+			wantStarted:  earlier,
+			wantFinished: earlier,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, startedAt, finishedAt, finishing := advanceStatus(tt.progress, tt.percentage, readAt)
+			if status != tt.wantStatus || startedAt != tt.wantStarted || finishedAt != tt.wantFinished || finishing != tt.wantFinishing {
+				t.Errorf("advanceStatus() = %s, %v, %v, %v, want %s, %v, %v, %v",
+					status, startedAt, finishedAt, finishing, tt.wantStatus, tt.wantStarted, tt.wantFinished, tt.wantFinishing)
+			}
+		})
+	}
+}
+
+func TestLocatorDerived(t *testing.T) {
+	epub, pdf := "epub", "pdf"
+	total := 0.4
+
+	tests := []struct {
+		name     string
+		book     repository.Book
+		progress repository.ReadingProgress
+		want     bool
+	}{
+		{name: "epub with locator", book: repository.Book{Format: &epub}, progress: repository.ReadingProgress{LocatorTotalProgression: &total}, want: true},
+		{name: "epub without locator", book: repository.Book{Format: &epub}},
+		{name: "pdf with locator", book: repository.Book{Format: &pdf}, progress: repository.ReadingProgress{LocatorTotalProgression: &total}},
+		{name: "book without a file", progress: repository.ReadingProgress{LocatorTotalProgression: &total}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := locatorDerived(tt.book, tt.progress); got != tt.want {
+				t.Errorf("locatorDerived() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+-- name: GetAPITokensByUserID :many
+SELECT * FROM api_tokens
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY created_at;
+
+-- name: GetAPITokenByHash :one
+SELECT * FROM api_tokens
+WHERE token_hash = sqlc.arg(token_hash);
+
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (id, user_id, name, token_prefix, token_hash, scopes)
+VALUES (sqlc.arg(id), sqlc.arg(user_id), sqlc.arg(name), sqlc.arg(token_prefix), sqlc.arg(token_hash), sqlc.arg(scopes))
+RETURNING *;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = NOW()
+WHERE id = sqlc.arg(id) AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
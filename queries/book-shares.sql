@@ -0,0 +1,19 @@
+-- name: GetBookSharesByBookID :many
+SELECT sqlc.embed(book_shares), users.email, users.first_name, users.last_name
+FROM book_shares
+JOIN users ON users.id = book_shares.user_id
+WHERE book_shares.book_id = sqlc.arg(book_id)
+ORDER BY book_shares.created_at;
+
+-- name: GetBookShare :one
+SELECT * FROM book_shares
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
+
+-- name: CreateBookShare :one
+INSERT INTO book_shares (book_id, user_id, shared_by)
+VALUES (sqlc.arg(book_id), sqlc.arg(user_id), sqlc.arg(shared_by))
+RETURNING *;
+
+-- name: DeleteBookShare :execrows
+DELETE FROM book_shares
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
@@ -0,0 +1,36 @@
+-- name: GetBookmarksByBookID :many
+SELECT * FROM bookmarks
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+ORDER BY page_number NULLS LAST, locator_total_progression NULLS LAST, created_at;
+
+-- name: GetBookmarksByIDs :many
+SELECT * FROM bookmarks
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: CreateBookmark :one
+INSERT INTO bookmarks (id, book_id, user_id, page_number, locator_href, locator_cfi, locator_progression, locator_total_progression, label)
+VALUES (sqlc.arg(id), sqlc.arg(book_id), sqlc.arg(user_id), sqlc.narg(page_number), sqlc.narg(locator_href), sqlc.narg(locator_cfi),
+  sqlc.narg(locator_progression), sqlc.narg(locator_total_progression), sqlc.arg(label))
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE id = sqlc.arg(id) AND book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
+
+-- name: CreateImportedBookmark :one
+INSERT INTO bookmarks (id, book_id, user_id, page_number, location, label, created_at)
+VALUES (sqlc.arg(id), sqlc.arg(book_id), sqlc.arg(user_id), sqlc.narg(page_number), sqlc.narg(location), sqlc.arg(label), sqlc.arg(created_at))
+RETURNING *;
+
+-- name: BookmarkExists :one
+SELECT EXISTS (
+  SELECT 1 FROM bookmarks
+  WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+    AND page_number IS NOT DISTINCT FROM sqlc.narg(page_number)::int
+    AND location IS NOT DISTINCT FROM sqlc.narg(location)::int
+)::bool AS found;
+
+-- name: GetBookmarksByUserID :many
+SELECT * FROM bookmarks
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY book_id, page_number NULLS LAST, locator_total_progression NULLS LAST, created_at;
@@ -1,17 +1,334 @@
 -- name: GetBooksByOwnerID :many
-SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete 
+SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at
 FROM books 
-LEFT JOIN reading_progress on reading_progress.book_id = books.id
-WHERE owner_id = sqlc.arg(owner_id);
+LEFT JOIN reading_progress on reading_progress.book_id = books.id AND reading_progress.user_id = books.owner_id
+WHERE owner_id = sqlc.arg(owner_id)
+ORDER BY lower(books.title), books.id;
 
 -- name: GetBookByID :one
 SELECT * FROM books WHERE id = sqlc.arg(id);
 
+-- name: GetReadableBooksByIDs :many
+SELECT * FROM books
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+  AND (owner_id = sqlc.arg(user_id)
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)));
+
 -- name: CreateBook :one
-INSERT INTO books (id, title, author, owner_id, s3_key, total_pages)
-VALUES (sqlc.arg(id), sqlc.arg(title), sqlc.arg(author), sqlc.arg(owner_id), sqlc.arg(s3_key), sqlc.arg(total_pages))
+INSERT INTO books (id, title, author, owner_id, s3_key, total_pages, language, format)
+VALUES (sqlc.arg(id), sqlc.arg(title), sqlc.arg(author), sqlc.arg(owner_id), sqlc.arg(s3_key), sqlc.arg(total_pages), sqlc.arg(language), sqlc.arg(format))
+RETURNING *;
+
+-- name: UpdateBook :one
+-- A null argument keeps the column; an empty author, description or isbn clears it.
+UPDATE books
+SET title = COALESCE(sqlc.narg(title)::text, title),
+    author = NULLIF(COALESCE(sqlc.narg(author)::text, author), ''),
+    total_pages = COALESCE(sqlc.narg(total_pages)::int, total_pages),
+    description = NULLIF(COALESCE(sqlc.narg(description)::text, description), ''),
+    language = COALESCE(sqlc.narg(language)::text, language),
+    isbn = NULLIF(COALESCE(sqlc.narg(isbn)::text, isbn), '')
+WHERE id = sqlc.arg(id)
 RETURNING *;
 
+-- name: SetBookCovers :exec
+UPDATE books
+SET cover_small_key = sqlc.arg(cover_small_key), cover_medium_key = sqlc.arg(cover_medium_key), cover_large_key = sqlc.arg(cover_large_key)
+WHERE id = sqlc.arg(id);
+
 -- name: DeleteBook :exec
 DELETE FROM books where id=sqlc.arg(id);
 
+
+-- name: GetLibraryPageByAddedAt :many
+WITH page AS (
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE books.owner_id = sqlc.arg(user_id) AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (books.added_at, books.id) > (sqlc.narg(cursor_key)::text::timestamp, sqlc.narg(cursor_id)::uuid))
+  ORDER BY books.added_at, books.id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE books.owner_id = sqlc.arg(user_id) AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (books.added_at, books.id) < (sqlc.narg(cursor_key)::text::timestamp, sqlc.narg(cursor_id)::uuid))
+  ORDER BY books.added_at DESC, books.id DESC
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE book_shares.user_id = sqlc.arg(user_id) AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (books.added_at, books.id) > (sqlc.narg(cursor_key)::text::timestamp, sqlc.narg(cursor_id)::uuid))
+  ORDER BY books.added_at, books.id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE book_shares.user_id = sqlc.arg(user_id) AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (books.added_at, books.id) < (sqlc.narg(cursor_key)::text::timestamp, sqlc.narg(cursor_id)::uuid))
+  ORDER BY books.added_at DESC, books.id DESC
+  LIMIT sqlc.arg(page_size))
+)
+SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (books.added_at)::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+ORDER BY
+  CASE WHEN sqlc.arg(descending)::boolean THEN books.added_at END DESC,
+  CASE WHEN sqlc.arg(descending)::boolean THEN books.id END DESC,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN books.added_at END,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN books.id END
+LIMIT sqlc.arg(page_size);
+
+-- name: GetLibraryPageByAuthor :many
+WITH page AS (
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE books.owner_id = sqlc.arg(user_id) AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) > (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(COALESCE(books.author, '')), books.id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE books.owner_id = sqlc.arg(user_id) AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) < (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(COALESCE(books.author, '')) DESC, books.id DESC
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE book_shares.user_id = sqlc.arg(user_id) AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) > (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(COALESCE(books.author, '')), books.id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE book_shares.user_id = sqlc.arg(user_id) AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) < (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(COALESCE(books.author, '')) DESC, books.id DESC
+  LIMIT sqlc.arg(page_size))
+)
+SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (lower(COALESCE(books.author, '')))::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+ORDER BY
+  CASE WHEN sqlc.arg(descending)::boolean THEN lower(COALESCE(books.author, '')) END DESC,
+  CASE WHEN sqlc.arg(descending)::boolean THEN books.id END DESC,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN lower(COALESCE(books.author, '')) END,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN books.id END
+LIMIT sqlc.arg(page_size);
+
+-- name: GetLibraryPageByLastReadAt :many
+WITH page AS (
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = sqlc.arg(user_id)
+    AND (books.owner_id = sqlc.arg(user_id)
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+    AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (COALESCE(reading_progress.last_read_at, 'epoch'::timestamp), reading_progress.book_id) > (sqlc.narg(cursor_key)::text::timestamp, sqlc.narg(cursor_id)::uuid))
+  ORDER BY COALESCE(reading_progress.last_read_at, 'epoch'::timestamp), reading_progress.book_id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = sqlc.arg(user_id)
+    AND (books.owner_id = sqlc.arg(user_id)
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+    AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (COALESCE(reading_progress.last_read_at, 'epoch'::timestamp), reading_progress.book_id) < (sqlc.narg(cursor_key)::text::timestamp, sqlc.narg(cursor_id)::uuid))
+  ORDER BY COALESCE(reading_progress.last_read_at, 'epoch'::timestamp) DESC, reading_progress.book_id DESC
+  LIMIT sqlc.arg(page_size))
+)
+SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (COALESCE(reading_progress.last_read_at, 'epoch'::timestamp))::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+ORDER BY
+  CASE WHEN sqlc.arg(descending)::boolean THEN COALESCE(reading_progress.last_read_at, 'epoch'::timestamp) END DESC,
+  CASE WHEN sqlc.arg(descending)::boolean THEN books.id END DESC,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN COALESCE(reading_progress.last_read_at, 'epoch'::timestamp) END,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN books.id END
+LIMIT sqlc.arg(page_size);
+
+-- name: GetLibraryPageByPercentageComplete :many
+WITH page AS (
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = sqlc.arg(user_id)
+    AND (books.owner_id = sqlc.arg(user_id)
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+    AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (reading_progress.percentage_complete, reading_progress.book_id) > (sqlc.narg(cursor_key)::text::numeric, sqlc.narg(cursor_id)::uuid))
+  ORDER BY reading_progress.percentage_complete, reading_progress.book_id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = sqlc.arg(user_id)
+    AND (books.owner_id = sqlc.arg(user_id)
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+    AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (reading_progress.percentage_complete, reading_progress.book_id) < (sqlc.narg(cursor_key)::text::numeric, sqlc.narg(cursor_id)::uuid))
+  ORDER BY reading_progress.percentage_complete DESC, reading_progress.book_id DESC
+  LIMIT sqlc.arg(page_size))
+)
+SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (reading_progress.percentage_complete)::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+ORDER BY
+  CASE WHEN sqlc.arg(descending)::boolean THEN reading_progress.percentage_complete END DESC,
+  CASE WHEN sqlc.arg(descending)::boolean THEN books.id END DESC,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN reading_progress.percentage_complete END,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN books.id END
+LIMIT sqlc.arg(page_size);
+
+-- name: GetLibraryPageByTitle :many
+WITH page AS (
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE books.owner_id = sqlc.arg(user_id) AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(books.title), books.id) > (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(books.title), books.id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE books.owner_id = sqlc.arg(user_id) AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(books.title), books.id) < (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(books.title) DESC, books.id DESC
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE book_shares.user_id = sqlc.arg(user_id) AND NOT sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(books.title), books.id) > (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(books.title), books.id
+  LIMIT sqlc.arg(page_size))
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+  WHERE book_shares.user_id = sqlc.arg(user_id) AND sqlc.arg(descending)::boolean
+    AND (sqlc.narg(author)::text IS NULL OR books.author ILIKE '%' || sqlc.narg(author)::text || '%' ESCAPE '\')
+    AND (sqlc.narg(status)::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = sqlc.narg(status)::text)
+    AND (sqlc.narg(shelf_id)::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = sqlc.narg(shelf_id)::uuid AND shelf_books.book_id = books.id))
+    AND (sqlc.narg(cursor_key)::text IS NULL OR (lower(books.title), books.id) < (sqlc.narg(cursor_key)::text, sqlc.narg(cursor_id)::uuid))
+  ORDER BY lower(books.title) DESC, books.id DESC
+  LIMIT sqlc.arg(page_size))
+)
+SELECT sqlc.embed(books), reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (lower(books.title))::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = sqlc.arg(user_id)
+ORDER BY
+  CASE WHEN sqlc.arg(descending)::boolean THEN lower(books.title) END DESC,
+  CASE WHEN sqlc.arg(descending)::boolean THEN books.id END DESC,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN lower(books.title) END,
+  CASE WHEN NOT sqlc.arg(descending)::boolean THEN books.id END
+LIMIT sqlc.arg(page_size);
+
+-- name: GetReadableBooksByUserID :many
+SELECT * FROM books
+WHERE owner_id = sqlc.arg(user_id)
+  OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id))
+ORDER BY added_at;
+
+-- name: GetReadableBookByKOReaderHash :one
+SELECT * FROM books
+WHERE koreader_hash = sqlc.arg(koreader_hash)
+  AND (owner_id = sqlc.arg(user_id)
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+ORDER BY added_at
+LIMIT 1;
+
+-- name: GetReadableBooksWithoutKOReaderHash :many
+SELECT * FROM books
+WHERE koreader_hash IS NULL AND s3_key IS NOT NULL
+  AND (owner_id = sqlc.arg(user_id)
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)));
+
+-- name: SetBookKOReaderHash :exec
+UPDATE books SET koreader_hash = sqlc.arg(koreader_hash)
+WHERE id = sqlc.arg(id);
+
+-- name: SetBookMetadata :one
+UPDATE books
+SET title = COALESCE(sqlc.narg(title)::text, title),
+    author = NULLIF(COALESCE(sqlc.narg(author)::text, author), ''),
+    total_pages = COALESCE(sqlc.narg(total_pages)::int, total_pages),
+    language = COALESCE(sqlc.narg(language)::text, language),
+    format = COALESCE(sqlc.narg(format)::text, format)
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,12 @@
+-- name: GetChangesSince :many
+SELECT * FROM change_log
+WHERE user_id = sqlc.arg(user_id) AND id > sqlc.arg(since)::bigint
+ORDER BY id
+LIMIT sqlc.arg(max_changes);
+
+-- name: GetReadingProgressChangesSince :many
+SELECT * FROM change_log
+WHERE user_id = sqlc.arg(user_id) AND id > sqlc.arg(since)::bigint AND entity = 'reading_progress'
+ORDER BY id
+LIMIT sqlc.arg(max_changes);
+
@@ -0,0 +1,57 @@
+-- name: GetHighlightsByBookID :many
+SELECT * FROM highlights
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+ORDER BY page_number NULLS LAST, created_at;
+
+-- name: GetHighlightByID :one
+SELECT * FROM highlights
+WHERE id = sqlc.arg(id) AND book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
+
+-- name: CreateHighlight :one
+INSERT INTO highlights (id, book_id, user_id, page_number, cfi_range, selected_text, color, note)
+VALUES (sqlc.arg(id), sqlc.arg(book_id), sqlc.arg(user_id), sqlc.arg(page_number), sqlc.arg(cfi_range), sqlc.arg(selected_text), sqlc.arg(color), sqlc.arg(note))
+RETURNING *;
+
+-- name: UpdateHighlight :one
+UPDATE highlights
+SET page_number = COALESCE(sqlc.narg(page_number)::int, page_number),
+    cfi_range = CASE WHEN sqlc.arg(clear_cfi_range)::boolean THEN NULL ELSE COALESCE(sqlc.narg(cfi_range)::text, cfi_range) END,
+    selected_text = COALESCE(sqlc.narg(selected_text)::text, selected_text),
+    color = COALESCE(sqlc.narg(color)::text, color),
+    note = CASE WHEN sqlc.arg(clear_note)::boolean THEN NULL ELSE COALESCE(sqlc.narg(note)::text, note) END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id) AND book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: DeleteHighlight :execrows
+DELETE FROM highlights
+WHERE id = sqlc.arg(id) AND book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetHighlightsByIDs :many
+SELECT * FROM highlights
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetHighlightsByUserID :many
+SELECT * FROM highlights
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY book_id, page_number NULLS LAST, created_at;
+
+-- name: CreateImportedHighlight :one
+INSERT INTO highlights (id, book_id, user_id, page_number, location_start, location_end, selected_text, color, note, created_at, updated_at)
+VALUES (sqlc.arg(id), sqlc.arg(book_id), sqlc.arg(user_id), sqlc.narg(page_number), sqlc.narg(location_start), sqlc.narg(location_end),
+  sqlc.arg(selected_text), sqlc.arg(color), sqlc.narg(note), sqlc.arg(created_at), sqlc.arg(created_at))
+RETURNING *;
+
+-- name: HighlightExists :one
+SELECT EXISTS (
+  SELECT 1 FROM highlights
+  WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id) AND selected_text = sqlc.arg(selected_text)
+    AND location_start IS NOT DISTINCT FROM sqlc.narg(location_start)::int
+)::bool AS found;
+
+-- name: GetHighlightAtLocation :one
+SELECT * FROM highlights
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+  AND location_start <= sqlc.arg(location)::int AND location_end >= sqlc.arg(location)::int
+ORDER BY location_end - location_start, created_at DESC
+LIMIT 1;
@@ -0,0 +1,30 @@
+-- name: GetKosyncAccountByUsername :one
+SELECT * FROM kosync_accounts
+WHERE username = sqlc.arg(username);
+
+-- name: GetKosyncAccountByUserID :one
+SELECT * FROM kosync_accounts
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: UpsertKosyncAccount :one
+INSERT INTO kosync_accounts (user_id, username, key_hash)
+VALUES (sqlc.arg(user_id), sqlc.arg(username), sqlc.arg(key_hash))
+ON CONFLICT (user_id) DO UPDATE
+SET username = EXCLUDED.username, key_hash = EXCLUDED.key_hash, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteKosyncAccount :execrows
+DELETE FROM kosync_accounts
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: GetKosyncProgress :one
+SELECT * FROM kosync_progress
+WHERE user_id = sqlc.arg(user_id) AND document = sqlc.arg(document);
+
+-- name: UpsertKosyncProgress :one
+INSERT INTO kosync_progress (user_id, document, progress, percentage, device, device_id, updated_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(document), sqlc.arg(progress), sqlc.arg(percentage), sqlc.arg(device), sqlc.arg(device_id), sqlc.arg(updated_at))
+ON CONFLICT (user_id, document) DO UPDATE
+SET progress = EXCLUDED.progress, percentage = EXCLUDED.percentage, device = EXCLUDED.device,
+    device_id = EXCLUDED.device_id, updated_at = EXCLUDED.updated_at
+RETURNING *;
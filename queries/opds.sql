@@ -0,0 +1,19 @@
+-- name: GetOPDSFeedTokenByUserID :one
+SELECT * FROM opds_feed_tokens
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: GetUserByOPDSFeedToken :one
+SELECT users.* FROM users
+JOIN opds_feed_tokens ON opds_feed_tokens.user_id = users.id
+WHERE opds_feed_tokens.token_hash = sqlc.arg(token_hash);
+
+-- name: UpsertOPDSFeedToken :one
+INSERT INTO opds_feed_tokens (user_id, token_hash)
+VALUES (sqlc.arg(user_id), sqlc.arg(token_hash))
+ON CONFLICT (user_id) DO UPDATE
+SET token_hash = EXCLUDED.token_hash, created_at = NOW()
+RETURNING *;
+
+-- name: DeleteOPDSFeedToken :execrows
+DELETE FROM opds_feed_tokens
+WHERE user_id = sqlc.arg(user_id);
@@ -0,0 +1,36 @@
+-- name: GetReadingGoalsByUserID :many
+SELECT * FROM reading_goals
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY kind, year;
+
+-- name: UpsertReadingGoal :one
+INSERT INTO reading_goals (id, user_id, kind, target, year)
+VALUES (sqlc.arg(id), sqlc.arg(user_id), sqlc.arg(kind), sqlc.arg(target), sqlc.arg(year))
+ON CONFLICT (user_id, kind, (COALESCE(year, 0))) DO UPDATE
+SET target = EXCLUDED.target, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteReadingGoal :execrows
+DELETE FROM reading_goals
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetReadingDays :many
+SELECT DISTINCT to_char(read_at AT TIME ZONE 'UTC' AT TIME ZONE sqlc.arg(time_zone)::text, 'YYYY-MM-DD')::text AS day
+FROM reading_events
+WHERE user_id = sqlc.arg(user_id) AND to_page <> from_page
+ORDER BY day;
+
+-- name: GetPagesReadBetween :one
+SELECT COALESCE(SUM(GREATEST(to_page - from_page, 0)), 0)::int AS pages
+FROM reading_events
+WHERE user_id = sqlc.arg(user_id) AND read_at >= sqlc.arg(from_time) AND read_at < sqlc.arg(to_time);
+
+-- name: GetSecondsReadBetween :one
+SELECT COALESCE(SUM(EXTRACT(EPOCH FROM ended_at - started_at)), 0)::int AS seconds
+FROM reading_sessions
+WHERE user_id = sqlc.arg(user_id) AND started_at >= sqlc.arg(from_time) AND started_at < sqlc.arg(to_time);
+
+-- name: CountBooksFinishedBetween :one
+SELECT COUNT(DISTINCT book_id)::int AS books
+FROM completed_reads
+WHERE user_id = sqlc.arg(user_id) AND finished_at >= sqlc.arg(from_time) AND finished_at < sqlc.arg(to_time);
@@ -0,0 +1,21 @@
+-- name: GetReadingPositions :many
+SELECT * FROM reading_positions
+WHERE user_id = sqlc.arg(user_id) AND book_id = sqlc.arg(book_id)
+ORDER BY read_at DESC;
+
+-- name: UpsertReadingPosition :exec
+INSERT INTO reading_positions (user_id, book_id, device_id, device_name, current_page, percentage_complete,
+  locator_href, locator_cfi, locator_progression, locator_total_progression, read_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(book_id), sqlc.arg(device_id), sqlc.arg(device_name), sqlc.arg(current_page), sqlc.arg(percentage_complete),
+  sqlc.arg(locator_href), sqlc.arg(locator_cfi), sqlc.arg(locator_progression), sqlc.arg(locator_total_progression), sqlc.arg(read_at))
+ON CONFLICT (user_id, book_id, device_id) DO UPDATE
+SET device_name = COALESCE(EXCLUDED.device_name, reading_positions.device_name),
+    current_page = EXCLUDED.current_page,
+    percentage_complete = EXCLUDED.percentage_complete,
+    locator_href = EXCLUDED.locator_href,
+    locator_cfi = EXCLUDED.locator_cfi,
+    locator_progression = EXCLUDED.locator_progression,
+    locator_total_progression = EXCLUDED.locator_total_progression,
+    read_at = EXCLUDED.read_at,
+    updated_at = NOW()
+WHERE reading_positions.read_at <= EXCLUDED.read_at;
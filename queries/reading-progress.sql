@@ -1,10 +1,33 @@
+-- name: GetReadingProgress :one
+SELECT * FROM reading_progress
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetReadingProgressForUpdate :one
+SELECT * FROM reading_progress
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+FOR UPDATE;
+
 -- name: UpdateReadingProgress :one
 UPDATE reading_progress 
-SET current_page=sqlc.arg(current_page), percentage_complete=sqlc.arg(percentage_complete) 
+SET current_page=sqlc.arg(current_page), percentage_complete=sqlc.arg(percentage_complete), last_read_at=GREATEST(last_read_at, sqlc.arg(read_at)::timestamp),
+    status=sqlc.arg(status), started_at=sqlc.narg(started_at), finished_at=sqlc.narg(finished_at),
+    locator_href=sqlc.narg(locator_href), locator_cfi=sqlc.narg(locator_cfi),
+    locator_progression=sqlc.narg(locator_progression), locator_total_progression=sqlc.narg(locator_total_progression),
+    updated_at=NOW()
 WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
 RETURNING *;
 
 
+-- name: GetReadingProgressByBookID :many
+SELECT * FROM reading_progress
+WHERE book_id = sqlc.arg(book_id);
+
+-- name: SetReadingProgressPercentage :exec
+UPDATE reading_progress
+SET percentage_complete = sqlc.arg(percentage_complete), status = sqlc.arg(status),
+    started_at = sqlc.narg(started_at), finished_at = sqlc.narg(finished_at), updated_at = NOW()
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
+
 -- name: DeteleReadingProgress :exec
 DELETE FROM reading_progress WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id);
 
@@ -13,3 +36,31 @@ DELETE FROM reading_progress WHERE book_id = sqlc.arg(book_id) AND user_id = sql
 INSERT INTO reading_progress (book_id, user_id) VALUES (sqlc.arg(book_id), sqlc.arg(user_id))
 RETURNING *;
 
+
+-- name: EnsureReadingProgress :exec
+INSERT INTO reading_progress (book_id, user_id) VALUES (sqlc.arg(book_id), sqlc.arg(user_id))
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteReadingProgressByBookID :exec
+DELETE FROM reading_progress WHERE book_id = sqlc.arg(book_id);
+
+-- name: GetCompletedReads :many
+SELECT * FROM completed_reads
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+ORDER BY finished_at;
+
+-- name: CreateCompletedRead :one
+INSERT INTO completed_reads (id, user_id, book_id, started_at, finished_at)
+VALUES (sqlc.arg(id), sqlc.arg(user_id), sqlc.arg(book_id), sqlc.arg(started_at), sqlc.arg(finished_at))
+RETURNING *;
+
+-- name: SetReadingStatus :one
+UPDATE reading_progress
+SET status = sqlc.arg(status), started_at = sqlc.narg(started_at), finished_at = sqlc.narg(finished_at), updated_at = NOW()
+WHERE book_id = sqlc.arg(book_id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+
+-- name: GetReadingProgressByBookIDs :many
+SELECT * FROM reading_progress
+WHERE user_id = sqlc.arg(user_id) AND book_id = ANY(sqlc.arg(book_ids)::uuid[]);
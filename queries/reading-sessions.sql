@@ -0,0 +1,53 @@
+-- name: GetOpenReadingSession :one
+SELECT * FROM reading_sessions
+WHERE user_id = sqlc.arg(user_id) AND book_id = sqlc.arg(book_id)
+  AND ended_at >= sqlc.arg(window_start) AND started_at <= sqlc.arg(window_end)
+ORDER BY ended_at DESC
+LIMIT 1;
+
+-- name: CreateReadingSession :one
+INSERT INTO reading_sessions (id, user_id, book_id, started_at, ended_at, start_page, end_page, pages_read)
+VALUES (sqlc.arg(id), sqlc.arg(user_id), sqlc.arg(book_id), sqlc.arg(read_at), sqlc.arg(read_at), sqlc.arg(start_page), sqlc.arg(end_page), sqlc.arg(pages_read))
+RETURNING *;
+
+-- name: ExtendReadingSession :one
+UPDATE reading_sessions
+SET started_at = LEAST(started_at, sqlc.arg(read_at)::timestamp),
+    ended_at = GREATEST(ended_at, sqlc.arg(read_at)::timestamp),
+    end_page = CASE WHEN sqlc.arg(read_at)::timestamp >= ended_at THEN sqlc.arg(end_page)::int ELSE end_page END,
+    pages_read = pages_read + sqlc.arg(pages_read)::int
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateReadingEvent :one
+INSERT INTO reading_events (id, session_id, user_id, book_id, from_page, to_page, read_at)
+VALUES (sqlc.arg(id), sqlc.arg(session_id), sqlc.arg(user_id), sqlc.arg(book_id), sqlc.arg(from_page), sqlc.arg(to_page), sqlc.arg(read_at))
+RETURNING *;
+
+-- name: GetPagesReadPerDay :many
+SELECT to_char(read_at AT TIME ZONE 'UTC' AT TIME ZONE sqlc.arg(time_zone)::text, 'YYYY-MM-DD')::text AS day, SUM(GREATEST(to_page - from_page, 0))::int AS pages
+FROM reading_events
+WHERE user_id = sqlc.arg(user_id) AND read_at >= sqlc.arg(from_time) AND read_at < sqlc.arg(to_time)
+GROUP BY day
+ORDER BY day;
+
+-- name: GetReadingSessionsInRange :many
+SELECT sqlc.embed(reading_sessions), books.title
+FROM reading_sessions
+JOIN books ON books.id = reading_sessions.book_id
+WHERE reading_sessions.user_id = sqlc.arg(user_id)
+  AND reading_sessions.started_at >= sqlc.arg(from_time) AND reading_sessions.started_at < sqlc.arg(to_time)
+ORDER BY reading_sessions.book_id, reading_sessions.started_at;
+
+-- name: GetBooksFinishedPerMonth :many
+SELECT to_char(finished_at AT TIME ZONE 'UTC' AT TIME ZONE sqlc.arg(time_zone)::text, 'YYYY-MM')::text AS month, COUNT(*)::int AS books
+FROM completed_reads
+WHERE user_id = sqlc.arg(user_id) AND finished_at >= sqlc.arg(from_time) AND finished_at < sqlc.arg(to_time)
+GROUP BY month
+ORDER BY month;
+
+-- name: GetPageReadBefore :one
+SELECT to_page FROM reading_events
+WHERE user_id = sqlc.arg(user_id) AND book_id = sqlc.arg(book_id) AND read_at <= sqlc.arg(read_at)
+ORDER BY read_at DESC, recorded_at DESC
+LIMIT 1;
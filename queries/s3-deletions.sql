@@ -0,0 +1,24 @@
+-- name: CreatePendingS3Deletion :exec
+INSERT INTO pending_s3_deletions (s3_key)
+VALUES (sqlc.arg(s3_key));
+
+-- name: ClaimPendingS3Deletions :many
+UPDATE pending_s3_deletions
+SET attempts = attempts + 1, next_attempt_at = NOW() + INTERVAL '5 minutes'
+WHERE id IN (
+  SELECT id FROM pending_s3_deletions
+  WHERE next_attempt_at <= NOW()
+  ORDER BY next_attempt_at
+  LIMIT sqlc.arg(batch_size)
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeletePendingS3Deletion :exec
+DELETE FROM pending_s3_deletions
+WHERE id = sqlc.arg(id);
+
+-- name: RetryPendingS3Deletion :exec
+UPDATE pending_s3_deletions
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg(delay_seconds)::float8), last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,31 @@
+-- name: DeleteBookPages :exec
+DELETE FROM book_pages WHERE book_id = sqlc.arg(book_id);
+
+-- name: CreateBookPage :exec
+INSERT INTO book_pages (book_id, page_number, part, content)
+VALUES (sqlc.arg(book_id), sqlc.arg(page_number), sqlc.arg(part), sqlc.arg(content));
+
+-- name: SearchBooks :many
+SELECT books.id AS book_id, books.title, books.author,
+  ts_headline('simple', translate(books.title || COALESCE(' - ' || books.author, ''), E'\uE000\uE001', ''), websearch_to_tsquery('simple', sqlc.arg(query)::text),
+    E'StartSel=\uE000, StopSel=\uE001, HighlightAll=true')::text AS snippet,
+  ts_rank(books.search_vector, websearch_to_tsquery('simple', sqlc.arg(query)::text))::float8 AS rank
+FROM books
+WHERE (books.owner_id = sqlc.arg(user_id)
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+  AND books.search_vector @@ websearch_to_tsquery('simple', sqlc.arg(query)::text)
+ORDER BY rank DESC, books.id
+LIMIT sqlc.arg(result_limit);
+
+-- name: SearchBookPages :many
+SELECT book_pages.book_id, books.title, books.author, book_pages.page_number,
+  ts_headline('english', translate(book_pages.content, E'\uE000\uE001', ''), websearch_to_tsquery('english', sqlc.arg(query)::text),
+    E'StartSel=\uE000, StopSel=\uE001, MaxWords=35, MinWords=15, MaxFragments=2')::text AS snippet,
+  ts_rank(book_pages.search_vector, websearch_to_tsquery('english', sqlc.arg(query)::text))::float8 AS rank
+FROM book_pages
+JOIN books ON books.id = book_pages.book_id
+WHERE (books.owner_id = sqlc.arg(user_id)
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = sqlc.arg(user_id)))
+  AND book_pages.search_vector @@ websearch_to_tsquery('english', sqlc.arg(query)::text)
+ORDER BY rank DESC, book_pages.book_id, book_pages.page_number, book_pages.part
+LIMIT sqlc.arg(result_limit);
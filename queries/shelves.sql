@@ -0,0 +1,48 @@
+-- name: GetShelvesByUserID :many
+SELECT sqlc.embed(shelves), (SELECT COUNT(*) FROM shelf_books WHERE shelf_books.shelf_id = shelves.id)::int AS book_count
+FROM shelves
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY position, created_at;
+
+-- name: GetShelfByID :one
+SELECT * FROM shelves
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: CreateShelf :one
+INSERT INTO shelves (id, user_id, name, position)
+VALUES (sqlc.arg(id), sqlc.arg(user_id), sqlc.arg(name),
+  (SELECT COALESCE(MAX(position) + 1, 0) FROM shelves WHERE user_id = sqlc.arg(user_id)))
+RETURNING *;
+
+-- name: RenameShelf :one
+UPDATE shelves
+SET name = sqlc.arg(name), updated_at = NOW()
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: SetShelfPosition :execrows
+UPDATE shelves
+SET position = sqlc.arg(position), updated_at = NOW()
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: DeleteShelf :execrows
+DELETE FROM shelves
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: AddBookToShelf :exec
+INSERT INTO shelf_books (shelf_id, book_id)
+VALUES (sqlc.arg(shelf_id), sqlc.arg(book_id))
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveBookFromShelf :execrows
+DELETE FROM shelf_books
+WHERE shelf_id = sqlc.arg(shelf_id) AND book_id = sqlc.arg(book_id);
+
+-- name: GetShelvesByIDs :many
+SELECT * FROM shelves
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetShelfBookIDs :many
+SELECT shelf_id, book_id FROM shelf_books
+WHERE shelf_id = ANY(sqlc.arg(shelf_ids)::uuid[])
+ORDER BY added_at;
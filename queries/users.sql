@@ -12,3 +12,23 @@ VALUES (sqlc.arg(id), sqlc.arg(username), sqlc.arg(first_name), sqlc.arg(last_na
 RETURNING *;
 
 
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE lower(email) = lower(sqlc.arg(email)::text);
+
+-- name: SetUserTimeZone :one
+UPDATE users
+SET time_zone = sqlc.arg(time_zone), updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UpdateUserProfile :execrows
+UPDATE users
+SET email = sqlc.arg(email), username = sqlc.arg(username), first_name = sqlc.arg(first_name), last_name = sqlc.arg(last_name),
+    phone = sqlc.arg(phone), profile_synced_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: GetUserWithFreshProfile :one
+SELECT * FROM users
+WHERE id = sqlc.arg(id) AND profile_synced_at > NOW() - sqlc.arg(max_age_seconds)::int * INTERVAL '1 second';
@@ -13,9 +13,9 @@ import (
 )
 
 const createBook = `-- name: CreateBook :one
-INSERT INTO books (id, title, author, owner_id, s3_key, total_pages)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, title, author, owner_id, s3_key, total_pages
+INSERT INTO books (id, title, author, owner_id, s3_key, total_pages, language, format)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash
 `
 
 type CreateBookParams struct {
@@ -23,8 +23,10 @@ type CreateBookParams struct {
 	Title      string    `json:"title"`
 	Author     *string   `json:"author"`
 	OwnerID    string    `json:"owner_id"`
-	S3Key      string    `json:"s3_key"`
+	S3Key      *string   `json:"s3_key"`
 	TotalPages int32     `json:"total_pages"`
+	Language   *string   `json:"language"`
+	Format     *string   `json:"format"`
 }
 
 func (q *Queries) CreateBook(ctx context.Context, arg CreateBookParams) (Book, error) {
@@ -35,6 +37,8 @@ func (q *Queries) CreateBook(ctx context.Context, arg CreateBookParams) (Book, e
 		arg.OwnerID,
 		arg.S3Key,
 		arg.TotalPages,
+		arg.Language,
+		arg.Format,
 	)
 	var i Book
 	err := row.Scan(
@@ -44,6 +48,16 @@ func (q *Queries) CreateBook(ctx context.Context, arg CreateBookParams) (Book, e
 		&i.OwnerID,
 		&i.S3Key,
 		&i.TotalPages,
+		&i.Description,
+		&i.Language,
+		&i.Isbn,
+		&i.Format,
+		&i.CoverSmallKey,
+		&i.CoverMediumKey,
+		&i.CoverLargeKey,
+		&i.AddedAt,
+		&i.SearchVector,
+		&i.KoreaderHash,
 	)
 	return i, err
 }
@@ -58,7 +72,7 @@ func (q *Queries) DeleteBook(ctx context.Context, id uuid.UUID) error {
 }
 
 const getBookByID = `-- name: GetBookByID :one
-SELECT id, title, author, owner_id, s3_key, total_pages FROM books WHERE id = $1
+SELECT id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash FROM books WHERE id = $1
 `
 
 func (q *Queries) GetBookByID(ctx context.Context, id uuid.UUID) (Book, error) {
@@ -71,21 +85,33 @@ func (q *Queries) GetBookByID(ctx context.Context, id uuid.UUID) (Book, error) {
 		&i.OwnerID,
 		&i.S3Key,
 		&i.TotalPages,
+		&i.Description,
+		&i.Language,
+		&i.Isbn,
+		&i.Format,
+		&i.CoverSmallKey,
+		&i.CoverMediumKey,
+		&i.CoverLargeKey,
+		&i.AddedAt,
+		&i.SearchVector,
+		&i.KoreaderHash,
 	)
 	return i, err
 }
 
 const getBooksByOwnerID = `-- name: GetBooksByOwnerID :many
-SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, reading_progress.current_page, reading_progress.percentage_complete 
+SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, books.description, books.language, books.isbn, books.format, books.cover_small_key, books.cover_medium_key, books.cover_large_key, books.added_at, books.search_vector, books.koreader_hash, reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at
 FROM books 
-LEFT JOIN reading_progress on reading_progress.book_id = books.id
+LEFT JOIN reading_progress on reading_progress.book_id = books.id AND reading_progress.user_id = books.owner_id
 WHERE owner_id = $1
+ORDER BY lower(books.title), books.id
 `
 
 type GetBooksByOwnerIDRow struct {
-	Book               Book           `json:"book"`
-	CurrentPage        *int32         `json:"current_page"`
-	PercentageComplete pgtype.Numeric `json:"percentage_complete"`
+	Book               Book             `json:"book"`
+	CurrentPage        *int32           `json:"current_page"`
+	PercentageComplete pgtype.Numeric   `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp `json:"last_read_at"`
 }
 
 func (q *Queries) GetBooksByOwnerID(ctx context.Context, ownerID string) ([]GetBooksByOwnerIDRow, error) {
@@ -104,8 +130,19 @@ func (q *Queries) GetBooksByOwnerID(ctx context.Context, ownerID string) ([]GetB
 			&i.Book.OwnerID,
 			&i.Book.S3Key,
 			&i.Book.TotalPages,
+			&i.Book.Description,
+			&i.Book.Language,
+			&i.Book.Isbn,
+			&i.Book.Format,
+			&i.Book.CoverSmallKey,
+			&i.Book.CoverMediumKey,
+			&i.Book.CoverLargeKey,
+			&i.Book.AddedAt,
+			&i.Book.SearchVector,
+			&i.Book.KoreaderHash,
 			&i.CurrentPage,
 			&i.PercentageComplete,
+			&i.LastReadAt,
 		); err != nil {
 			return nil, err
 		}
@@ -116,3 +153,940 @@ func (q *Queries) GetBooksByOwnerID(ctx context.Context, ownerID string) ([]GetB
 	}
 	return items, nil
 }
+
+const getLibraryPageByAddedAt = `-- name: GetLibraryPageByAddedAt :many
+WITH page AS (
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE books.owner_id = $1 AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (books.added_at, books.id) > ($6::text::timestamp, $7::uuid))
+  ORDER BY books.added_at, books.id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE books.owner_id = $1 AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (books.added_at, books.id) < ($6::text::timestamp, $7::uuid))
+  ORDER BY books.added_at DESC, books.id DESC
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE book_shares.user_id = $1 AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (books.added_at, books.id) > ($6::text::timestamp, $7::uuid))
+  ORDER BY books.added_at, books.id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE book_shares.user_id = $1 AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (books.added_at, books.id) < ($6::text::timestamp, $7::uuid))
+  ORDER BY books.added_at DESC, books.id DESC
+  LIMIT $8)
+)
+SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, books.description, books.language, books.isbn, books.format, books.cover_small_key, books.cover_medium_key, books.cover_large_key, books.added_at, books.search_vector, books.koreader_hash, reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (books.added_at)::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+ORDER BY
+  CASE WHEN $2::boolean THEN books.added_at END DESC,
+  CASE WHEN $2::boolean THEN books.id END DESC,
+  CASE WHEN NOT $2::boolean THEN books.added_at END,
+  CASE WHEN NOT $2::boolean THEN books.id END
+LIMIT $8
+`
+
+type GetLibraryPageByAddedAtParams struct {
+	UserID     string      `json:"user_id"`
+	Descending bool        `json:"descending"`
+	Author     *string     `json:"author"`
+	Status     *string     `json:"status"`
+	ShelfID    pgtype.UUID `json:"shelf_id"`
+	CursorKey  *string     `json:"cursor_key"`
+	CursorID   pgtype.UUID `json:"cursor_id"`
+	PageSize   int32       `json:"page_size"`
+}
+
+type GetLibraryPageByAddedAtRow struct {
+	Book               Book             `json:"book"`
+	CurrentPage        *int32           `json:"current_page"`
+	PercentageComplete pgtype.Numeric   `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp `json:"last_read_at"`
+	Status             *string          `json:"status"`
+	SortKey            string           `json:"sort_key"`
+}
+
+func (q *Queries) GetLibraryPageByAddedAt(ctx context.Context, arg GetLibraryPageByAddedAtParams) ([]GetLibraryPageByAddedAtRow, error) {
+	rows, err := q.db.Query(ctx, getLibraryPageByAddedAt,
+		arg.UserID,
+		arg.Descending,
+		arg.Author,
+		arg.Status,
+		arg.ShelfID,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLibraryPageByAddedAtRow
+	for rows.Next() {
+		var i GetLibraryPageByAddedAtRow
+		if err := rows.Scan(
+			&i.Book.ID,
+			&i.Book.Title,
+			&i.Book.Author,
+			&i.Book.OwnerID,
+			&i.Book.S3Key,
+			&i.Book.TotalPages,
+			&i.Book.Description,
+			&i.Book.Language,
+			&i.Book.Isbn,
+			&i.Book.Format,
+			&i.Book.CoverSmallKey,
+			&i.Book.CoverMediumKey,
+			&i.Book.CoverLargeKey,
+			&i.Book.AddedAt,
+			&i.Book.SearchVector,
+			&i.Book.KoreaderHash,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLibraryPageByAuthor = `-- name: GetLibraryPageByAuthor :many
+WITH page AS (
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE books.owner_id = $1 AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) > ($6::text, $7::uuid))
+  ORDER BY lower(COALESCE(books.author, '')), books.id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE books.owner_id = $1 AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) < ($6::text, $7::uuid))
+  ORDER BY lower(COALESCE(books.author, '')) DESC, books.id DESC
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE book_shares.user_id = $1 AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) > ($6::text, $7::uuid))
+  ORDER BY lower(COALESCE(books.author, '')), books.id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE book_shares.user_id = $1 AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(COALESCE(books.author, '')), books.id) < ($6::text, $7::uuid))
+  ORDER BY lower(COALESCE(books.author, '')) DESC, books.id DESC
+  LIMIT $8)
+)
+SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, books.description, books.language, books.isbn, books.format, books.cover_small_key, books.cover_medium_key, books.cover_large_key, books.added_at, books.search_vector, books.koreader_hash, reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (lower(COALESCE(books.author, '')))::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+ORDER BY
+  CASE WHEN $2::boolean THEN lower(COALESCE(books.author, '')) END DESC,
+  CASE WHEN $2::boolean THEN books.id END DESC,
+  CASE WHEN NOT $2::boolean THEN lower(COALESCE(books.author, '')) END,
+  CASE WHEN NOT $2::boolean THEN books.id END
+LIMIT $8
+`
+
+type GetLibraryPageByAuthorParams struct {
+	UserID     string      `json:"user_id"`
+	Descending bool        `json:"descending"`
+	Author     *string     `json:"author"`
+	Status     *string     `json:"status"`
+	ShelfID    pgtype.UUID `json:"shelf_id"`
+	CursorKey  *string     `json:"cursor_key"`
+	CursorID   pgtype.UUID `json:"cursor_id"`
+	PageSize   int32       `json:"page_size"`
+}
+
+type GetLibraryPageByAuthorRow struct {
+	Book               Book             `json:"book"`
+	CurrentPage        *int32           `json:"current_page"`
+	PercentageComplete pgtype.Numeric   `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp `json:"last_read_at"`
+	Status             *string          `json:"status"`
+	SortKey            string           `json:"sort_key"`
+}
+
+func (q *Queries) GetLibraryPageByAuthor(ctx context.Context, arg GetLibraryPageByAuthorParams) ([]GetLibraryPageByAuthorRow, error) {
+	rows, err := q.db.Query(ctx, getLibraryPageByAuthor,
+		arg.UserID,
+		arg.Descending,
+		arg.Author,
+		arg.Status,
+		arg.ShelfID,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLibraryPageByAuthorRow
+	for rows.Next() {
+		var i GetLibraryPageByAuthorRow
+		if err := rows.Scan(
+			&i.Book.ID,
+			&i.Book.Title,
+			&i.Book.Author,
+			&i.Book.OwnerID,
+			&i.Book.S3Key,
+			&i.Book.TotalPages,
+			&i.Book.Description,
+			&i.Book.Language,
+			&i.Book.Isbn,
+			&i.Book.Format,
+			&i.Book.CoverSmallKey,
+			&i.Book.CoverMediumKey,
+			&i.Book.CoverLargeKey,
+			&i.Book.AddedAt,
+			&i.Book.SearchVector,
+			&i.Book.KoreaderHash,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLibraryPageByLastReadAt = `-- name: GetLibraryPageByLastReadAt :many
+WITH page AS (
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = $1
+    AND (books.owner_id = $1
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $1))
+    AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (COALESCE(reading_progress.last_read_at, 'epoch'::timestamp), reading_progress.book_id) > ($6::text::timestamp, $7::uuid))
+  ORDER BY COALESCE(reading_progress.last_read_at, 'epoch'::timestamp), reading_progress.book_id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = $1
+    AND (books.owner_id = $1
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $1))
+    AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (COALESCE(reading_progress.last_read_at, 'epoch'::timestamp), reading_progress.book_id) < ($6::text::timestamp, $7::uuid))
+  ORDER BY COALESCE(reading_progress.last_read_at, 'epoch'::timestamp) DESC, reading_progress.book_id DESC
+  LIMIT $8)
+)
+SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, books.description, books.language, books.isbn, books.format, books.cover_small_key, books.cover_medium_key, books.cover_large_key, books.added_at, books.search_vector, books.koreader_hash, reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (COALESCE(reading_progress.last_read_at, 'epoch'::timestamp))::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+ORDER BY
+  CASE WHEN $2::boolean THEN COALESCE(reading_progress.last_read_at, 'epoch'::timestamp) END DESC,
+  CASE WHEN $2::boolean THEN books.id END DESC,
+  CASE WHEN NOT $2::boolean THEN COALESCE(reading_progress.last_read_at, 'epoch'::timestamp) END,
+  CASE WHEN NOT $2::boolean THEN books.id END
+LIMIT $8
+`
+
+type GetLibraryPageByLastReadAtParams struct {
+	UserID     string      `json:"user_id"`
+	Descending bool        `json:"descending"`
+	Author     *string     `json:"author"`
+	Status     *string     `json:"status"`
+	ShelfID    pgtype.UUID `json:"shelf_id"`
+	CursorKey  *string     `json:"cursor_key"`
+	CursorID   pgtype.UUID `json:"cursor_id"`
+	PageSize   int32       `json:"page_size"`
+}
+
+type GetLibraryPageByLastReadAtRow struct {
+	Book               Book             `json:"book"`
+	CurrentPage        *int32           `json:"current_page"`
+	PercentageComplete pgtype.Numeric   `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp `json:"last_read_at"`
+	Status             *string          `json:"status"`
+	SortKey            string           `json:"sort_key"`
+}
+
+func (q *Queries) GetLibraryPageByLastReadAt(ctx context.Context, arg GetLibraryPageByLastReadAtParams) ([]GetLibraryPageByLastReadAtRow, error) {
+	rows, err := q.db.Query(ctx, getLibraryPageByLastReadAt,
+		arg.UserID,
+		arg.Descending,
+		arg.Author,
+		arg.Status,
+		arg.ShelfID,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLibraryPageByLastReadAtRow
+	for rows.Next() {
+		var i GetLibraryPageByLastReadAtRow
+		if err := rows.Scan(
+			&i.Book.ID,
+			&i.Book.Title,
+			&i.Book.Author,
+			&i.Book.OwnerID,
+			&i.Book.S3Key,
+			&i.Book.TotalPages,
+			&i.Book.Description,
+			&i.Book.Language,
+			&i.Book.Isbn,
+			&i.Book.Format,
+			&i.Book.CoverSmallKey,
+			&i.Book.CoverMediumKey,
+			&i.Book.CoverLargeKey,
+			&i.Book.AddedAt,
+			&i.Book.SearchVector,
+			&i.Book.KoreaderHash,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLibraryPageByPercentageComplete = `-- name: GetLibraryPageByPercentageComplete :many
+WITH page AS (
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = $1
+    AND (books.owner_id = $1
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $1))
+    AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (reading_progress.percentage_complete, reading_progress.book_id) > ($6::text::numeric, $7::uuid))
+  ORDER BY reading_progress.percentage_complete, reading_progress.book_id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM reading_progress
+  JOIN books ON books.id = reading_progress.book_id
+  WHERE reading_progress.user_id = $1
+    AND (books.owner_id = $1
+      OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $1))
+    AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (reading_progress.percentage_complete, reading_progress.book_id) < ($6::text::numeric, $7::uuid))
+  ORDER BY reading_progress.percentage_complete DESC, reading_progress.book_id DESC
+  LIMIT $8)
+)
+SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, books.description, books.language, books.isbn, books.format, books.cover_small_key, books.cover_medium_key, books.cover_large_key, books.added_at, books.search_vector, books.koreader_hash, reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (reading_progress.percentage_complete)::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+ORDER BY
+  CASE WHEN $2::boolean THEN reading_progress.percentage_complete END DESC,
+  CASE WHEN $2::boolean THEN books.id END DESC,
+  CASE WHEN NOT $2::boolean THEN reading_progress.percentage_complete END,
+  CASE WHEN NOT $2::boolean THEN books.id END
+LIMIT $8
+`
+
+type GetLibraryPageByPercentageCompleteParams struct {
+	UserID     string      `json:"user_id"`
+	Descending bool        `json:"descending"`
+	Author     *string     `json:"author"`
+	Status     *string     `json:"status"`
+	ShelfID    pgtype.UUID `json:"shelf_id"`
+	CursorKey  *string     `json:"cursor_key"`
+	CursorID   pgtype.UUID `json:"cursor_id"`
+	PageSize   int32       `json:"page_size"`
+}
+
+type GetLibraryPageByPercentageCompleteRow struct {
+	Book               Book             `json:"book"`
+	CurrentPage        *int32           `json:"current_page"`
+	PercentageComplete pgtype.Numeric   `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp `json:"last_read_at"`
+	Status             *string          `json:"status"`
+	SortKey            string           `json:"sort_key"`
+}
+
+func (q *Queries) GetLibraryPageByPercentageComplete(ctx context.Context, arg GetLibraryPageByPercentageCompleteParams) ([]GetLibraryPageByPercentageCompleteRow, error) {
+	rows, err := q.db.Query(ctx, getLibraryPageByPercentageComplete,
+		arg.UserID,
+		arg.Descending,
+		arg.Author,
+		arg.Status,
+		arg.ShelfID,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLibraryPageByPercentageCompleteRow
+	for rows.Next() {
+		var i GetLibraryPageByPercentageCompleteRow
+		if err := rows.Scan(
+			&i.Book.ID,
+			&i.Book.Title,
+			&i.Book.Author,
+			&i.Book.OwnerID,
+			&i.Book.S3Key,
+			&i.Book.TotalPages,
+			&i.Book.Description,
+			&i.Book.Language,
+			&i.Book.Isbn,
+			&i.Book.Format,
+			&i.Book.CoverSmallKey,
+			&i.Book.CoverMediumKey,
+			&i.Book.CoverLargeKey,
+			&i.Book.AddedAt,
+			&i.Book.SearchVector,
+			&i.Book.KoreaderHash,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLibraryPageByTitle = `-- name: GetLibraryPageByTitle :many
+WITH page AS (
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE books.owner_id = $1 AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(books.title), books.id) > ($6::text, $7::uuid))
+  ORDER BY lower(books.title), books.id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM books
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE books.owner_id = $1 AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(books.title), books.id) < ($6::text, $7::uuid))
+  ORDER BY lower(books.title) DESC, books.id DESC
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE book_shares.user_id = $1 AND NOT $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(books.title), books.id) > ($6::text, $7::uuid))
+  ORDER BY lower(books.title), books.id
+  LIMIT $8)
+  UNION ALL
+  (SELECT books.id FROM book_shares
+  JOIN books ON books.id = book_shares.book_id
+  LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+  WHERE book_shares.user_id = $1 AND $2::boolean
+    AND ($3::text IS NULL OR books.author ILIKE '%' || $3::text || '%' ESCAPE '\')
+    AND ($4::text IS NULL OR COALESCE(reading_progress.status, 'want_to_read') = $4::text)
+    AND ($5::uuid IS NULL OR EXISTS (
+      SELECT 1 FROM shelf_books WHERE shelf_books.shelf_id = $5::uuid AND shelf_books.book_id = books.id))
+    AND ($6::text IS NULL OR (lower(books.title), books.id) < ($6::text, $7::uuid))
+  ORDER BY lower(books.title) DESC, books.id DESC
+  LIMIT $8)
+)
+SELECT books.id, books.title, books.author, books.owner_id, books.s3_key, books.total_pages, books.description, books.language, books.isbn, books.format, books.cover_small_key, books.cover_medium_key, books.cover_large_key, books.added_at, books.search_vector, books.koreader_hash, reading_progress.current_page, reading_progress.percentage_complete, reading_progress.last_read_at, reading_progress.status, (lower(books.title))::text AS sort_key
+FROM page
+JOIN books ON books.id = page.id
+LEFT JOIN reading_progress ON reading_progress.book_id = books.id AND reading_progress.user_id = $1
+ORDER BY
+  CASE WHEN $2::boolean THEN lower(books.title) END DESC,
+  CASE WHEN $2::boolean THEN books.id END DESC,
+  CASE WHEN NOT $2::boolean THEN lower(books.title) END,
+  CASE WHEN NOT $2::boolean THEN books.id END
+LIMIT $8
+`
+
+type GetLibraryPageByTitleParams struct {
+	UserID     string      `json:"user_id"`
+	Descending bool        `json:"descending"`
+	Author     *string     `json:"author"`
+	Status     *string     `json:"status"`
+	ShelfID    pgtype.UUID `json:"shelf_id"`
+	CursorKey  *string     `json:"cursor_key"`
+	CursorID   pgtype.UUID `json:"cursor_id"`
+	PageSize   int32       `json:"page_size"`
+}
+
+type GetLibraryPageByTitleRow struct {
+	Book               Book             `json:"book"`
+	CurrentPage        *int32           `json:"current_page"`
+	PercentageComplete pgtype.Numeric   `json:"percentage_complete"`
+	LastReadAt         pgtype.Timestamp `json:"last_read_at"`
+	Status             *string          `json:"status"`
+	SortKey            string           `json:"sort_key"`
+}
+
+func (q *Queries) GetLibraryPageByTitle(ctx context.Context, arg GetLibraryPageByTitleParams) ([]GetLibraryPageByTitleRow, error) {
+	rows, err := q.db.Query(ctx, getLibraryPageByTitle,
+		arg.UserID,
+		arg.Descending,
+		arg.Author,
+		arg.Status,
+		arg.ShelfID,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLibraryPageByTitleRow
+	for rows.Next() {
+		var i GetLibraryPageByTitleRow
+		if err := rows.Scan(
+			&i.Book.ID,
+			&i.Book.Title,
+			&i.Book.Author,
+			&i.Book.OwnerID,
+			&i.Book.S3Key,
+			&i.Book.TotalPages,
+			&i.Book.Description,
+			&i.Book.Language,
+			&i.Book.Isbn,
+			&i.Book.Format,
+			&i.Book.CoverSmallKey,
+			&i.Book.CoverMediumKey,
+			&i.Book.CoverLargeKey,
+			&i.Book.AddedAt,
+			&i.Book.SearchVector,
+			&i.Book.KoreaderHash,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReadableBookByKOReaderHash = `-- name: GetReadableBookByKOReaderHash :one
+SELECT id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash FROM books
+WHERE koreader_hash = $1
+  AND (owner_id = $2
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $2))
+ORDER BY added_at
+LIMIT 1
+`
+
+type GetReadableBookByKOReaderHashParams struct {
+	KoreaderHash *string `json:"koreader_hash"`
+	UserID       string  `json:"user_id"`
+}
+
+func (q *Queries) GetReadableBookByKOReaderHash(ctx context.Context, arg GetReadableBookByKOReaderHashParams) (Book, error) {
+	row := q.db.QueryRow(ctx, getReadableBookByKOReaderHash, arg.KoreaderHash, arg.UserID)
+	var i Book
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Author,
+		&i.OwnerID,
+		&i.S3Key,
+		&i.TotalPages,
+		&i.Description,
+		&i.Language,
+		&i.Isbn,
+		&i.Format,
+		&i.CoverSmallKey,
+		&i.CoverMediumKey,
+		&i.CoverLargeKey,
+		&i.AddedAt,
+		&i.SearchVector,
+		&i.KoreaderHash,
+	)
+	return i, err
+}
+
+const getReadableBooksByIDs = `-- name: GetReadableBooksByIDs :many
+SELECT id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash FROM books
+WHERE id = ANY($1::uuid[])
+  AND (owner_id = $2
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $2))
+`
+
+type GetReadableBooksByIDsParams struct {
+	Ids    []uuid.UUID `json:"ids"`
+	UserID string      `json:"user_id"`
+}
+
+func (q *Queries) GetReadableBooksByIDs(ctx context.Context, arg GetReadableBooksByIDsParams) ([]Book, error) {
+	rows, err := q.db.Query(ctx, getReadableBooksByIDs, arg.Ids, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Book
+	for rows.Next() {
+		var i Book
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Author,
+			&i.OwnerID,
+			&i.S3Key,
+			&i.TotalPages,
+			&i.Description,
+			&i.Language,
+			&i.Isbn,
+			&i.Format,
+			&i.CoverSmallKey,
+			&i.CoverMediumKey,
+			&i.CoverLargeKey,
+			&i.AddedAt,
+			&i.SearchVector,
+			&i.KoreaderHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReadableBooksByUserID = `-- name: GetReadableBooksByUserID :many
+SELECT id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash FROM books
+WHERE owner_id = $1
+  OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $1)
+ORDER BY added_at
+`
+
+func (q *Queries) GetReadableBooksByUserID(ctx context.Context, userID string) ([]Book, error) {
+	rows, err := q.db.Query(ctx, getReadableBooksByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Book
+	for rows.Next() {
+		var i Book
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Author,
+			&i.OwnerID,
+			&i.S3Key,
+			&i.TotalPages,
+			&i.Description,
+			&i.Language,
+			&i.Isbn,
+			&i.Format,
+			&i.CoverSmallKey,
+			&i.CoverMediumKey,
+			&i.CoverLargeKey,
+			&i.AddedAt,
+			&i.SearchVector,
+			&i.KoreaderHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReadableBooksWithoutKOReaderHash = `-- name: GetReadableBooksWithoutKOReaderHash :many
+SELECT id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash FROM books
+WHERE koreader_hash IS NULL AND s3_key IS NOT NULL
+  AND (owner_id = $1
+    OR EXISTS (SELECT 1 FROM book_shares WHERE book_shares.book_id = books.id AND book_shares.user_id = $1))
+`
+
+func (q *Queries) GetReadableBooksWithoutKOReaderHash(ctx context.Context, userID string) ([]Book, error) {
+	rows, err := q.db.Query(ctx, getReadableBooksWithoutKOReaderHash, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Book
+	for rows.Next() {
+		var i Book
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Author,
+			&i.OwnerID,
+			&i.S3Key,
+			&i.TotalPages,
+			&i.Description,
+			&i.Language,
+			&i.Isbn,
+			&i.Format,
+			&i.CoverSmallKey,
+			&i.CoverMediumKey,
+			&i.CoverLargeKey,
+			&i.AddedAt,
+			&i.SearchVector,
+			&i.KoreaderHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setBookCovers = `-- name: SetBookCovers :exec
+UPDATE books
+SET cover_small_key = $1, cover_medium_key = $2, cover_large_key = $3
+WHERE id = $4
+`
+
+type SetBookCoversParams struct {
+	CoverSmallKey  *string   `json:"cover_small_key"`
+	CoverMediumKey *string   `json:"cover_medium_key"`
+	CoverLargeKey  *string   `json:"cover_large_key"`
+	ID             uuid.UUID `json:"id"`
+}
+
+func (q *Queries) SetBookCovers(ctx context.Context, arg SetBookCoversParams) error {
+	_, err := q.db.Exec(ctx, setBookCovers,
+		arg.CoverSmallKey,
+		arg.CoverMediumKey,
+		arg.CoverLargeKey,
+		arg.ID,
+	)
+	return err
+}
+
+const setBookKOReaderHash = `-- name: SetBookKOReaderHash :exec
+UPDATE books SET koreader_hash = $1
+WHERE id = $2
+`
+
+type SetBookKOReaderHashParams struct {
+	KoreaderHash *string   `json:"koreader_hash"`
+	ID           uuid.UUID `json:"id"`
+}
+
+func (q *Queries) SetBookKOReaderHash(ctx context.Context, arg SetBookKOReaderHashParams) error {
+	_, err := q.db.Exec(ctx, setBookKOReaderHash, arg.KoreaderHash, arg.ID)
+	return err
+}
+
+const setBookMetadata = `-- name: SetBookMetadata :one
+UPDATE books
+SET title = COALESCE($1::text, title),
+    author = NULLIF(COALESCE($2::text, author), ''),
+    total_pages = COALESCE($3::int, total_pages),
+    language = COALESCE($4::text, language),
+    format = COALESCE($5::text, format)
+WHERE id = $6
+RETURNING id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash
+`
+
+type SetBookMetadataParams struct {
+	Title      *string   `json:"title"`
+	Author     *string   `json:"author"`
+	TotalPages *int32    `json:"total_pages"`
+	Language   *string   `json:"language"`
+	Format     *string   `json:"format"`
+	ID         uuid.UUID `json:"id"`
+}
+
+func (q *Queries) SetBookMetadata(ctx context.Context, arg SetBookMetadataParams) (Book, error) {
+	row := q.db.QueryRow(ctx, setBookMetadata,
+		arg.Title,
+		arg.Author,
+		arg.TotalPages,
+		arg.Language,
+		arg.Format,
+		arg.ID,
+	)
+	var i Book
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Author,
+		&i.OwnerID,
+		&i.S3Key,
+		&i.TotalPages,
+		&i.Description,
+		&i.Language,
+		&i.Isbn,
+		&i.Format,
+		&i.CoverSmallKey,
+		&i.CoverMediumKey,
+		&i.CoverLargeKey,
+		&i.AddedAt,
+		&i.SearchVector,
+		&i.KoreaderHash,
+	)
+	return i, err
+}
+
+const updateBook = `-- name: UpdateBook :one
+UPDATE books
+SET title = COALESCE($1::text, title),
+    author = NULLIF(COALESCE($2::text, author), ''),
+    total_pages = COALESCE($3::int, total_pages),
+    description = NULLIF(COALESCE($4::text, description), ''),
+    language = COALESCE($5::text, language),
+    isbn = NULLIF(COALESCE($6::text, isbn), '')
+WHERE id = $7
+RETURNING id, title, author, owner_id, s3_key, total_pages, description, language, isbn, format, cover_small_key, cover_medium_key, cover_large_key, added_at, search_vector, koreader_hash
+`
+
+type UpdateBookParams struct {
+	Title       *string   `json:"title"`
+	Author      *string   `json:"author"`
+	TotalPages  *int32    `json:"total_pages"`
+	Description *string   `json:"description"`
+	Language    *string   `json:"language"`
+	Isbn        *string   `json:"isbn"`
+	ID          uuid.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateBook(ctx context.Context, arg UpdateBookParams) (Book, error) {
+	row := q.db.QueryRow(ctx, updateBook,
+		arg.Title,
+		arg.Author,
+		arg.TotalPages,
+		arg.Description,
+		arg.Language,
+		arg.Isbn,
+		arg.ID,
+	)
+	var i Book
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Author,
+		&i.OwnerID,
+		&i.S3Key,
+		&i.TotalPages,
+		&i.Description,
+		&i.Language,
+		&i.Isbn,
+		&i.Format,
+		&i.CoverSmallKey,
+		&i.CoverMediumKey,
+		&i.CoverLargeKey,
+		&i.AddedAt,
+		&i.SearchVector,
+		&i.KoreaderHash,
+	)
+	return i, err
+}
@@ -8,32 +8,220 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiToken struct {
+	ID          uuid.UUID        `json:"id"`
+	UserID      string           `json:"user_id"`
+	Name        string           `json:"name"`
+	TokenPrefix string           `json:"token_prefix"`
+	TokenHash   string           `json:"-"`
+	Scopes      []string         `json:"scopes"`
+	CreatedAt   time.Time        `json:"created_at"`
+	LastUsedAt  pgtype.Timestamp `json:"last_used_at"`
+}
+
 type Book struct {
+	ID             uuid.UUID `json:"id"`
+	Title          string    `json:"title"`
+	Author         *string   `json:"author"`
+	OwnerID        string    `json:"owner_id"`
+	S3Key          *string   `json:"s3_key"`
+	TotalPages     int32     `json:"total_pages"`
+	Description    *string   `json:"description"`
+	Language       *string   `json:"language"`
+	Isbn           *string   `json:"isbn"`
+	Format         *string   `json:"format"`
+	CoverSmallKey  *string   `json:"cover_small_key"`
+	CoverMediumKey *string   `json:"cover_medium_key"`
+	CoverLargeKey  *string   `json:"cover_large_key"`
+	AddedAt        time.Time `json:"added_at"`
+	SearchVector   string    `json:"-"`
+	KoreaderHash   *string   `json:"koreader_hash"`
+}
+
+type BookPage struct {
+	BookID       uuid.UUID `json:"book_id"`
+	PageNumber   int32     `json:"page_number"`
+	Content      string    `json:"content"`
+	SearchVector string    `json:"-"`
+	Part         int32     `json:"part"`
+}
+
+type BookShare struct {
+	BookID    uuid.UUID `json:"book_id"`
+	UserID    string    `json:"user_id"`
+	SharedBy  string    `json:"shared_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Bookmark struct {
+	ID                      uuid.UUID `json:"id"`
+	BookID                  uuid.UUID `json:"book_id"`
+	UserID                  string    `json:"user_id"`
+	PageNumber              *int32    `json:"page_number"`
+	LocatorHref             *string   `json:"locator_href"`
+	LocatorCfi              *string   `json:"locator_cfi"`
+	LocatorProgression      *float64  `json:"locator_progression"`
+	LocatorTotalProgression *float64  `json:"locator_total_progression"`
+	Label                   string    `json:"label"`
+	CreatedAt               time.Time `json:"created_at"`
+	Location                *int32    `json:"location"`
+}
+
+type ChangeLog struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Entity    string    `json:"entity"`
+	EntityID  string    `json:"entity_id"`
+	Operation string    `json:"operation"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type CompletedRead struct {
+	ID         uuid.UUID        `json:"id"`
+	UserID     string           `json:"user_id"`
+	BookID     uuid.UUID        `json:"book_id"`
+	StartedAt  pgtype.Timestamp `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+}
+
+type Highlight struct {
+	ID            uuid.UUID `json:"id"`
+	BookID        uuid.UUID `json:"book_id"`
+	UserID        string    `json:"user_id"`
+	PageNumber    *int32    `json:"page_number"`
+	CfiRange      *string   `json:"cfi_range"`
+	SelectedText  string    `json:"selected_text"`
+	Color         string    `json:"color"`
+	Note          *string   `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	LocationStart *int32    `json:"location_start"`
+	LocationEnd   *int32    `json:"location_end"`
+}
+
+type KosyncAccount struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	KeyHash   string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type KosyncProgress struct {
+	UserID     string    `json:"user_id"`
+	Document   string    `json:"document"`
+	Progress   string    `json:"progress"`
+	Percentage float64   `json:"percentage"`
+	Device     string    `json:"device"`
+	DeviceID   string    `json:"device_id"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type OpdsFeedToken struct {
+	UserID    string    `json:"user_id"`
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PendingS3Deletion struct {
+	ID            int64     `json:"id"`
+	S3Key         string    `json:"s3_key"`
+	Attempts      int32     `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     *string   `json:"last_error"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ReadingEvent struct {
 	ID         uuid.UUID `json:"id"`
-	Title      string    `json:"title"`
-	Author     *string   `json:"author"`
-	OwnerID    string    `json:"owner_id"`
-	S3Key      string    `json:"s3_key"`
-	TotalPages int32     `json:"total_pages"`
+	SessionID  uuid.UUID `json:"session_id"`
+	UserID     string    `json:"user_id"`
+	BookID     uuid.UUID `json:"book_id"`
+	FromPage   int32     `json:"from_page"`
+	ToPage     int32     `json:"to_page"`
+	ReadAt     time.Time `json:"read_at"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+type ReadingGoal struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Target    int32     `json:"target"`
+	Year      *int32    `json:"year"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ReadingPosition struct {
+	UserID                  string    `json:"user_id"`
+	BookID                  uuid.UUID `json:"book_id"`
+	DeviceID                string    `json:"device_id"`
+	DeviceName              *string   `json:"device_name"`
+	CurrentPage             int32     `json:"current_page"`
+	PercentageComplete      float64   `json:"percentage_complete"`
+	LocatorHref             *string   `json:"locator_href"`
+	LocatorCfi              *string   `json:"locator_cfi"`
+	LocatorProgression      *float64  `json:"locator_progression"`
+	LocatorTotalProgression *float64  `json:"locator_total_progression"`
+	ReadAt                  time.Time `json:"read_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
 }
 
 type ReadingProgress struct {
-	UserID             string    `json:"user_id"`
-	BookID             uuid.UUID `json:"book_id"`
-	CurrentPage        int32     `json:"current_page"`
-	PercentageComplete float64   `json:"percentage_complete"`
-	LastReadAt         time.Time `json:"last_read_at"`
+	UserID                  string           `json:"user_id"`
+	BookID                  uuid.UUID        `json:"book_id"`
+	CurrentPage             int32            `json:"current_page"`
+	PercentageComplete      float64          `json:"percentage_complete"`
+	LastReadAt              pgtype.Timestamp `json:"last_read_at"`
+	Status                  string           `json:"status"`
+	StartedAt               pgtype.Timestamp `json:"started_at"`
+	FinishedAt              pgtype.Timestamp `json:"finished_at"`
+	LocatorHref             *string          `json:"locator_href"`
+	LocatorCfi              *string          `json:"locator_cfi"`
+	LocatorProgression      *float64         `json:"locator_progression"`
+	LocatorTotalProgression *float64         `json:"locator_total_progression"`
+	UpdatedAt               time.Time        `json:"updated_at"`
 }
 
-type User struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Username  *string   `json:"username"`
-	FirstName *string   `json:"first_name"`
-	LastName  *string   `json:"last_name"`
-	AddedAt   time.Time `json:"added_at"`
+type ReadingSession struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    string    `json:"user_id"`
+	BookID    uuid.UUID `json:"book_id"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	StartPage int32     `json:"start_page"`
+	EndPage   int32     `json:"end_page"`
+	PagesRead int32     `json:"pages_read"`
+}
+
+type Shelf struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Position  int32     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Phone     *string   `json:"phone"`
+}
+
+type ShelfBook struct {
+	ShelfID uuid.UUID `json:"shelf_id"`
+	BookID  uuid.UUID `json:"book_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type User struct {
+	ID              string           `json:"id"`
+	Email           string           `json:"email"`
+	Username        *string          `json:"username"`
+	FirstName       *string          `json:"first_name"`
+	LastName        *string          `json:"last_name"`
+	AddedAt         time.Time        `json:"added_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	Phone           *string          `json:"phone"`
+	TimeZone        string           `json:"time_zone"`
+	ProfileSyncedAt pgtype.Timestamp `json:"profile_synced_at"`
 }
@@ -7,13 +7,48 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const createCompletedRead = `-- name: CreateCompletedRead :one
+INSERT INTO completed_reads (id, user_id, book_id, started_at, finished_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, book_id, started_at, finished_at
+`
+
+type CreateCompletedReadParams struct {
+	ID         uuid.UUID        `json:"id"`
+	UserID     string           `json:"user_id"`
+	BookID     uuid.UUID        `json:"book_id"`
+	StartedAt  pgtype.Timestamp `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+}
+
+func (q *Queries) CreateCompletedRead(ctx context.Context, arg CreateCompletedReadParams) (CompletedRead, error) {
+	row := q.db.QueryRow(ctx, createCompletedRead,
+		arg.ID,
+		arg.UserID,
+		arg.BookID,
+		arg.StartedAt,
+		arg.FinishedAt,
+	)
+	var i CompletedRead
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.BookID,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
 const createReadingProgress = `-- name: CreateReadingProgress :one
 INSERT INTO reading_progress (book_id, user_id) VALUES ($1, $2)
-RETURNING user_id, book_id, current_page, percentage_complete, last_read_at
+RETURNING user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at
 `
 
 type CreateReadingProgressParams struct {
@@ -30,10 +65,27 @@ func (q *Queries) CreateReadingProgress(ctx context.Context, arg CreateReadingPr
 		&i.CurrentPage,
 		&i.PercentageComplete,
 		&i.LastReadAt,
+		&i.Status,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.LocatorHref,
+		&i.LocatorCfi,
+		&i.LocatorProgression,
+		&i.LocatorTotalProgression,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const deleteReadingProgressByBookID = `-- name: DeleteReadingProgressByBookID :exec
+DELETE FROM reading_progress WHERE book_id = $1
+`
+
+func (q *Queries) DeleteReadingProgressByBookID(ctx context.Context, bookID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteReadingProgressByBookID, bookID)
+	return err
+}
+
 const deteleReadingProgress = `-- name: DeteleReadingProgress :exec
 DELETE FROM reading_progress WHERE book_id = $1 AND user_id = $2
 `
@@ -48,24 +100,312 @@ func (q *Queries) DeteleReadingProgress(ctx context.Context, arg DeteleReadingPr
 	return err
 }
 
+const ensureReadingProgress = `-- name: EnsureReadingProgress :exec
+INSERT INTO reading_progress (book_id, user_id) VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type EnsureReadingProgressParams struct {
+	BookID uuid.UUID `json:"book_id"`
+	UserID string    `json:"user_id"`
+}
+
+func (q *Queries) EnsureReadingProgress(ctx context.Context, arg EnsureReadingProgressParams) error {
+	_, err := q.db.Exec(ctx, ensureReadingProgress, arg.BookID, arg.UserID)
+	return err
+}
+
+const getCompletedReads = `-- name: GetCompletedReads :many
+SELECT id, user_id, book_id, started_at, finished_at FROM completed_reads
+WHERE book_id = $1 AND user_id = $2
+ORDER BY finished_at
+`
+
+type GetCompletedReadsParams struct {
+	BookID uuid.UUID `json:"book_id"`
+	UserID string    `json:"user_id"`
+}
+
+func (q *Queries) GetCompletedReads(ctx context.Context, arg GetCompletedReadsParams) ([]CompletedRead, error) {
+	rows, err := q.db.Query(ctx, getCompletedReads, arg.BookID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CompletedRead
+	for rows.Next() {
+		var i CompletedRead
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.BookID,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReadingProgress = `-- name: GetReadingProgress :one
+SELECT user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at FROM reading_progress
+WHERE book_id = $1 AND user_id = $2
+`
+
+type GetReadingProgressParams struct {
+	BookID uuid.UUID `json:"book_id"`
+	UserID string    `json:"user_id"`
+}
+
+func (q *Queries) GetReadingProgress(ctx context.Context, arg GetReadingProgressParams) (ReadingProgress, error) {
+	row := q.db.QueryRow(ctx, getReadingProgress, arg.BookID, arg.UserID)
+	var i ReadingProgress
+	err := row.Scan(
+		&i.UserID,
+		&i.BookID,
+		&i.CurrentPage,
+		&i.PercentageComplete,
+		&i.LastReadAt,
+		&i.Status,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.LocatorHref,
+		&i.LocatorCfi,
+		&i.LocatorProgression,
+		&i.LocatorTotalProgression,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getReadingProgressByBookID = `-- name: GetReadingProgressByBookID :many
+SELECT user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at FROM reading_progress
+WHERE book_id = $1
+`
+
+func (q *Queries) GetReadingProgressByBookID(ctx context.Context, bookID uuid.UUID) ([]ReadingProgress, error) {
+	rows, err := q.db.Query(ctx, getReadingProgressByBookID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReadingProgress
+	for rows.Next() {
+		var i ReadingProgress
+		if err := rows.Scan(
+			&i.UserID,
+			&i.BookID,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.LocatorHref,
+			&i.LocatorCfi,
+			&i.LocatorProgression,
+			&i.LocatorTotalProgression,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReadingProgressByBookIDs = `-- name: GetReadingProgressByBookIDs :many
+SELECT user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at FROM reading_progress
+WHERE user_id = $1 AND book_id = ANY($2::uuid[])
+`
+
+type GetReadingProgressByBookIDsParams struct {
+	UserID  string      `json:"user_id"`
+	BookIds []uuid.UUID `json:"book_ids"`
+}
+
+func (q *Queries) GetReadingProgressByBookIDs(ctx context.Context, arg GetReadingProgressByBookIDsParams) ([]ReadingProgress, error) {
+	rows, err := q.db.Query(ctx, getReadingProgressByBookIDs, arg.UserID, arg.BookIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReadingProgress
+	for rows.Next() {
+		var i ReadingProgress
+		if err := rows.Scan(
+			&i.UserID,
+			&i.BookID,
+			&i.CurrentPage,
+			&i.PercentageComplete,
+			&i.LastReadAt,
+			&i.Status,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.LocatorHref,
+			&i.LocatorCfi,
+			&i.LocatorProgression,
+			&i.LocatorTotalProgression,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReadingProgressForUpdate = `-- name: GetReadingProgressForUpdate :one
+SELECT user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at FROM reading_progress
+WHERE book_id = $1 AND user_id = $2
+FOR UPDATE
+`
+
+type GetReadingProgressForUpdateParams struct {
+	BookID uuid.UUID `json:"book_id"`
+	UserID string    `json:"user_id"`
+}
+
+func (q *Queries) GetReadingProgressForUpdate(ctx context.Context, arg GetReadingProgressForUpdateParams) (ReadingProgress, error) {
+	row := q.db.QueryRow(ctx, getReadingProgressForUpdate, arg.BookID, arg.UserID)
+	var i ReadingProgress
+	err := row.Scan(
+		&i.UserID,
+		&i.BookID,
+		&i.CurrentPage,
+		&i.PercentageComplete,
+		&i.LastReadAt,
+		&i.Status,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.LocatorHref,
+		&i.LocatorCfi,
+		&i.LocatorProgression,
+		&i.LocatorTotalProgression,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setReadingProgressPercentage = `-- name: SetReadingProgressPercentage :exec
+UPDATE reading_progress
+SET percentage_complete = $1, status = $2,
+    started_at = $3, finished_at = $4, updated_at = NOW()
+WHERE book_id = $5 AND user_id = $6
+`
+
+type SetReadingProgressPercentageParams struct {
+	PercentageComplete float64          `json:"percentage_complete"`
+	Status             string           `json:"status"`
+	StartedAt          pgtype.Timestamp `json:"started_at"`
+	FinishedAt         pgtype.Timestamp `json:"finished_at"`
+	BookID             uuid.UUID        `json:"book_id"`
+	UserID             string           `json:"user_id"`
+}
+
+func (q *Queries) SetReadingProgressPercentage(ctx context.Context, arg SetReadingProgressPercentageParams) error {
+	_, err := q.db.Exec(ctx, setReadingProgressPercentage,
+		arg.PercentageComplete,
+		arg.Status,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.BookID,
+		arg.UserID,
+	)
+	return err
+}
+
+const setReadingStatus = `-- name: SetReadingStatus :one
+UPDATE reading_progress
+SET status = $1, started_at = $2, finished_at = $3, updated_at = NOW()
+WHERE book_id = $4 AND user_id = $5
+RETURNING user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at
+`
+
+type SetReadingStatusParams struct {
+	Status     string           `json:"status"`
+	StartedAt  pgtype.Timestamp `json:"started_at"`
+	FinishedAt pgtype.Timestamp `json:"finished_at"`
+	BookID     uuid.UUID        `json:"book_id"`
+	UserID     string           `json:"user_id"`
+}
+
+func (q *Queries) SetReadingStatus(ctx context.Context, arg SetReadingStatusParams) (ReadingProgress, error) {
+	row := q.db.QueryRow(ctx, setReadingStatus,
+		arg.Status,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.BookID,
+		arg.UserID,
+	)
+	var i ReadingProgress
+	err := row.Scan(
+		&i.UserID,
+		&i.BookID,
+		&i.CurrentPage,
+		&i.PercentageComplete,
+		&i.LastReadAt,
+		&i.Status,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.LocatorHref,
+		&i.LocatorCfi,
+		&i.LocatorProgression,
+		&i.LocatorTotalProgression,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateReadingProgress = `-- name: UpdateReadingProgress :one
 UPDATE reading_progress 
-SET current_page=$1, percentage_complete=$2 
-WHERE book_id = $3 AND user_id = $4
-RETURNING user_id, book_id, current_page, percentage_complete, last_read_at
+SET current_page=$1, percentage_complete=$2, last_read_at=GREATEST(last_read_at, $3::timestamp),
+    status=$4, started_at=$5, finished_at=$6,
+    locator_href=$7, locator_cfi=$8,
+    locator_progression=$9, locator_total_progression=$10,
+    updated_at=NOW()
+WHERE book_id = $11 AND user_id = $12
+RETURNING user_id, book_id, current_page, percentage_complete, last_read_at, status, started_at, finished_at, locator_href, locator_cfi, locator_progression, locator_total_progression, updated_at
 `
 
 type UpdateReadingProgressParams struct {
-	CurrentPage        int32     `json:"current_page"`
-	PercentageComplete float64   `json:"percentage_complete"`
-	BookID             uuid.UUID `json:"book_id"`
-	UserID             string    `json:"user_id"`
+	CurrentPage             int32            `json:"current_page"`
+	PercentageComplete      float64          `json:"percentage_complete"`
+	ReadAt                  time.Time        `json:"read_at"`
+	Status                  string           `json:"status"`
+	StartedAt               pgtype.Timestamp `json:"started_at"`
+	FinishedAt              pgtype.Timestamp `json:"finished_at"`
+	LocatorHref             *string          `json:"locator_href"`
+	LocatorCfi              *string          `json:"locator_cfi"`
+	LocatorProgression      *float64         `json:"locator_progression"`
+	LocatorTotalProgression *float64         `json:"locator_total_progression"`
+	BookID                  uuid.UUID        `json:"book_id"`
+	UserID                  string           `json:"user_id"`
 }
 
 func (q *Queries) UpdateReadingProgress(ctx context.Context, arg UpdateReadingProgressParams) (ReadingProgress, error) {
 	row := q.db.QueryRow(ctx, updateReadingProgress,
 		arg.CurrentPage,
 		arg.PercentageComplete,
+		arg.ReadAt,
+		arg.Status,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.LocatorHref,
+		arg.LocatorCfi,
+		arg.LocatorProgression,
+		arg.LocatorTotalProgression,
 		arg.BookID,
 		arg.UserID,
 	)
@@ -76,6 +416,14 @@ func (q *Queries) UpdateReadingProgress(ctx context.Context, arg UpdateReadingPr
 		&i.CurrentPage,
 		&i.PercentageComplete,
 		&i.LastReadAt,
+		&i.Status,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.LocatorHref,
+		&i.LocatorCfi,
+		&i.LocatorProgression,
+		&i.LocatorTotalProgression,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -12,7 +12,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, username, first_name, last_name, email, phone)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, email, username, first_name, last_name, added_at, updated_at, phone
+RETURNING id, email, username, first_name, last_name, added_at, updated_at, phone, time_zone, profile_synced_at
 `
 
 type CreateUserParams struct {
@@ -43,12 +43,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.AddedAt,
 		&i.UpdatedAt,
 		&i.Phone,
+		&i.TimeZone,
+		&i.ProfileSyncedAt,
 	)
 	return i, err
 }
 
 const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, email, username, first_name, last_name, added_at, updated_at, phone FROM users
+SELECT id, email, username, first_name, last_name, added_at, updated_at, phone, time_zone, profile_synced_at FROM users
 `
 
 func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
@@ -69,6 +71,8 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 			&i.AddedAt,
 			&i.UpdatedAt,
 			&i.Phone,
+			&i.TimeZone,
+			&i.ProfileSyncedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -80,8 +84,31 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, email, username, first_name, last_name, added_at, updated_at, phone, time_zone, profile_synced_at FROM users
+WHERE lower(email) = lower($1::text)
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.AddedAt,
+		&i.UpdatedAt,
+		&i.Phone,
+		&i.TimeZone,
+		&i.ProfileSyncedAt,
+	)
+	return i, err
+}
+
 const getUserById = `-- name: GetUserById :one
-SELECT id, email, username, first_name, last_name, added_at, updated_at, phone FROM users
+SELECT id, email, username, first_name, last_name, added_at, updated_at, phone, time_zone, profile_synced_at FROM users
 WHERE id = $1
 `
 
@@ -97,6 +124,97 @@ func (q *Queries) GetUserById(ctx context.Context, id string) (User, error) {
 		&i.AddedAt,
 		&i.UpdatedAt,
 		&i.Phone,
+		&i.TimeZone,
+		&i.ProfileSyncedAt,
 	)
 	return i, err
 }
+
+const getUserWithFreshProfile = `-- name: GetUserWithFreshProfile :one
+SELECT id, email, username, first_name, last_name, added_at, updated_at, phone, time_zone, profile_synced_at FROM users
+WHERE id = $1 AND profile_synced_at > NOW() - $2::int * INTERVAL '1 second'
+`
+
+type GetUserWithFreshProfileParams struct {
+	ID            string `json:"id"`
+	MaxAgeSeconds int32  `json:"max_age_seconds"`
+}
+
+func (q *Queries) GetUserWithFreshProfile(ctx context.Context, arg GetUserWithFreshProfileParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserWithFreshProfile, arg.ID, arg.MaxAgeSeconds)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.AddedAt,
+		&i.UpdatedAt,
+		&i.Phone,
+		&i.TimeZone,
+		&i.ProfileSyncedAt,
+	)
+	return i, err
+}
+
+const setUserTimeZone = `-- name: SetUserTimeZone :one
+UPDATE users
+SET time_zone = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, email, username, first_name, last_name, added_at, updated_at, phone, time_zone, profile_synced_at
+`
+
+type SetUserTimeZoneParams struct {
+	TimeZone string `json:"time_zone"`
+	ID       string `json:"id"`
+}
+
+func (q *Queries) SetUserTimeZone(ctx context.Context, arg SetUserTimeZoneParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserTimeZone, arg.TimeZone, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Username,
+		&i.FirstName,
+		&i.LastName,
+		&i.AddedAt,
+		&i.UpdatedAt,
+		&i.Phone,
+		&i.TimeZone,
+		&i.ProfileSyncedAt,
+	)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :execrows
+UPDATE users
+SET email = $1, username = $2, first_name = $3, last_name = $4,
+    phone = $5, profile_synced_at = NOW(), updated_at = NOW()
+WHERE id = $6
+`
+
+type UpdateUserProfileParams struct {
+	Email     string  `json:"email"`
+	Username  *string `json:"username"`
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Phone     *string `json:"phone"`
+	ID        string  `json:"id"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserProfile,
+		arg.Email,
+		arg.Username,
+		arg.FirstName,
+		arg.LastName,
+		arg.Phone,
+		arg.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/Hodik/noteshelf-be.git/utils"
+)
+
+const (
+	s3DeleteAttempts       = 3
+	s3DeletionBatchSize    = 50
+	s3DeletionPollInterval = time.Minute
+	maxS3DeletionBackoff   = 6 * time.Hour
+)
+
+// s3DeletionWake nudges the worker to drain the queue right away instead of at
+// its next poll.
+var s3DeletionWake = make(chan struct{}, 1)
+
+// queueS3Deletions records keys to delete from S3. It must run in the same
+// transaction that removes whatever referenced them.
+func queueS3Deletions(ctx context.Context, queries *repository.Queries, keys []string) error {
+	for _, key := range keys {
+		if err := queries.CreatePendingS3Deletion(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func wakeS3DeletionWorker() {
+	select {
+	case s3DeletionWake <- struct{}{}:
+	default:
+	}
+}
+
+// runS3DeletionWorker deletes the objects queued in pending_s3_deletions until
+// ctx is done. A key stays queued until S3 confirms the delete and is retried
+// with a growing backoff meanwhile, so neither failures nor restarts leave
+// orphaned objects behind.
+func runS3DeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(s3DeletionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		drainS3Deletions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s3DeletionWake:
+		}
+	}
+}
+
+// drainS3Deletions works through every deletion that is due. Claimed rows are
+// leased for a few minutes, so other instances leave them alone and a crash
+// only delays them.
+func drainS3Deletions(ctx context.Context) {
+	for ctx.Err() == nil {
+		pending, err := cfg.Queries.ClaimPendingS3Deletions(ctx, s3DeletionBatchSize)
+		if err != nil {
+			log.Printf("error claiming pending s3 deletions: %s", err)
+			return
+		}
+
+		for _, deletion := range pending {
+			err := utils.DeleteObjectWithRetry(ctx, cfg.S3Client, cfg.BucketName, deletion.S3Key, s3DeleteAttempts)
+			if err == nil {
+				if err := cfg.Queries.DeletePendingS3Deletion(ctx, deletion.ID); err != nil {
+					log.Printf("error dequeuing deleted s3 object %s: %s", deletion.S3Key, err)
+				}
+				continue
+			}
+
+			delay := s3DeletionBackoff(deletion.Attempts)
+			log.Printf("error deleting s3 object %s (attempt %d), retrying in %s: %s", deletion.S3Key, deletion.Attempts, delay, err)
+			message := err.Error()
+			if err := cfg.Queries.RetryPendingS3Deletion(ctx, repository.RetryPendingS3DeletionParams{DelaySeconds: delay.Seconds(), LastError: &message, ID: deletion.ID}); err != nil {
+				log.Printf("error rescheduling s3 deletion of %s: %s", deletion.S3Key, err)
+			}
+		}
+
+		if len(pending) < s3DeletionBatchSize {
+			return
+		}
+	}
+}
+
+// s3DeletionBackoff doubles the wait after every failed attempt, starting at a
+// minute and capped at maxS3DeletionBackoff.
+func s3DeletionBackoff(attempts int32) time.Duration {
+	delay := time.Minute
+	for i := int32(1); i < attempts && delay < maxS3DeletionBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxS3DeletionBackoff)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestS3DeletionBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 5, want: 16 * time.Minute},
+		{attempts: 10, want: maxS3DeletionBackoff},
+		{attempts: 1 << 30, want: maxS3DeletionBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := s3DeletionBackoff(tt.attempts); got != tt.want {
+			t.Errorf("s3DeletionBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
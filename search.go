@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/bookmeta"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// maxPagePartSize bounds the text stored in one book_pages row. A row's
+// tsvector must stay below 1MB, and for text made of many distinct short words
+// it can grow to a few times the size of the text.
+const maxPagePartSize = 256 << 10
+
+// Matches in snippets are delimited with private use characters, which are
+// stripped from the text first. That way the text can be HTML escaped before
+// the delimiters become <mark> tags.
+const (
+	snippetStartSel = "\uE000"
+	snippetStopSel  = "\uE001"
+)
+
+var snippetMarks = strings.NewReplacer(snippetStartSel, "<mark>", snippetStopSel, "</mark>")
+
+func snippetHTML(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+// splitPageContent cuts content into parts of at most size bytes, breaking at
+// whitespace where possible and never inside a UTF-8 sequence.
+func splitPageContent(content string, size int) []string {
+	var parts []string
+	for len(content) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = size
+		}
+		if space := strings.LastIndexFunc(content[:cut], unicode.IsSpace); space > size/2 {
+			cut = space
+		}
+		parts = append(parts, content[:cut])
+		content = strings.TrimLeftFunc(content[cut:], unicode.IsSpace)
+	}
+	if content != "" {
+		parts = append(parts, content)
+	}
+	return parts
+}
+
+// indexBookContent stores the extracted text of every page of a book so it can
+// be matched by the search endpoint. Re-indexing replaces earlier pages. Pages
+// longer than maxPagePartSize are stored in several parts, so a single huge
+// spine item can't fail the index of the whole book.
+func indexBookContent(ctx context.Context, book repository.Book, data []byte) error {
+	pages, err := bookmeta.ExtractText(data)
+	if errors.Is(err, bookmeta.ErrUnsupportedFormat) {
+		log.Printf("no content indexed for book %s: %s", book.ID, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.DBPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	localQueries := repository.New(tx)
+	if err := localQueries.DeleteBookPages(ctx, book.ID); err != nil {
+		return err
+	}
+
+	for i, content := range pages {
+		for part, text := range splitPageContent(content, maxPagePartSize) {
+			if err := localQueries.CreateBookPage(ctx, repository.CreateBookPageParams{BookID: book.ID, PageNumber: int32(i + 1), Part: int32(part), Content: text}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+type SearchHit struct {
+	BookID     uuid.UUID `json:"book_id"`
+	Title      string    `json:"title"`
+	Author     *string   `json:"author"`
+	PageNumber *int32    `json:"page_number"`
+	Snippet    string    `json:"snippet"`
+	Rank       float64   `json:"rank"`
+}
+
+// searchHandler matches q against book titles and authors first and then
+// against the indexed page content. Snippets are HTML with every match wrapped
+// in <mark> tags and the book's own text escaped.
+//
+// The two use different text search configurations on purpose. Titles and
+// authors are matched with 'simple', which neither stems nor drops stop
+// words, so names match as typed and a book called "It" or "The End" can be
+// found at all. Page content is prose and is matched with 'english', so
+// "running" also finds "run"; its stop words are too common in a page to be
+// useful anyway.
+func searchHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+			return
+		}
+	}
+
+	bookHits, err := cfg.Queries.SearchBooks(c, repository.SearchBooksParams{Query: query, UserID: dbUser.ID, ResultLimit: int32(limit)})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	pageHits, err := cfg.Queries.SearchBookPages(c, repository.SearchBookPagesParams{Query: query, UserID: dbUser.ID, ResultLimit: int32(limit)})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hits := make([]SearchHit, 0, len(bookHits)+len(pageHits))
+	for _, hit := range bookHits {
+		hits = append(hits, SearchHit{BookID: hit.BookID, Title: hit.Title, Author: hit.Author, Snippet: snippetHTML(hit.Snippet), Rank: hit.Rank})
+	}
+	for _, hit := range pageHits {
+		pageNumber := hit.PageNumber
+		hits = append(hits, SearchHit{BookID: hit.BookID, Title: hit.Title, Author: hit.Author, PageNumber: &pageNumber, Snippet: snippetHTML(hit.Snippet), Rank: hit.Rank})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hits": hits})
+}
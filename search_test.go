@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSnippetHTML(t *testing.T) {
+	mark := func(s string) string { return snippetStartSel + s + snippetStopSel }
+
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{name: "plain match", snippet: "the " + mark("whale") + " surfaced", want: "the <mark>whale</mark> surfaced"},
+		{name: "script in book text", snippet: "<script>alert(1)</script> " + mark("whale"), want: "&lt;script&gt;alert(1)&lt;/script&gt; <mark>whale</mark>"},
+		{name: "attribute injection", snippet: `<img src=x onerror="steal()"> ` + mark("whale"), want: "&lt;img src=x onerror=&#34;steal()&#34;&gt; <mark>whale</mark>"},
+		{name: "literal mark tags are escaped", snippet: "<mark>not a match</mark>", want: "&lt;mark&gt;not a match&lt;/mark&gt;"},
+		{name: "ampersand", snippet: "Tom & " + mark("Jerry"), want: "Tom &amp; <mark>Jerry</mark>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snippetHTML(tt.snippet); got != tt.want {
+				t.Errorf("snippetHTML(%q) = %q, want %q", tt.snippet, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPageContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		size    int
+		want    []string
+	}{
+		{name: "empty", content: "", size: 10, want: nil},
+		{name: "fits", content: "call me ishmael", size: 20, want: []string{"call me ishmael"}},
+		{name: "breaks at whitespace", content: "call me ishmael some years ago", size: 16, want: []string{"call me ishmael", "some years ago"}},
+		{name: "long word is cut", content: "abcdefghijklmnop", size: 6, want: []string{"abcdef", "ghijkl", "mnop"}},
+		{name: "never splits a rune", content: "ééééé", size: 3, want: []string{"é", "é", "é", "é", "é"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitPageContent(tt.content, tt.size)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitPageContent(%q, %d) = %q, want %q", tt.content, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPageContentOversizedPage(t *testing.T) {
+	// An EPUB spine item holding a whole book in one file.
+	content := strings.Repeat("Il était une fois une très longue histoire. ", 100_000)
+
+	parts := splitPageContent(content, maxPagePartSize)
+	if len(parts) < 2 {
+		t.Fatalf("splitPageContent() returned %d parts, want the page split", len(parts))
+	}
+
+	total := 0
+	for i, part := range parts {
+		if len(part) > maxPagePartSize {
+			t.Errorf("part %d is %d bytes, want at most %d", i, len(part), maxPagePartSize)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d is not valid UTF-8", i)
+		}
+		total += len(strings.Fields(part))
+	}
+	if want := len(strings.Fields(content)); total != want {
+		t.Errorf("parts hold %d words, want %d", total, want)
+	}
+}
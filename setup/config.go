@@ -3,6 +3,7 @@ package setup
 import (
 	"crypto/rsa"
 
+	"github.com/Hodik/noteshelf-be.git/auth"
 	"github.com/Hodik/noteshelf-be.git/repository"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -17,4 +18,5 @@ type Config struct {
 	CloudfrontUrl             string
 	PrivateSignKey            *rsa.PrivateKey
 	KeyPairID                 string
+	Authenticator             auth.Authenticator
 }
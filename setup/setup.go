@@ -1,13 +1,17 @@
 package setup
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"github.com/Hodik/noteshelf-be.git/auth"
 	"github.com/Hodik/noteshelf-be.git/repository"
 	"github.com/Hodik/noteshelf-be.git/utils"
-	"github.com/clerk/clerk-sdk-go/v2"
+	jose "github.com/go-jose/go-jose/v3"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -38,9 +42,43 @@ func SetupQueries(dbPool *pgxpool.Pool) *repository.Queries {
 	return repository.New(dbPool)
 }
 
+// SetupAuthenticator picks the identity provider from AUTH_PROVIDER. Clerk is
+// the default; CLERK_PROFILE_TTL sets how long its profiles are cached, and
+// CLERK_SHARED_PROFILE_CACHE=false stops recording their freshness in
+// Postgres. "jwt" verifies tokens locally against AUTH_JWKS_FILE, or the PEM
+// key in AUTH_PUBLIC_KEY_FILE, which defaults to ./public_key.pem.
+func SetupAuthenticator(queries *repository.Queries) (auth.Authenticator, error) {
+	switch provider := os.Getenv("AUTH_PROVIDER"); provider {
+	case "", "clerk":
+		ttl := auth.DefaultProfileTTL
+		if raw := os.Getenv("CLERK_PROFILE_TTL"); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid CLERK_PROFILE_TTL %q", raw)
+			}
+			ttl = parsed
+		}
+		shared := os.Getenv("CLERK_SHARED_PROFILE_CACHE") != "false"
+		return auth.NewClerkAuthenticator(os.Getenv("CLERK_SECRET_KEY"), ttl, queries, shared), nil
+	case "jwt":
+		var keys jose.JSONWebKeySet
+		var err error
+		if jwksFile := os.Getenv("AUTH_JWKS_FILE"); jwksFile != "" {
+			keys, err = auth.LoadJWKS(jwksFile)
+		} else {
+			keys, err = auth.LoadPEMKeys(cmp.Or(os.Getenv("AUTH_PUBLIC_KEY_FILE"), "./public_key.pem"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewJWTAuthenticator(keys, os.Getenv("AUTH_ISSUER"), os.Getenv("AUTH_AUDIENCE"))
+	default:
+		return nil, fmt.Errorf("unknown auth provider %q", provider)
+	}
+}
+
 func Setup(presignedUrlExpirySeconds int) Config {
 
-	clerk.SetKey(os.Getenv("CLERK_SECRET_KEY"))
 	dbPool, err := SetupDB()
 	if err != nil {
 		log.Fatalln("failed to setup db connections", err.Error())
@@ -72,5 +110,10 @@ func Setup(presignedUrlExpirySeconds int) Config {
 	}
 	queries := SetupQueries(dbPool)
 
-	return Config{DBPool: dbPool, Queries: queries, S3Client: s3Client, BucketName: bucketName, PresignedUrlExpirySeconds: int64(presignedUrlExpirySeconds), CloudfrontUrl: cloudfrontUrl, PrivateSignKey: privateSignKey, KeyPairID: keyPairID}
+	authenticator, err := SetupAuthenticator(queries)
+	if err != nil {
+		log.Fatalf("failed to setup authentication %s", err)
+	}
+
+	return Config{DBPool: dbPool, Queries: queries, S3Client: s3Client, BucketName: bucketName, PresignedUrlExpirySeconds: int64(presignedUrlExpirySeconds), CloudfrontUrl: cloudfrontUrl, PrivateSignKey: privateSignKey, KeyPairID: keyPairID, Authenticator: authenticator}
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ShareBookRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type BookShareResponse struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	FirstName *string   `json:"first_name"`
+	LastName  *string   `json:"last_name"`
+	SharedBy  string    `json:"shared_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func listBookSharesHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getOwnedBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.Queries.GetBookSharesByBookID(c, book.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	shares := make([]BookShareResponse, 0, len(rows))
+	for _, row := range rows {
+		shares = append(shares, BookShareResponse{
+			UserID:    row.BookShare.UserID,
+			Email:     row.Email,
+			FirstName: row.FirstName,
+			LastName:  row.LastName,
+			SharedBy:  row.BookShare.SharedBy,
+			CreatedAt: row.BookShare.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// shareBookHandler grants the user registered under the given email read
+// access to a book and gives them their own reading progress for it.
+// shareBookHandler shares a book with the user registered under an email. It
+// answers 204 whether or not anyone is, and whether or not the book was
+// already shared with them, so it can't be used to find out which emails have
+// an account.
+func shareBookHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req ShareBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	book, ok := getOwnedBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	recipient, err := cfg.Queries.GetUserByEmail(c, strings.TrimSpace(req.Email))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if recipient.ID == dbUser.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot share a book with yourself"})
+		return
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	if _, err := localQueries.CreateBookShare(c, repository.CreateBookShareParams{BookID: book.ID, UserID: recipient.ID, SharedBy: dbUser.ID}); err != nil {
+		if isUniqueViolation(err) {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Progress is kept when access is revoked, so a re-share picks up where
+	// the reader left off.
+	if err := localQueries.EnsureReadingProgress(c, repository.EnsureReadingProgressParams{BookID: book.ID, UserID: recipient.ID}); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func revokeBookShareHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	book, ok := getOwnedBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteBookShare(c, repository.DeleteBookShareParams{BookID: book.ID, UserID: c.Param("user_id")})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "book is not shared with this user"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
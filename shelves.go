@@ -0,0 +1,317 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/Hodik/noteshelf-be.git/setup"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type ShelfRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type ReorderShelvesRequest struct {
+	ShelfIDs []uuid.UUID `json:"shelf_ids" binding:"required"`
+}
+
+type LibraryShelf struct {
+	repository.Shelf
+	BookCount int32 `json:"book_count"`
+}
+
+func parseShelfID(c *gin.Context) (uuid.UUID, bool) {
+	shelfID := c.Param("shelf_id")
+	uuidShelfID, err := uuid.Parse(shelfID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": shelfID + " is not a valid uuid"})
+		return uuid.Nil, false
+	}
+
+	return uuidShelfID, true
+}
+
+// getOwnedShelf loads the shelf referenced by the shelf_id path parameter. Other
+// users' shelves are reported as missing. The request is aborted when ok is
+// false.
+func getOwnedShelf(c *gin.Context, dbUser *repository.User) (shelf repository.Shelf, ok bool) {
+	shelfID, ok := parseShelfID(c)
+	if !ok {
+		return shelf, false
+	}
+
+	shelf, err := cfg.Queries.GetShelfByID(c, repository.GetShelfByIDParams{ID: shelfID, UserID: dbUser.ID})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "shelf not found"})
+			return shelf, false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return shelf, false
+	}
+
+	return shelf, true
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == setup.UniqueViolationCode
+}
+
+func listShelvesHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := cfg.Queries.GetShelvesByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	shelves := make([]LibraryShelf, 0, len(rows))
+	for _, row := range rows {
+		shelves = append(shelves, LibraryShelf{Shelf: row.Shelf, BookCount: row.BookCount})
+	}
+
+	c.JSON(http.StatusOK, shelves)
+}
+
+func createShelfHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req ShelfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be blank"})
+		return
+	}
+
+	shelf, err := cfg.Queries.CreateShelf(c, repository.CreateShelfParams{ID: uuid.New(), UserID: dbUser.ID, Name: name})
+	if err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a shelf with this name already exists"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, shelf)
+}
+
+func renameShelfHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelfID, ok := parseShelfID(c)
+	if !ok {
+		return
+	}
+
+	var req ShelfRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be blank"})
+		return
+	}
+
+	shelf, err := cfg.Queries.RenameShelf(c, repository.RenameShelfParams{Name: name, ID: shelfID, UserID: dbUser.ID})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "shelf not found"})
+			return
+		}
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a shelf with this name already exists"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shelf)
+}
+
+// reorderShelvesHandler takes every shelf of the user in the desired order and
+// stores each shelf's index as its position.
+func reorderShelvesHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req ReorderShelvesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	existing, err := localQueries.GetShelvesByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(req.ShelfIDs))
+	for _, id := range req.ShelfIDs {
+		if seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shelf " + id.String() + " is listed twice"})
+			return
+		}
+		seen[id] = true
+	}
+	if len(req.ShelfIDs) != len(existing) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "shelf_ids must list every shelf exactly once"})
+		return
+	}
+
+	for position, id := range req.ShelfIDs {
+		updated, err := localQueries.SetShelfPosition(c, repository.SetShelfPositionParams{Position: int32(position), ID: id, UserID: dbUser.ID})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if updated == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shelf " + id.String() + " not found"})
+			return
+		}
+	}
+
+	rows, err := localQueries.GetShelvesByUserID(c, dbUser.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelves := make([]LibraryShelf, 0, len(rows))
+	for _, row := range rows {
+		shelves = append(shelves, LibraryShelf{Shelf: row.Shelf, BookCount: row.BookCount})
+	}
+
+	c.JSON(http.StatusOK, shelves)
+}
+
+func deleteShelfHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelfID, ok := parseShelfID(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.Queries.DeleteShelf(c, repository.DeleteShelfParams{ID: shelfID, UserID: dbUser.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deleted == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "shelf not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func addBookToShelfHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelf, ok := getOwnedShelf(c, dbUser)
+	if !ok {
+		return
+	}
+
+	book, ok := getReadableBook(c, dbUser)
+	if !ok {
+		return
+	}
+
+	if err := cfg.Queries.AddBookToShelf(c, repository.AddBookToShelfParams{ShelfID: shelf.ID, BookID: book.ID}); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func removeBookFromShelfHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	shelf, ok := getOwnedShelf(c, dbUser)
+	if !ok {
+		return
+	}
+
+	bookID := c.Param("book_id")
+	uuidBookID, err := uuid.Parse(bookID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": bookID + " is not a valid uuid"})
+		return
+	}
+
+	removed, err := cfg.Queries.RemoveBookFromShelf(c, repository.RemoveBookFromShelfParams{ShelfID: shelf.ID, BookID: uuidBookID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if removed == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "book is not on this shelf"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
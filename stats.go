@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// readingSessionIdleGap is how long a reader may go without a progress update
+// before the next update starts a new session.
+const readingSessionIdleGap = 30 * time.Minute
+
+const (
+	statsDateLayout       = "2006-01-02"
+	defaultStatsRangeDays = 30
+)
+
+// recordReadingEvent stores a progress update as an event and folds it into
+// the reader's session for the book, starting a new one after an idle gap.
+// Updates may arrive out of order, so the session is matched around readAt
+// rather than only at its end.
+func recordReadingEvent(ctx context.Context, q *repository.Queries, bookID uuid.UUID, userID string, fromPage, toPage int32, readAt time.Time) (repository.ReadingEvent, error) {
+	pagesRead := max(toPage-fromPage, 0)
+
+	session, err := q.GetOpenReadingSession(ctx, repository.GetOpenReadingSessionParams{
+		UserID:      userID,
+		BookID:      bookID,
+		WindowStart: readAt.Add(-readingSessionIdleGap),
+		WindowEnd:   readAt.Add(readingSessionIdleGap),
+	})
+	switch {
+	case err == nil:
+		session, err = q.ExtendReadingSession(ctx, repository.ExtendReadingSessionParams{ReadAt: readAt, EndPage: toPage, PagesRead: pagesRead, ID: session.ID})
+	case strings.Contains(err.Error(), "no rows"):
+		session, err = q.CreateReadingSession(ctx, repository.CreateReadingSessionParams{
+			ID:        uuid.New(),
+			UserID:    userID,
+			BookID:    bookID,
+			ReadAt:    readAt,
+			StartPage: fromPage,
+			EndPage:   toPage,
+			PagesRead: pagesRead,
+		})
+	}
+	if err != nil {
+		return repository.ReadingEvent{}, err
+	}
+
+	return q.CreateReadingEvent(ctx, repository.CreateReadingEventParams{
+		ID:        uuid.New(),
+		SessionID: session.ID,
+		UserID:    userID,
+		BookID:    bookID,
+		FromPage:  fromPage,
+		ToPage:    toPage,
+		ReadAt:    readAt,
+	})
+}
+
+type DailyReading struct {
+	Day         string `json:"day"`
+	Pages       int32  `json:"pages"`
+	SecondsRead int64  `json:"seconds_read"`
+}
+
+type SessionSummary struct {
+	ID        uuid.UUID `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	StartPage int32     `json:"start_page"`
+	EndPage   int32     `json:"end_page"`
+	PagesRead int32     `json:"pages_read"`
+}
+
+type BookTimeline struct {
+	BookID      uuid.UUID        `json:"book_id"`
+	Title       string           `json:"title"`
+	PagesRead   int32            `json:"pages_read"`
+	SecondsRead int64            `json:"seconds_read"`
+	Sessions    []SessionSummary `json:"sessions"`
+}
+
+type ReadingStats struct {
+	From                 string                                   `json:"from"`
+	To                   string                                   `json:"to"`
+	TotalPages           int32                                    `json:"total_pages"`
+	TotalSecondsRead     int64                                    `json:"total_seconds_read"`
+	PagesPerHour         float64                                  `json:"pages_per_hour"`
+	Days                 []DailyReading                           `json:"days"`
+	BooksFinishedByMonth []repository.GetBooksFinishedPerMonthRow `json:"books_finished_by_month"`
+	Books                []BookTimeline                           `json:"books"`
+}
+
+// parseStatsRange reads the inclusive from and to dates of the stats query,
+// days in loc, defaulting to the last 30 days. The returned end is exclusive.
+func parseStatsRange(c *gin.Context, loc *time.Location) (from, to time.Time, ok bool) {
+	now := time.Now().In(loc)
+	to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	from = to.AddDate(0, 0, -defaultStatsRangeDays)
+
+	var err error
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.ParseInLocation(statsDateLayout, raw, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2006-01-02"})
+			return from, to, false
+		}
+		to = to.AddDate(0, 0, 1)
+		from = to.AddDate(0, 0, -defaultStatsRangeDays)
+	}
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.ParseInLocation(statsDateLayout, raw, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2006-01-02"})
+			return from, to, false
+		}
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+func getStatsHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Days and months are the reader's, so reading late in the evening isn't
+	// counted towards the next day.
+	loc := userLocation(dbUser)
+	from, to, ok := parseStatsRange(c, loc)
+	if !ok {
+		return
+	}
+
+	pagesPerDay, err := cfg.Queries.GetPagesReadPerDay(c, repository.GetPagesReadPerDayParams{TimeZone: loc.String(), UserID: dbUser.ID, FromTime: from.UTC(), ToTime: to.UTC()})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, err := cfg.Queries.GetReadingSessionsInRange(c, repository.GetReadingSessionsInRangeParams{UserID: dbUser.ID, FromTime: from.UTC(), ToTime: to.UTC()})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	finished, err := cfg.Queries.GetBooksFinishedPerMonth(c, repository.GetBooksFinishedPerMonthParams{TimeZone: loc.String(), UserID: dbUser.ID, FromTime: from.UTC(), ToTime: to.UTC()})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats := ReadingStats{
+		From:                 from.Format(statsDateLayout),
+		To:                   to.AddDate(0, 0, -1).Format(statsDateLayout),
+		Days:                 []DailyReading{},
+		BooksFinishedByMonth: finished,
+		Books:                []BookTimeline{},
+	}
+
+	days := map[string]int{}
+	for _, day := range pagesPerDay {
+		days[day.Day] = len(stats.Days)
+		stats.Days = append(stats.Days, DailyReading{Day: day.Day, Pages: day.Pages})
+		stats.TotalPages += day.Pages
+	}
+
+	// Reading speed only counts sessions with a measurable duration; a single
+	// update has no length.
+	var timedPages int32
+	for _, row := range sessions {
+		session := row.ReadingSession
+		seconds := int64(session.EndedAt.Sub(session.StartedAt).Seconds())
+		stats.TotalSecondsRead += seconds
+		if seconds > 0 {
+			timedPages += session.PagesRead
+		}
+
+		day := session.StartedAt.In(loc).Format(statsDateLayout)
+		if i, ok := days[day]; ok {
+			stats.Days[i].SecondsRead += seconds
+		} else {
+			days[day] = len(stats.Days)
+			stats.Days = append(stats.Days, DailyReading{Day: day, SecondsRead: seconds})
+		}
+
+		if n := len(stats.Books); n == 0 || stats.Books[n-1].BookID != session.BookID {
+			stats.Books = append(stats.Books, BookTimeline{BookID: session.BookID, Title: row.Title})
+		}
+		timeline := &stats.Books[len(stats.Books)-1]
+		timeline.PagesRead += session.PagesRead
+		timeline.SecondsRead += seconds
+		timeline.Sessions = append(timeline.Sessions, SessionSummary{
+			ID:        session.ID,
+			StartedAt: session.StartedAt,
+			EndedAt:   session.EndedAt,
+			StartPage: session.StartPage,
+			EndPage:   session.EndPage,
+			PagesRead: session.PagesRead,
+		})
+	}
+
+	if stats.TotalSecondsRead > 0 {
+		stats.PagesPerHour = float64(timedPages) / (float64(stats.TotalSecondsRead) / 3600)
+	}
+
+	slices.SortFunc(stats.Days, func(a, b DailyReading) int { return strings.Compare(a.Day, b.Day) })
+
+	c.JSON(http.StatusOK, stats)
+}
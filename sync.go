@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hodik/noteshelf-be.git/auth"
+	"github.com/Hodik/noteshelf-be.git/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxSyncBatchSize = 500
+
+const (
+	syncApplied  = "applied"
+	syncRejected = "rejected"
+	syncFailed   = "failed"
+)
+
+type SyncProgressEvent struct {
+	BookID      uuid.UUID `json:"book_id" binding:"required"`
+	DeviceID    string    `json:"device_id" binding:"required,max=100"`
+	DeviceName  *string   `json:"device_name" binding:"omitempty,max=100"`
+	CurrentPage int32     `json:"current_page" binding:"omitempty,min=1"`
+	Locator     *Locator  `json:"locator"`
+	ReadAt      time.Time `json:"read_at" binding:"required"`
+}
+
+type SyncProgressRequest struct {
+	SyncToken string              `json:"sync_token"`
+	Limit     int                 `json:"limit" binding:"omitempty,min=1,max=1000"`
+	Events    []SyncProgressEvent `json:"events" binding:"dive"`
+}
+
+type SyncProgressResult struct {
+	BookID   uuid.UUID                   `json:"book_id"`
+	Status   string                      `json:"status"`
+	Error    string                      `json:"error,omitempty"`
+	Progress *repository.ReadingProgress `json:"progress,omitempty"`
+	Conflict *PositionConflict           `json:"conflict,omitempty"`
+}
+
+type SyncProgressResponse struct {
+	Results   []SyncProgressResult         `json:"results"`
+	Changes   []repository.ReadingProgress `json:"changes"`
+	SyncToken string                       `json:"sync_token"`
+	HasMore   bool                         `json:"has_more"`
+}
+
+// progressTokenPrefix marks sync tokens that only vouch for progress changes.
+// Such a token may be ahead of book, shelf, annotation or bookmark changes the
+// client has never seen, so GET /changes rejects it rather than skipping them.
+const progressTokenPrefix = "progress:"
+
+func encodeProgressSyncToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(progressTokenPrefix + strconv.FormatInt(id, 10)))
+}
+
+// decodeProgressSyncToken accepts progress tokens as well as GET /changes
+// tokens, which cover progress changes along with everything else.
+func decodeProgressSyncToken(token string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(data), progressTokenPrefix) {
+		return decodeChangeToken(token)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(data), progressTokenPrefix), 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid sync token")
+	}
+	return id, nil
+}
+
+// syncProgressHandler applies a batch of progress events recorded by an
+// offline client. Events are applied oldest first inside one transaction, each
+// under its own savepoint so a bad event doesn't take the rest down with it.
+// The response lists a result per event, in request order, and every progress
+// change since the client's sync token, a page at a time like GET /changes:
+// while has_more is set the client calls again with the returned token and no
+// events. The returned token only covers progress and can't be handed to
+// GET /changes; a GET /changes token can be used here, though.
+func syncProgressHandler(c *gin.Context) {
+	dbUser, err := auth.GetDBUserFromRequest(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var req SyncProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Events) > maxSyncBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a batch may contain at most " + strconv.Itoa(maxSyncBatchSize) + " events"})
+		return
+	}
+
+	limit := defaultChangesLimit
+	if req.Limit > 0 {
+		limit = req.Limit
+	}
+
+	since, err := decodeProgressSyncToken(req.SyncToken)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := cfg.DBPool.Begin(c)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(c)
+
+	localQueries := repository.New(tx)
+
+	bookIDs := make([]uuid.UUID, 0, len(req.Events))
+	for _, event := range req.Events {
+		if !slices.Contains(bookIDs, event.BookID) {
+			bookIDs = append(bookIDs, event.BookID)
+		}
+	}
+
+	books := map[uuid.UUID]repository.Book{}
+	if len(bookIDs) > 0 {
+		readable, err := localQueries.GetReadableBooksByIDs(c, repository.GetReadableBooksByIDsParams{Ids: bookIDs, UserID: dbUser.ID})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, book := range readable {
+			books[book.ID] = book
+		}
+	}
+
+	order := make([]int, len(req.Events))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return req.Events[a].ReadAt.Compare(req.Events[b].ReadAt)
+	})
+
+	now := time.Now().UTC()
+	results := make([]SyncProgressResult, len(req.Events))
+	for _, i := range order {
+		event := req.Events[i]
+		result := &results[i]
+		result.BookID = event.BookID
+
+		book, ok := books[event.BookID]
+		if !ok {
+			result.Status, result.Error = syncRejected, "book not found"
+			continue
+		}
+		if event.CurrentPage == 0 && (event.Locator == nil || event.Locator.Locations.TotalProgression == nil) {
+			result.Status, result.Error = syncRejected, "either current_page or a locator with totalProgression is required"
+			continue
+		}
+
+		readAt := now
+		if event.ReadAt.Before(now) {
+			readAt = event.ReadAt.UTC()
+		}
+		update := progressUpdate{DeviceID: event.DeviceID, DeviceName: event.DeviceName, CurrentPage: event.CurrentPage, Locator: event.Locator, ReadAt: readAt}
+
+		savepoint, err := tx.Begin(c)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		itemQueries := repository.New(savepoint)
+		progress, err := saveReadingProgress(c, itemQueries, book, dbUser.ID, update)
+		var conflict *PositionConflict
+		if err == nil {
+			conflict, err = findPositionConflict(c, itemQueries, book, dbUser.ID, update)
+		}
+		if err == nil {
+			err = savepoint.Commit(c)
+		}
+		if err != nil {
+			savepoint.Rollback(c)
+			result.Status, result.Error = syncFailed, err.Error()
+			continue
+		}
+
+		result.Status, result.Progress, result.Conflict = syncApplied, &progress, conflict
+	}
+
+	entries, err := localQueries.GetReadingProgressChangesSince(c, repository.GetReadingProgressChangesSinceParams{UserID: dbUser.ID, Since: since, MaxChanges: int32(limit + 1)})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+
+	changed := collapseChanges(entries)
+	records, err := loadChangedRecords(c, localQueries, dbUser.ID, changed)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(c); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	latest := since
+	if len(entries) > 0 {
+		latest = entries[len(entries)-1].ID
+	}
+	changes := []repository.ReadingProgress{}
+	for _, change := range changed {
+		if progress, ok := records[entityReadingProgress][change.entry.EntityID]; ok {
+			changes = append(changes, progress.(repository.ReadingProgress))
+		}
+	}
+
+	c.JSON(http.StatusOK, SyncProgressResponse{Results: results, Changes: changes, SyncToken: encodeProgressSyncToken(latest), HasMore: hasMore})
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestProgressSyncToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    int64
+		wantErr bool
+	}{
+		{name: "empty", token: "", want: 0},
+		{name: "progress token", token: encodeProgressSyncToken(42), want: 42},
+		{name: "changes token", token: encodeChangeToken(17), want: 17},
+		{name: "negative", token: encodeProgressSyncToken(-1), wantErr: true},
+		{name: "garbage", token: "not a token!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeProgressSyncToken(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeProgressSyncToken(%q) = %d, want an error", tt.token, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("decodeProgressSyncToken(%q) = %d, %v, want %d", tt.token, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangesRejectsProgressSyncToken(t *testing.T) {
+	if _, err := decodeChangeToken(encodeProgressSyncToken(42)); err == nil {
+		t.Error("decodeChangeToken() accepted a progress-only sync token")
+	}
+}
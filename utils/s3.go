@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"bytes"
 	"context"
 	"crypto/rsa"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -60,6 +62,117 @@ func KeyExists(ctx context.Context, client *s3.Client, bucket, key string) bool
 	return false
 }
 
+// DownloadObject reads key from bucket into memory. Objects larger than
+// maxBytes are rejected.
+func DownloadObject(ctx context.Context, client *s3.Client, bucket, key string, maxBytes int64) ([]byte, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	if output.ContentLength != nil && *output.ContentLength > maxBytes {
+		return nil, fmt.Errorf("s3 object %s is larger than %d bytes", key, maxBytes)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(output.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("s3 object %s is larger than %d bytes", key, maxBytes)
+	}
+
+	return data, nil
+}
+
+// ObjectReader reads ranges of an S3 object without downloading all of it.
+type ObjectReader struct {
+	ctx    context.Context
+	client *s3.Client
+	bucket string
+	key    string
+	Size   int64
+}
+
+func NewObjectReader(ctx context.Context, client *s3.Client, bucket, key string) (*ObjectReader, error) {
+	output, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectReader{ctx: ctx, client: client, bucket: bucket, key: key, Size: aws.ToInt64(output.ContentLength)}, nil
+}
+
+func (r *ObjectReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.Size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), r.Size) - 1
+
+	output, err := r.client.GetObject(r.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, end)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer output.Body.Close()
+
+	n, err := io.ReadFull(output.Body, p[:end-off+1])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func UploadObject(ctx context.Context, client *s3.Client, bucket, key string, body []byte, contentType string) error {
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// DeleteObjectWithRetry deletes key from bucket, retrying with exponential
+// backoff until it succeeds, attempts are exhausted or ctx is done.
+func DeleteObjectWithRetry(ctx context.Context, client *s3.Client, bucket, key string, attempts int) error {
+	var err error
+	backoff := 200 * time.Millisecond
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err == nil {
+			return nil
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return fmt.Errorf("error deleting s3 object %s after %d attempts: %s", key, attempts, err.Error())
+}
+
 func GeneratePresignedUploadURL(ctx context.Context, s3Client *s3.Client, bucketName, key string, expirySeconds int64) (string, error) {
 	presignClient := s3.NewPresignClient(s3Client)
 